@@ -10,6 +10,7 @@ import (
 	"net/http"
 	"net/textproto"
 	"strconv"
+	"time"
 
 	"github.com/isucon/isucandar/agent"
 
@@ -167,10 +168,88 @@ func SetCourseStatus(ctx context.Context, a *agent.Agent, courseID string, statu
 	return a.Do(ctx, req)
 }
 
+type GradingPolicyType string
+
+const (
+	GradingScore    GradingPolicyType = "score"
+	GradingLetter   GradingPolicyType = "letter"
+	GradingPassFail GradingPolicyType = "pass_fail"
+)
+
+type ClassWeight struct {
+	ClassID string `json:"class_id"`
+	Weight  int    `json:"weight"`
+}
+
+type SetGradingPolicyRequest struct {
+	Type         GradingPolicyType `json:"type"`
+	PassScore    int               `json:"pass_score"`
+	CutoffS      int               `json:"cutoff_s"`
+	CutoffA      int               `json:"cutoff_a"`
+	CutoffB      int               `json:"cutoff_b"`
+	CutoffC      int               `json:"cutoff_c"`
+	ClassWeights []ClassWeight     `json:"class_weights"`
+}
+
+func SetGradingPolicy(ctx context.Context, a *agent.Agent, courseID string, policy SetGradingPolicyRequest) (*http.Response, error) {
+	body, err := json.Marshal(policy)
+	if err != nil {
+		return nil, fails.ErrorCritical(err)
+	}
+
+	req, err := a.PUT(fmt.Sprintf("/api/courses/%s/grading-policy", courseID), bytes.NewReader(body))
+	if err != nil {
+		return nil, fails.ErrorCritical(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return a.Do(ctx, req)
+}
+
+type SetCourseRequisitesRequest struct {
+	Prerequisites []string `json:"prerequisites"`
+	Corequisites  []string `json:"corequisites"`
+}
+
+func SetCourseRequisites(ctx context.Context, a *agent.Agent, courseID string, requisites SetCourseRequisitesRequest) (*http.Response, error) {
+	body, err := json.Marshal(requisites)
+	if err != nil {
+		return nil, fails.ErrorCritical(err)
+	}
+
+	req, err := a.PUT(fmt.Sprintf("/api/courses/%s/requisites", courseID), bytes.NewReader(body))
+	if err != nil {
+		return nil, fails.ErrorCritical(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return a.Do(ctx, req)
+}
+
+type SetCourseLotteryRequest struct {
+	Enabled bool   `json:"enabled"`
+	Seed    *int64 `json:"seed,omitempty"`
+}
+
+func SetCourseLottery(ctx context.Context, a *agent.Agent, courseID string, lottery SetCourseLotteryRequest) (*http.Response, error) {
+	body, err := json.Marshal(lottery)
+	if err != nil {
+		return nil, fails.ErrorCritical(err)
+	}
+
+	req, err := a.PUT(fmt.Sprintf("/api/courses/%s/lottery", courseID), bytes.NewReader(body))
+	if err != nil {
+		return nil, fails.ErrorCritical(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return a.Do(ctx, req)
+}
+
 type AddClassRequest struct {
-	Part        uint8  `json:"part"`
-	Title       string `json:"title"`
-	Description string `json:"description"`
+	Part        uint8      `json:"part"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Deadline    *time.Time `json:"deadline,omitempty"`
+	LateUntil   *time.Time `json:"late_until,omitempty"`
+	LatePenalty uint8      `json:"late_penalty,omitempty"`
 }
 type AddClassResponse struct {
 	ClassID string `json:"class_id"`
@@ -192,12 +271,16 @@ func AddClass(ctx context.Context, a *agent.Agent, courseID string, classRequest
 }
 
 type GetClassResponse struct {
-	ID               string `json:"id"`
-	Part             uint8  `json:"part"`
-	Title            string `json:"title"`
-	Description      string `json:"description"`
-	SubmissionClosed bool   `json:"submission_closed"`
-	Submitted        bool   `json:"submitted"`
+	ID               string     `json:"id"`
+	Part             uint8      `json:"part"`
+	Title            string     `json:"title"`
+	Description      string     `json:"description"`
+	SubmissionClosed bool       `json:"submission_closed"`
+	Deadline         *time.Time `json:"deadline"`
+	LateUntil        *time.Time `json:"late_until"`
+	LatePenalty      uint8      `json:"late_penalty"`
+	Submitted        bool       `json:"submitted"`
+	Late             bool       `json:"late"`
 }
 
 func GetClasses(ctx context.Context, a *agent.Agent, courseID string) (*http.Response, error) {
@@ -267,6 +350,24 @@ func RegisterScores(ctx context.Context, a *agent.Agent, courseID, classID strin
 	return a.Do(ctx, req)
 }
 
+type SetSubmissionStatusRequest struct {
+	Closed bool `json:"closed"`
+}
+
+func SetSubmissionStatus(ctx context.Context, a *agent.Agent, courseID, classID string, status SetSubmissionStatusRequest) (*http.Response, error) {
+	body, err := json.Marshal(status)
+	if err != nil {
+		return nil, fails.ErrorCritical(err)
+	}
+
+	req, err := a.PUT(fmt.Sprintf("/api/courses/%s/classes/%s/submission-status", courseID, classID), bytes.NewReader(body))
+	if err != nil {
+		return nil, fails.ErrorCritical(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return a.Do(ctx, req)
+}
+
 func DownloadSubmittedAssignments(ctx context.Context, a *agent.Agent, courseID, classID string) (*http.Response, error) {
 	path := fmt.Sprintf("/api/courses/%s/classes/%s/assignments/export", courseID, classID)
 
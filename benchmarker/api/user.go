@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 
 	"github.com/isucon/isucandar/agent"
@@ -55,6 +56,11 @@ type RegisterCoursesErrorResponse struct {
 	CourseNotFound       []string `json:"course_not_found"`
 	NotRegistrableStatus []string `json:"not_registrable_status"`
 	ScheduleConflict     []string `json:"schedule_conflict"`
+	CapacityExceeded     []string `json:"capacity_exceeded"`
+	PrerequisiteNotMet   []string `json:"prerequisite_not_met"`
+	CorequisiteNotMet    []string `json:"corequisite_not_met"`
+	CreditLimitExceeded  []string `json:"credit_limit_exceeded"`
+	LotteryCourse        []string `json:"lottery_course"`
 }
 
 func RegisterCourses(ctx context.Context, a *agent.Agent, courses []RegisterCourseRequestContent) (*http.Response, error) {
@@ -73,6 +79,17 @@ func RegisterCourses(ctx context.Context, a *agent.Agent, courses []RegisterCour
 	return a.Do(ctx, req)
 }
 
+func UnregisterCourse(ctx context.Context, a *agent.Agent, courseID string) (*http.Response, error) {
+	path := fmt.Sprintf("/api/users/me/courses/%s", courseID)
+
+	req, err := a.DELETE(path, nil)
+	if err != nil {
+		return nil, fails.ErrorCritical(err)
+	}
+
+	return a.Do(ctx, req)
+}
+
 type GetGradeResponse struct {
 	Summary       Summary        `json:"summary"`
 	CourseResults []CourseResult `json:"courses"`
@@ -95,6 +112,7 @@ type CourseResult struct {
 	TotalScoreAvg    float64      `json:"total_score_avg"`     // 平均値
 	TotalScoreMax    int          `json:"total_score_max"`     // 最大値
 	TotalScoreMin    int          `json:"total_score_min"`     // 最小値
+	Grade            string       `json:"grade"`
 	ClassScores      []ClassScore `json:"class_scores"`
 }
 
@@ -103,6 +121,7 @@ type ClassScore struct {
 	Title      string `json:"title"`
 	Part       uint8  `json:"part"`
 	Score      *int   `json:"score"`      // 0~100点
+	Late       bool   `json:"late"`       // 遅延提出かどうか
 	Submitters int    `json:"submitters"` // 提出した学生数
 }
 
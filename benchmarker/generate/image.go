@@ -1,30 +1,20 @@
 package generate
 
 import (
-	"bytes"
 	"embed"
-	"image"
-	"image/color"
-	_ "image/jpeg"
+
+	"github.com/isucon/isucon11-final/webapp/go/pdf"
 )
 
 //go:embed data/images/*
 var f embed.FS
 
 var (
-	images     []*Image
+	images     []*pdf.Image
 	imageCount int
 	next       int
 )
 
-type Image struct {
-	format     string
-	colorModel color.Model
-	width      int
-	height     int
-	data       []byte
-}
-
 func init() {
 	files, _ := f.ReadDir("data/images")
 	imageCount = len(files)
@@ -33,22 +23,15 @@ func init() {
 		if err != nil {
 			panic(err)
 		}
-		r := bytes.NewReader(data)
-		config, format, err := image.DecodeConfig(r)
+		img, err := pdf.NewImage(data)
 		if err != nil {
 			panic(err)
 		}
-		images = append(images, &Image{
-			format:     format,
-			colorModel: config.ColorModel,
-			width:      config.Width,
-			height:     config.Height,
-			data:       data,
-		})
+		images = append(images, img)
 	}
 }
 
-func cyclicGetImage() *Image {
+func cyclicGetImage() *pdf.Image {
 	img := images[next]
 	next = (next + 1) % imageCount
 	return img
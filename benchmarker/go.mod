@@ -5,6 +5,7 @@ go 1.17
 require (
 	github.com/isucon/isucandar v0.0.0-20210915091839-3dcdca522300
 	github.com/isucon/isucon10-portal v0.0.0-20201008112716-8c0b637e1bd8
+	github.com/isucon/isucon11-final/webapp/go/pdf v0.0.0
 	github.com/oklog/ulid/v2 v2.0.2
 	github.com/pkg/profile v1.6.0
 )
@@ -17,3 +18,6 @@ require (
 	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
 	google.golang.org/protobuf v1.25.0 // indirect
 )
+
+// webapp と共有している PDF の生成処理
+replace github.com/isucon/isucon11-final/webapp/go/pdf => ../webapp/go/pdf
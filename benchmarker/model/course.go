@@ -45,6 +45,8 @@ type Course struct {
 	capacityCounter    *CapacityCounter
 	classes            []*Class
 	status             api.CourseStatus
+	gradingPolicy      GradingPolicy
+	classWeights       map[string]int // classID -> 重み(百分率)。未設定の講義は既定値
 
 	closer              chan struct{}
 	zeroReservationCond *sync.Cond
@@ -72,6 +74,8 @@ func NewCourse(param *CourseParam, id string, teacher *Teacher, capacity int, ca
 		capacityCounter:    capacityCounter,
 		classes:            make([]*Class, 0, ClassCountPerCourse),
 		status:             api.StatusRegistration,
+		gradingPolicy:      DefaultGradingPolicy(),
+		classWeights:       make(map[string]int),
 
 		closer: make(chan struct{}, 0),
 	}
@@ -86,6 +90,37 @@ func (c *Course) AddClass(class *Class) {
 	c.classes = append(c.classes, class)
 }
 
+// SetGradingPolicy 成績評価の方針を設定する。weights に含まれない講義の重みは既定値に戻る
+func (c *Course) SetGradingPolicy(policy GradingPolicy, weights map[string]int) {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+
+	c.gradingPolicy = policy
+	c.classWeights = make(map[string]int, len(weights))
+	for classID, weight := range weights {
+		c.classWeights[classID] = weight
+	}
+}
+
+// Grade 学生の科目の成績。科目が終了していない場合は nil を返す
+func (c *Course) Grade(code string) *CourseGrade {
+	c.rmu.RLock()
+	defer c.rmu.RUnlock()
+
+	if c.status != api.StatusClosed {
+		return nil
+	}
+	grade := c.gradingPolicy.Grade(c.totalScoreByStudentCode(code), c.Credit)
+	return &grade
+}
+
+func (c *Course) classWeight(classID string) int {
+	if weight, ok := c.classWeights[classID]; ok {
+		return weight
+	}
+	return defaultClassWeight
+}
+
 func (c *Course) Status() api.CourseStatus {
 	c.rmu.RLock()
 	defer c.rmu.RUnlock()
@@ -223,6 +258,38 @@ func (c *Course) RollbackReservation() {
 	}
 }
 
+// ReserveDropIfAvailable は履修受付中なら履修取り消しのために1枠確保し、取り消しが終わるまで科目のステータス変更を保留させる
+func (c *Course) ReserveDropIfAvailable(s *Student) ReservationResult {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+
+	select {
+	case <-c.closer:
+		return NotAvailable
+	default:
+	}
+	if _, ok := c.registeredStudents[s.Code]; !ok {
+		return NotAvailable
+	}
+
+	c.reservations++
+
+	return Succeeded
+}
+
+// CommitDrop は ReserveDropIfAvailable で確保した枠を使って学生の履修を取り消す
+// 失敗時は RollbackReservation で枠を解放すること
+func (c *Course) CommitDrop(s *Student) {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+
+	delete(c.registeredStudents, s.Code)
+	c.reservations--
+	if c.reservations == 0 {
+		c.zeroReservationCond.Broadcast()
+	}
+}
+
 func (c *Course) StartTimer(duration time.Duration) {
 	c.once.Do(func() {
 		go func() {
@@ -308,6 +375,11 @@ func (c *Course) CalcCourseResultByStudentCode(code string) *CourseResult {
 
 	classScores := c.CollectClassScores(code)
 
+	var grade string
+	if c.status == api.StatusClosed {
+		grade = c.gradingPolicy.Grade(totalScore, c.Credit).Grade
+	}
+
 	return &CourseResult{
 		Name:             c.Name,
 		Code:             c.Code,
@@ -316,6 +388,7 @@ func (c *Course) CalcCourseResultByStudentCode(code string) *CourseResult {
 		TotalScoreAvg:    totalAvg,
 		TotalScoreMax:    totalMax,
 		TotalScoreMin:    totalMin,
+		Grade:            grade,
 		ClassScores:      classScores,
 	}
 }
@@ -324,15 +397,20 @@ func (c *Course) GetTotalScoreByStudentCode(code string) int {
 	c.rmu.RLock()
 	defer c.rmu.RUnlock()
 
+	return c.totalScoreByStudentCode(code)
+}
+
+// totalScoreByStudentCode 講義の得点の重み付き合計(小数点以下切り捨て)
+func (c *Course) totalScoreByStudentCode(code string) int {
 	score := 0
 	for _, class := range c.classes {
 		submission := class.GetSubmissionByStudentCode(code)
 		if submission != nil && submission.score != nil {
-			score += *submission.score
+			score += *submission.score * c.classWeight(class.ID)
 		}
 	}
 
-	return score
+	return score / 100
 }
 
 func (c *Course) calcTotalScores() map[string]int {
@@ -344,12 +422,16 @@ func (c *Course) calcTotalScores() map[string]int {
 		res[userCode] = 0
 	}
 	for _, class := range c.classes {
+		weight := c.classWeight(class.ID)
 		for userCode, submission := range class.Submissions() {
 			if submission != nil && submission.score != nil {
-				res[userCode] += *submission.score
+				res[userCode] += *submission.score * weight
 			}
 		}
 	}
+	for userCode := range res {
+		res[userCode] /= 100
+	}
 
 	return res
 }
@@ -1,5 +1,83 @@
 package model
 
+import "github.com/isucon/isucon11-final/benchmarker/api"
+
+// defaultClassWeight 講義の重みの既定値(百分率)
+const defaultClassWeight = 100
+
+var letterGradePoints = map[string]int{
+	"S": 4,
+	"A": 3,
+	"B": 2,
+	"C": 1,
+	"F": 0,
+}
+
+// GradingPolicy 科目の成績評価の方針
+type GradingPolicy struct {
+	Type      api.GradingPolicyType
+	PassScore int
+	CutoffS   int
+	CutoffA   int
+	CutoffB   int
+	CutoffC   int
+}
+
+// DefaultGradingPolicy 方針が未設定の科目の方針。総合点/100をGPとし、全員が単位を修得する
+func DefaultGradingPolicy() GradingPolicy {
+	return GradingPolicy{Type: api.GradingScore}
+}
+
+// CourseGrade 終了した科目の成績
+type CourseGrade struct {
+	Grade               string
+	Credits             int // 修得した単位数
+	GPACredits          int // GPAの対象となる単位数
+	WeightedGradePoints int // GP×100×単位数
+}
+
+func (p GradingPolicy) Grade(totalScore int, credit int) CourseGrade {
+	switch p.Type {
+	case api.GradingLetter:
+		var grade string
+		switch {
+		case totalScore >= p.CutoffS:
+			grade = "S"
+		case totalScore >= p.CutoffA:
+			grade = "A"
+		case totalScore >= p.CutoffB:
+			grade = "B"
+		case totalScore >= p.CutoffC:
+			grade = "C"
+		default:
+			grade = "F"
+		}
+		res := CourseGrade{
+			Grade:               grade,
+			GPACredits:          credit,
+			WeightedGradePoints: letterGradePoints[grade] * 100 * credit,
+		}
+		if grade != "F" {
+			res.Credits = credit
+		}
+		return res
+	case api.GradingPassFail:
+		if totalScore >= p.PassScore {
+			return CourseGrade{Grade: "P", Credits: credit}
+		}
+		return CourseGrade{Grade: "F"}
+	default:
+		res := CourseGrade{
+			GPACredits:          credit,
+			WeightedGradePoints: totalScore * credit,
+		}
+		if totalScore >= p.PassScore {
+			res.Credits = credit
+		}
+		return res
+	}
+}
+
 // CourseResultのうち計算しなくていいやつ
 type SimpleCourseResult struct {
 	Name              string // course name
@@ -54,6 +132,7 @@ type CourseResult struct {
 	TotalScoreAvg    float64 // 平均値
 	TotalScoreMax    int     // 最大値
 	TotalScoreMin    int     // 最小値
+	Grade            string  // 科目が終了していない場合は空文字
 	ClassScores      []*ClassScore
 }
 
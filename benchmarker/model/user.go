@@ -76,6 +76,18 @@ func (s *Student) AddCourse(course *Course) {
 	s.registeredCourses = append(s.registeredCourses, course)
 }
 
+func (s *Student) RemoveCourse(course *Course) {
+	s.rmu.Lock()
+	defer s.rmu.Unlock()
+
+	for i, c := range s.registeredCourses {
+		if c == course {
+			s.registeredCourses = append(s.registeredCourses[:i], s.registeredCourses[i+1:]...)
+			break
+		}
+	}
+}
+
 func (s *Student) Announcements() []*AnnouncementStatus {
 	s.rmu.Lock()
 	defer s.rmu.Unlock()
@@ -331,35 +343,50 @@ func (s *Student) waitReleaseTimeslot(registerCourseLimit int) <-chan struct{} {
 	return ch
 }
 
-func (s *Student) GPA() float64 {
+// grades 終了した科目の成績一覧
+func (s *Student) grades() []*CourseGrade {
 	s.rmu.RLock()
 	defer s.rmu.RUnlock()
 
-	tmp := 0
+	res := make([]*CourseGrade, 0, len(s.registeredCourses))
 	for _, course := range s.registeredCourses {
-		if course.Status() == api.StatusClosed {
-			tmp += course.GetTotalScoreByStudentCode(s.Code) * course.Credit
+		if grade := course.Grade(s.Code); grade != nil {
+			res = append(res, grade)
 		}
 	}
 
-	gpt := float64(tmp) / 100.0
-	credits := s.TotalCredit()
+	return res
+}
+
+func (s *Student) GPA() float64 {
+	tmp := 0
+	credits := 0
+	for _, grade := range s.grades() {
+		tmp += grade.WeightedGradePoints
+		credits += grade.GPACredits
+	}
 
 	if credits == 0 {
 		return 0
 	}
-	return gpt / float64(credits)
+	return float64(tmp) / 100.0 / float64(credits)
 }
 
+// TotalCredit 修得した単位数
 func (s *Student) TotalCredit() int {
-	s.rmu.RLock()
-	defer s.rmu.RUnlock()
+	res := 0
+	for _, grade := range s.grades() {
+		res += grade.Credits
+	}
 
+	return res
+}
+
+// GPACredit GPAの対象となる単位数
+func (s *Student) GPACredit() int {
 	res := 0
-	for _, course := range s.registeredCourses {
-		if course.Status() == api.StatusClosed {
-			res += course.Credit
-		}
+	for _, grade := range s.grades() {
+		res += grade.GPACredits
 	}
 
 	return res
@@ -259,6 +259,21 @@ func TakeCoursesAction(ctx context.Context, agent *agent.Agent, courses []*model
 	return hres, eres, nil
 }
 
+func DropCourseAction(ctx context.Context, agent *agent.Agent, course *model.Course) (*http.Response, error) {
+	hres, err := api.UnregisterCourse(ctx, agent, course.ID)
+	if err != nil {
+		return hres, fails.ErrorHTTP(err)
+	}
+	defer hres.Body.Close()
+
+	err = verifyStatusCode(hres, []int{http.StatusOK})
+	if err != nil {
+		return hres, err
+	}
+
+	return hres, nil
+}
+
 func GetAnnouncementListAction(ctx context.Context, agent *agent.Agent, next, courseID string) (*http.Response, api.GetAnnouncementsResponse, error) {
 	res := api.GetAnnouncementsResponse{}
 	if next == "" {
@@ -471,6 +486,21 @@ func DownloadSubmissionsAction(ctx context.Context, agent *agent.Agent, courseID
 	return hres, data, nil
 }
 
+func SetSubmissionStatusAction(ctx context.Context, agent *agent.Agent, courseID, classID string, status api.SetSubmissionStatusRequest) (*http.Response, error) {
+	hres, err := api.SetSubmissionStatus(ctx, agent, courseID, classID, status)
+	if err != nil {
+		return hres, fails.ErrorHTTP(err)
+	}
+	defer hres.Body.Close()
+
+	err = verifyStatusCode(hres, []int{http.StatusOK})
+	if err != nil {
+		return hres, err
+	}
+
+	return hres, nil
+}
+
 func PostGradeAction(ctx context.Context, agent *agent.Agent, courseID, classID string, scores []StudentScore) (*http.Response, error) {
 	req := make([]api.RegisterScoreRequestContent, 0, len(scores))
 	for _, v := range scores {
@@ -516,6 +546,51 @@ func setCourseStatusAction(ctx context.Context, agent *agent.Agent, courseID str
 	return hres, nil
 }
 
+func SetGradingPolicyAction(ctx context.Context, agent *agent.Agent, courseID string, policy api.SetGradingPolicyRequest) (*http.Response, error) {
+	hres, err := api.SetGradingPolicy(ctx, agent, courseID, policy)
+	if err != nil {
+		return hres, fails.ErrorHTTP(err)
+	}
+	defer hres.Body.Close()
+
+	err = verifyStatusCode(hres, []int{http.StatusOK})
+	if err != nil {
+		return hres, err
+	}
+
+	return hres, nil
+}
+
+func SetCourseRequisitesAction(ctx context.Context, agent *agent.Agent, courseID string, requisites api.SetCourseRequisitesRequest) (*http.Response, error) {
+	hres, err := api.SetCourseRequisites(ctx, agent, courseID, requisites)
+	if err != nil {
+		return hres, fails.ErrorHTTP(err)
+	}
+	defer hres.Body.Close()
+
+	err = verifyStatusCode(hres, []int{http.StatusOK})
+	if err != nil {
+		return hres, err
+	}
+
+	return hres, nil
+}
+
+func SetCourseLotteryAction(ctx context.Context, agent *agent.Agent, courseID string, lottery api.SetCourseLotteryRequest) (*http.Response, error) {
+	hres, err := api.SetCourseLottery(ctx, agent, courseID, lottery)
+	if err != nil {
+		return hres, fails.ErrorHTTP(err)
+	}
+	defer hres.Body.Close()
+
+	err = verifyStatusCode(hres, []int{http.StatusOK})
+	if err != nil {
+		return hres, err
+	}
+
+	return hres, nil
+}
+
 func AccessTopPageAction(ctx context.Context, agent *agent.Agent) (*http.Response, agent.Resources, error) {
 	hres, resources, err := api.BrowserAccess(ctx, agent, "")
 	if err != nil {
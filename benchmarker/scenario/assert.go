@@ -178,6 +178,10 @@ func AssertEqualCourseResult(expected *model.CourseResult, actual *api.CourseRes
 		return errMismatch("成績取得の科目の total_score_t_score が期待する値と一致しません", expected.TotalScoreTScore, actual.TotalScoreTScore)
 	}
 
+	if !AssertEqual("grade courses grade", expected.Grade, actual.Grade) {
+		return errMismatch("成績取得の科目の grade が期待する値と一致しません", expected.Grade, actual.Grade)
+	}
+
 	if !AssertEqual("grade courses class_scores length", len(expected.ClassScores), len(actual.ClassScores)) {
 		return errMismatch("成績取得の科目の class_scores の数が期待する値と一致しません", len(expected.ClassScores), len(actual.ClassScores))
 	}
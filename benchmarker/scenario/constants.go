@@ -12,6 +12,8 @@ const (
 	registerCourseLimitPerStudent = 20
 	// StudentCapacityPerCourse は科目あたりの履修定員
 	StudentCapacityPerCourse = 50
+	// dropCourseRate は履修登録に成功した学生がそのうち1科目の履修を取り消す確率
+	dropCourseRate = 0.05
 	// searchCountPerRegistration は履修登録前に実行する科目詳細取得の回数
 	searchCountPerRegistration = 3
 	// ClassCountPerCourse は科目あたりの講義数 -> same const exist in model/course.go
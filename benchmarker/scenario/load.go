@@ -13,6 +13,7 @@ import (
 	"github.com/isucon/isucandar"
 	"github.com/isucon/isucandar/parallel"
 
+	"github.com/isucon/isucon11-final/benchmarker/api"
 	"github.com/isucon/isucon11-final/benchmarker/fails"
 	"github.com/isucon/isucon11-final/benchmarker/generate"
 	"github.com/isucon/isucon11-final/benchmarker/model"
@@ -395,6 +396,11 @@ func (s *Scenario) registrationScenario(student *model.Student, step *isucandar.
 					student.AddCourse(c)
 					c.StartTimer(waitCourseFullTimeout)
 				}
+
+				// 一定確率で登録した科目のうち1つの履修を取り消す
+				if rand.Float64() < dropCourseRate {
+					s.dropCourse(ctx, student, temporaryReservedCourses[rand.Intn(len(temporaryReservedCourses))], step)
+				}
 			}
 
 			DebugLogger.Printf("[履修完了] code: %v, register count: %d", student.Code, len(temporaryReservedCourses))
@@ -402,6 +408,47 @@ func (s *Scenario) registrationScenario(student *model.Student, step *isucandar.
 	}
 }
 
+func (s *Scenario) dropCourse(ctx context.Context, student *model.Student, course *model.Course, step *isucandar.BenchmarkStep) {
+	// 履修締め切り後は取り消せないので、取り消しが終わるまで科目のステータス変更を止めておく
+	if course.ReserveDropIfAvailable(student) != model.Succeeded {
+		return
+	}
+
+	// リトライ後の400はタイムアウトしたリクエストで取り消し済みであることを表す
+	isRetry := false
+	isExtendRequest := false
+L:
+	if s.isNoRetryTime(ctx) {
+		course.RollbackReservation()
+		return
+	}
+	hres, err := DropCourseAction(ctx, student.Agent, course)
+	if err != nil && !(isRetry && hres != nil && hres.StatusCode == http.StatusBadRequest) {
+		if !isExtendRequest {
+			step.AddError(err)
+		}
+		if fails.IsTimeout(err) {
+			ContestantLogger.Printf("履修取り消し(DELETE /api/users/me/courses/:courseID)がタイムアウトしました。学生はリトライを試みます。")
+			time.Sleep(100 * time.Millisecond)
+			isRetry = true
+			isExtendRequest = s.isNoRequestTime(ctx)
+			goto L
+		}
+		course.RollbackReservation()
+		return
+	}
+	if !isExtendRequest {
+		step.AddScore(score.RegDropCourse)
+	}
+
+	course.CommitDrop(student)
+	student.RemoveCourse(course)
+	student.ReleaseTimeslot(course.DayOfWeek, course.Period)
+	s.CapacityCounter.Inc(course.DayOfWeek, course.Period)
+
+	DebugLogger.Printf("[履修取り消し] code: %v, course: %v", student.Code, course.ID)
+}
+
 func (s *Scenario) readAnnouncementScenario(student *model.Student, step *isucandar.BenchmarkStep) func(ctx context.Context) {
 	return func(ctx context.Context) {
 		var nextPathParam string // 次にアクセスするお知らせ一覧のページ
@@ -499,6 +546,7 @@ func (s *Scenario) readAnnouncementScenario(student *model.Student, step *isucan
 func (s *Scenario) readAnnouncementPagingScenario(student *model.Student, step *isucandar.BenchmarkStep) func(ctx context.Context) {
 	return func(ctx context.Context) {
 		var nextPathParam string // 次にアクセスするお知らせ一覧のページ
+		var prevLastID string    // 前のページの最後のお知らせのID
 		for ctx.Err() == nil {
 			timer := time.After(50 * time.Millisecond)
 
@@ -521,10 +569,15 @@ func (s *Scenario) readAnnouncementPagingScenario(student *model.Student, step *
 			// 並列で走る既読にするシナリオが未読/既読状態を変更するので、こちらのシナリオでは未読/既読状態は検証しない
 			if err := verifyAnnouncementsList(expectAnnouncementMap, &res, hres, false); err != nil {
 				step.AddError(err)
+			} else if err := verifyAnnouncementsNextPage(nextPathParam, prevLastID, &res, hres); err != nil {
+				step.AddError(err)
 			} else {
 				step.AddScore(score.ScoreGetAnnouncementList)
 				step.AddScore(score.PagingGetAnnouncementList)
 			}
+			if len(res.Announcements) > 0 {
+				prevLastID = res.Announcements[len(res.Announcements)-1].ID
+			}
 
 			// このページ内で既に詳細取得リクエストを送ったおしらせを集める
 			var readAnnouncementsID []string
@@ -735,6 +788,23 @@ func (s *Scenario) courseScenario(course *model.Course, step *isucandar.Benchmar
 				return
 			}
 
+			isExtendRequest = false
+		closeLoop:
+			if s.isNoRetryTime(ctx) {
+				return
+			}
+			_, err = SetSubmissionStatusAction(ctx, teacher.Agent, course.ID, class.ID, api.SetSubmissionStatusRequest{Closed: true})
+			if err != nil {
+				if !isExtendRequest {
+					step.AddError(err)
+				}
+				ContestantLogger.Printf("課題の提出締め切り(PUT /api/courses/:courseID/classes/:classID/submission-status)がタイムアウトまたは失敗しました。教員はリトライを試みます。")
+				time.Sleep(100 * time.Millisecond)
+				isExtendRequest = s.isNoRequestTime(ctx)
+				goto closeLoop
+			}
+			class.CloseSubmission()
+
 			isExtendRequest = false
 		downloadLoop:
 			if s.isNoRetryTime(ctx) {
@@ -750,7 +820,6 @@ func (s *Scenario) courseScenario(course *model.Course, step *isucandar.Benchmar
 				isExtendRequest = s.isNoRequestTime(ctx)
 				goto downloadLoop
 			}
-			class.CloseSubmission()
 
 			if err := verifyAssignments(assignmentsData, class, false, hres); err != nil {
 				step.AddError(err)
@@ -7,6 +7,7 @@ import (
 	"math/rand"
 	"net/http"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -349,13 +350,20 @@ func (s *Scenario) prepareNormal(ctx context.Context, step *isucandar.BenchmarkS
 				return
 			}
 
+			// 課題の提出締め切り
+			_, err = SetSubmissionStatusAction(ctx, teacher.Agent, course.ID, class.ID, api.SetSubmissionStatusRequest{Closed: true})
+			if err != nil {
+				step.AddError(err)
+				return
+			}
+			class.CloseSubmission()
+
 			// 課題ダウンロード
 			hres, assignmentsData, err := DownloadSubmissionsAction(ctx, teacher.Agent, course.ID, class.ID)
 			if err != nil {
 				step.AddError(err)
 				return
 			}
-			class.CloseSubmission()
 
 			if err := verifyAssignments(assignmentsData, class, true, hres); err != nil {
 				step.AddError(err)
@@ -1179,6 +1187,11 @@ func (s *Scenario) prepareAbnormal(ctx context.Context) error {
 		return err
 	}
 
+	// DELETE /api/users/me/courses/:courseID
+	if err := s.prepareCheckDropCourseAbnormal(ctx); err != nil {
+		return err
+	}
+
 	// GET /api/courses/:courseID
 	if err := s.prepareCheckGetCourseDetailAbnormal(ctx); err != nil {
 		return err
@@ -1293,7 +1306,7 @@ func (s *Scenario) prepareCheckAuthenticationAbnormal(ctx context.Context) error
 		return err
 	}
 	submissionClosedClass := model.NewClass(addClassRes.ClassID, classParam)
-	_, _, err = DownloadSubmissionsAction(ctx, teacher.Agent, course.ID, submissionClosedClass.ID)
+	_, err = SetSubmissionStatusAction(ctx, teacher.Agent, course.ID, submissionClosedClass.ID, api.SetSubmissionStatusRequest{Closed: true})
 	if err != nil {
 		return err
 	}
@@ -1331,6 +1344,11 @@ func (s *Scenario) prepareCheckAuthenticationAbnormal(ctx context.Context) error
 		return err
 	}
 
+	hres, err = DropCourseAction(ctx, agent, course)
+	if err := checkAuthentication(hres, err); err != nil {
+		return err
+	}
+
 	hres, _, err = GetGradeAction(ctx, agent)
 	if err := checkAuthentication(hres, err); err != nil {
 		return err
@@ -1414,6 +1432,9 @@ func (s *Scenario) prepareCheckAdminAuthorizationAbnormal(ctx context.Context) e
 	errAuthorization := func(hres *http.Response) error {
 		return fails.ErrorInvalidResponse(errors.New("学生ユーザで教員用APIへのアクセスが成功しました"), hres)
 	}
+	errOwnership := func(hres *http.Response) error {
+		return fails.ErrorInvalidResponse(errors.New("担当ではない教員ユーザで科目を操作するAPIへのアクセスが成功しました"), hres)
+	}
 	checkAuthorization := func(hres *http.Response, err error) error {
 		// リクエストが成功したらwebappの不具合
 		if err == nil {
@@ -1427,6 +1448,19 @@ func (s *Scenario) prepareCheckAdminAuthorizationAbnormal(ctx context.Context) e
 
 		return nil
 	}
+	checkOwnership := func(hres *http.Response, err error) error {
+		// リクエストが成功したらwebappの不具合
+		if err == nil {
+			return errOwnership(hres)
+		}
+
+		// ステータスコードのチェック
+		if err := verifyStatusCode(hres, []int{http.StatusForbidden}); err != nil {
+			return err
+		}
+
+		return nil
+	}
 
 	// ======== 検証用データの準備 ========
 
@@ -1462,7 +1496,7 @@ func (s *Scenario) prepareCheckAdminAuthorizationAbnormal(ctx context.Context) e
 		return err
 	}
 	submissionClosedClass := model.NewClass(addClassRes.ClassID, classParam)
-	_, _, err = DownloadSubmissionsAction(ctx, teacher.Agent, course.ID, submissionClosedClass.ID)
+	_, err = SetSubmissionStatusAction(ctx, teacher.Agent, course.ID, submissionClosedClass.ID, api.SetSubmissionStatusRequest{Closed: true})
 	if err != nil {
 		return err
 	}
@@ -1476,6 +1510,18 @@ func (s *Scenario) prepareCheckAdminAuthorizationAbnormal(ctx context.Context) e
 	}
 	submissionNotClosedClass := model.NewClass(addClassRes.ClassID, classParam)
 
+	// 科目の担当ではない教員ユーザ
+	otherTeacher, err := s.getLoggedInTeacher(ctx)
+	if err != nil {
+		return err
+	}
+	for otherTeacher.ID == teacher.ID {
+		otherTeacher, err = s.getLoggedInTeacher(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
 	// ======== 検証 ========
 
 	courseParam = generate.CourseParam(0, 1, teacher)
@@ -1511,12 +1557,87 @@ func (s *Scenario) prepareCheckAdminAuthorizationAbnormal(ctx context.Context) e
 		return err
 	}
 
+	submissionStatus := api.SetSubmissionStatusRequest{Closed: true}
+	hres, err = SetSubmissionStatusAction(ctx, student.Agent, course.ID, submissionNotClosedClass.ID, submissionStatus)
+	if err := checkAuthorization(hres, err); err != nil {
+		return err
+	}
+
 	announcement := generate.Announcement(course, submissionNotClosedClass)
 	hres, err = SendAnnouncementAction(ctx, student.Agent, announcement)
 	if err := checkAuthorization(hres, err); err != nil {
 		return err
 	}
 
+	gradingPolicy := api.SetGradingPolicyRequest{
+		Type:      api.GradingPassFail,
+		PassScore: 60,
+	}
+	hres, err = SetGradingPolicyAction(ctx, student.Agent, course.ID, gradingPolicy)
+	if err := checkAuthorization(hres, err); err != nil {
+		return err
+	}
+
+	requisites := api.SetCourseRequisitesRequest{}
+	hres, err = SetCourseRequisitesAction(ctx, student.Agent, course.ID, requisites)
+	if err := checkAuthorization(hres, err); err != nil {
+		return err
+	}
+
+	lottery := api.SetCourseLotteryRequest{Enabled: false}
+	hres, err = SetCourseLotteryAction(ctx, student.Agent, course.ID, lottery)
+	if err := checkAuthorization(hres, err); err != nil {
+		return err
+	}
+
+	// 担当ではない教員ユーザでの科目操作
+	hres, err = SetCourseStatusClosedAction(ctx, otherTeacher.Agent, course.ID)
+	if err := checkOwnership(hres, err); err != nil {
+		return err
+	}
+
+	classParam = generate.ClassParam(course, 3)
+	hres, _, err = AddClassAction(ctx, otherTeacher.Agent, course, classParam)
+	if err := checkOwnership(hres, err); err != nil {
+		return err
+	}
+
+	hres, err = PostGradeAction(ctx, otherTeacher.Agent, course.ID, submissionClosedClass.ID, scores)
+	if err := checkOwnership(hres, err); err != nil {
+		return err
+	}
+
+	hres, _, err = DownloadSubmissionsAction(ctx, otherTeacher.Agent, course.ID, submissionNotClosedClass.ID)
+	if err := checkOwnership(hres, err); err != nil {
+		return err
+	}
+
+	hres, err = SetSubmissionStatusAction(ctx, otherTeacher.Agent, course.ID, submissionNotClosedClass.ID, submissionStatus)
+	if err := checkOwnership(hres, err); err != nil {
+		return err
+	}
+
+	announcement = generate.Announcement(course, submissionNotClosedClass)
+	hres, err = SendAnnouncementAction(ctx, otherTeacher.Agent, announcement)
+	if err := checkOwnership(hres, err); err != nil {
+		return err
+	}
+
+	hres, err = SetGradingPolicyAction(ctx, otherTeacher.Agent, course.ID, gradingPolicy)
+	if err := checkOwnership(hres, err); err != nil {
+		return err
+	}
+
+	hres, err = SetCourseRequisitesAction(ctx, otherTeacher.Agent, course.ID, requisites)
+	if err := checkOwnership(hres, err); err != nil {
+		return err
+	}
+
+	hres, err = SetCourseLotteryAction(ctx, otherTeacher.Agent, course.ID, lottery)
+	if err := checkOwnership(hres, err); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -1527,6 +1648,15 @@ func (s *Scenario) prepareCheckLoginAbnormal(ctx context.Context) error {
 	errRelogin := func(hres *http.Response) error {
 		return fails.ErrorInvalidResponse(errors.New("ログイン状態での再ログインが成功しました"), hres)
 	}
+	errLockedOutLogin := func(hres *http.Response) error {
+		return fails.ErrorInvalidResponse(errors.New("ロックアウトされたユーザでのログインが成功しました"), hres)
+	}
+	errInvalidRetryAfter := func(hres *http.Response) error {
+		return fails.ErrorInvalidResponse(errors.New("ロックアウト時のレスポンスの Retry-After ヘッダが不正です"), hres)
+	}
+
+	// ロックアウトされるまでに許容される連続したログイン失敗の回数
+	const loginLockoutThreshold = 5
 
 	// ======== 検証用データの準備 ========
 
@@ -1536,6 +1666,12 @@ func (s *Scenario) prepareCheckLoginAbnormal(ctx context.Context) error {
 		panic("unreachable! studentPool is empty")
 	}
 
+	// ロックアウトの検証で使用する学生ユーザ（未ログイン状態）
+	lockedOutStudent, err := s.userPool.newStudent()
+	if err != nil {
+		panic("unreachable! studentPool is empty")
+	}
+
 	// ======== 検証 ========
 
 	// 存在しないユーザでのログイン
@@ -1579,6 +1715,34 @@ func (s *Scenario) prepareCheckLoginAbnormal(ctx context.Context) error {
 		return err
 	}
 
+	// 間違ったパスワードでのログインを繰り返す
+	for i := 0; i < loginLockoutThreshold; i++ {
+		hres, err = LoginAction(ctx, lockedOutStudent.Agent, &model.UserAccount{
+			Code:        lockedOutStudent.Code,
+			RawPassword: lockedOutStudent.RawPassword + "abc",
+			IsAdmin:     false,
+		})
+		if err == nil {
+			return errInvalidLogin(hres)
+		}
+		if err := verifyStatusCode(hres, []int{http.StatusUnauthorized}); err != nil {
+			return err
+		}
+	}
+
+	// ロックアウト中は正しいパスワードでもログインできない
+	hres, err = LoginAction(ctx, lockedOutStudent.Agent, lockedOutStudent.UserAccount)
+	if err == nil {
+		return errLockedOutLogin(hres)
+	}
+	if err := verifyStatusCode(hres, []int{http.StatusTooManyRequests}); err != nil {
+		return err
+	}
+	retryAfter, err := strconv.Atoi(hres.Header.Get("Retry-After"))
+	if err != nil || retryAfter <= 0 {
+		return errInvalidRetryAfter(hres)
+	}
+
 	return nil
 }
 
@@ -1632,6 +1796,10 @@ func (s *Scenario) prepareCheckRegisterCoursesAbnormal(ctx context.Context) erro
 		return err
 	}
 	closedCourse := model.NewCourse(courseParam, addCourseRes.ID, teacher, prepareCourseCapacity, model.NewCapacityCounter())
+	_, err = SetCourseStatusInProgressAction(ctx, teacher.Agent, closedCourse.ID)
+	if err != nil {
+		return err
+	}
 	_, err = SetCourseStatusClosedAction(ctx, teacher.Agent, closedCourse.ID)
 	if err != nil {
 		return err
@@ -1718,13 +1886,108 @@ func (s *Scenario) prepareCheckRegisterCoursesAbnormal(ctx context.Context) erro
 
 	if !isSameIgnoringOrder(eres.CourseNotFound, []string{unknownCourse.ID}) ||
 		!isSameIgnoringOrder(eres.NotRegistrableStatus, []string{inProgressCourse.ID, closedCourse.ID}) ||
-		!isSameIgnoringOrder(eres.ScheduleConflict, []string{conflictedCourse1.ID, conflictedCourse2.ID, conflictedCourse3.ID}) {
+		!isSameIgnoringOrder(eres.ScheduleConflict, []string{conflictedCourse1.ID, conflictedCourse2.ID, conflictedCourse3.ID}) ||
+		len(eres.CapacityExceeded) != 0 {
 		return errInvalidErrorResponse(hres)
 	}
 
 	return nil
 }
 
+func (s *Scenario) prepareCheckDropCourseAbnormal(ctx context.Context) error {
+	errDropUnknownCourse := func(hres *http.Response) error {
+		return fails.ErrorInvalidResponse(errors.New("存在しない科目の履修取り消しが成功しました"), hres)
+	}
+	errDropNotRegisteredCourse := func(hres *http.Response) error {
+		return fails.ErrorInvalidResponse(errors.New("履修していない科目の履修取り消しが成功しました"), hres)
+	}
+	errDropNotRegistrationCourse := func(hres *http.Response) error {
+		return fails.ErrorInvalidResponse(errors.New("履修登録期間外の科目の履修取り消しが成功しました"), hres)
+	}
+
+	// ======== 検証用データの準備 ========
+
+	// 検証で使用する学生ユーザ
+	student, err := s.getLoggedInStudent(ctx)
+	if err != nil {
+		return err
+	}
+
+	// 検証で使用する教員ユーザ
+	teacher, err := s.getLoggedInTeacher(ctx)
+	if err != nil {
+		return err
+	}
+
+	// student が履修登録後に履修を取り消した科目
+	courseParam := generate.CourseParam(0, 0, teacher)
+	_, addCourseRes, err := AddCourseAction(ctx, teacher.Agent, courseParam)
+	if err != nil {
+		return err
+	}
+	droppedCourse := model.NewCourse(courseParam, addCourseRes.ID, teacher, prepareCourseCapacity, model.NewCapacityCounter())
+	_, _, err = TakeCoursesAction(ctx, student.Agent, []*model.Course{droppedCourse})
+	if err != nil {
+		return err
+	}
+	_, err = DropCourseAction(ctx, student.Agent, droppedCourse)
+	if err != nil {
+		return err
+	}
+
+	// student が履修登録済みで、in-progressの科目
+	courseParam = generate.CourseParam(0, 1, teacher)
+	_, addCourseRes, err = AddCourseAction(ctx, teacher.Agent, courseParam)
+	if err != nil {
+		return err
+	}
+	inProgressCourse := model.NewCourse(courseParam, addCourseRes.ID, teacher, prepareCourseCapacity, model.NewCapacityCounter())
+	_, _, err = TakeCoursesAction(ctx, student.Agent, []*model.Course{inProgressCourse})
+	if err != nil {
+		return err
+	}
+	_, err = SetCourseStatusInProgressAction(ctx, teacher.Agent, inProgressCourse.ID)
+	if err != nil {
+		return err
+	}
+	inProgressCourse.SetStatusToInProgress()
+
+	// 存在しない科目
+	courseParam = generate.CourseParam(0, 2, teacher)
+	unknownCourse := model.NewCourse(courseParam, generate.GenULID(), teacher, prepareCourseCapacity, model.NewCapacityCounter())
+
+	// ======== 検証 ========
+
+	// 存在しない科目IDでの履修取り消し
+	hres, err := DropCourseAction(ctx, student.Agent, unknownCourse)
+	if err == nil {
+		return errDropUnknownCourse(hres)
+	}
+	if err := verifyStatusCode(hres, []int{http.StatusNotFound}); err != nil {
+		return err
+	}
+
+	// 取り消し済みの科目の再度の履修取り消し
+	hres, err = DropCourseAction(ctx, student.Agent, droppedCourse)
+	if err == nil {
+		return errDropNotRegisteredCourse(hres)
+	}
+	if err := verifyStatusCode(hres, []int{http.StatusBadRequest}); err != nil {
+		return err
+	}
+
+	// in-progress の科目の履修取り消し
+	hres, err = DropCourseAction(ctx, student.Agent, inProgressCourse)
+	if err == nil {
+		return errDropNotRegistrationCourse(hres)
+	}
+	if err := verifyStatusCode(hres, []int{http.StatusBadRequest}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (s *Scenario) prepareCheckGetCourseDetailAbnormal(ctx context.Context) error {
 	errGetUnknownCourseDetail := func(hres *http.Response) error {
 		return fails.ErrorInvalidResponse(errors.New("存在しない科目の詳細取得が成功しました"), hres)
@@ -1822,6 +2085,12 @@ func (s *Scenario) prepareCheckSetCourseStatusAbnormal(ctx context.Context) erro
 	errSetStatusForUnknownCourse := func(hres *http.Response) error {
 		return fails.ErrorInvalidResponse(errors.New("存在しない科目のステータス変更が成功しました"), hres)
 	}
+	errSetInvalidStatus := func(hres *http.Response) error {
+		return fails.ErrorInvalidResponse(errors.New("不正なステータスへの科目のステータス変更が成功しました"), hres)
+	}
+	errSetStatusInvalidTransition := func(hres *http.Response) error {
+		return fails.ErrorInvalidResponse(errors.New("許可されていない科目のステータス遷移が成功しました"), hres)
+	}
 
 	// ======== 検証用データの準備 ========
 
@@ -1831,10 +2100,62 @@ func (s *Scenario) prepareCheckSetCourseStatusAbnormal(ctx context.Context) erro
 		return err
 	}
 
+	// ステータスが registration の科目
+	courseParam := generate.CourseParam(0, 0, teacher)
+	_, addCourseRes, err := AddCourseAction(ctx, teacher.Agent, courseParam)
+	if err != nil {
+		return err
+	}
+	registrationCourse := model.NewCourse(courseParam, addCourseRes.ID, teacher, prepareCourseCapacity, model.NewCapacityCounter())
+
+	// ステータスが closed の科目
+	courseParam = generate.CourseParam(0, 1, teacher)
+	_, addCourseRes, err = AddCourseAction(ctx, teacher.Agent, courseParam)
+	if err != nil {
+		return err
+	}
+	closedCourse := model.NewCourse(courseParam, addCourseRes.ID, teacher, prepareCourseCapacity, model.NewCapacityCounter())
+	_, err = SetCourseStatusInProgressAction(ctx, teacher.Agent, closedCourse.ID)
+	if err != nil {
+		return err
+	}
+	_, err = SetCourseStatusClosedAction(ctx, teacher.Agent, closedCourse.ID)
+	if err != nil {
+		return err
+	}
+	closedCourse.SetStatusToClosed()
+
 	// ======== 検証 ========
 
+	// 不正な文字列でのステータス変更
+	hres, err := setCourseStatusAction(ctx, teacher.Agent, registrationCourse.ID, "invalid-status")
+	if err == nil {
+		return errSetInvalidStatus(hres)
+	}
+	if err := verifyStatusCode(hres, []int{http.StatusBadRequest}); err != nil {
+		return err
+	}
+
+	// registration から closed への遷移
+	hres, err = SetCourseStatusClosedAction(ctx, teacher.Agent, registrationCourse.ID)
+	if err == nil {
+		return errSetStatusInvalidTransition(hres)
+	}
+	if err := verifyStatusCode(hres, []int{http.StatusConflict}); err != nil {
+		return err
+	}
+
+	// closed から in-progress への遷移
+	hres, err = SetCourseStatusInProgressAction(ctx, teacher.Agent, closedCourse.ID)
+	if err == nil {
+		return errSetStatusInvalidTransition(hres)
+	}
+	if err := verifyStatusCode(hres, []int{http.StatusConflict}); err != nil {
+		return err
+	}
+
 	// 存在しない科目IDでの科目ステータス変更
-	hres, err := SetCourseStatusInProgressAction(ctx, teacher.Agent, generate.GenULID())
+	hres, err = SetCourseStatusInProgressAction(ctx, teacher.Agent, generate.GenULID())
 	if err == nil {
 		return errSetStatusForUnknownCourse(hres)
 	}
@@ -1842,8 +2163,6 @@ func (s *Scenario) prepareCheckSetCourseStatusAbnormal(ctx context.Context) erro
 		return err
 	}
 
-	// ※ 不正な文字列(in-progress, etc... 以外)を投げると500になるので検証しない
-
 	return nil
 }
 
@@ -1989,6 +2308,9 @@ func (s *Scenario) prepareCheckSubmitAssignmentAbnormal(ctx context.Context) err
 	errSubmitAssignmentForNotRegisteredCourse := func(hres *http.Response) error {
 		return fails.ErrorInvalidResponse(errors.New("履修していない科目の講義への課題提出が成功しました"), hres)
 	}
+	errSubmitAssignmentForClassOfOtherCourse := func(hres *http.Response) error {
+		return fails.ErrorInvalidResponse(errors.New("指定した科目に属さない講義への課題提出が成功しました"), hres)
+	}
 	errSubmitAssignmentForSubmissionClosedClass := func(hres *http.Response) error {
 		return fails.ErrorInvalidResponse(errors.New("課題提出が締め切られた講義への課題提出が成功しました"), hres)
 	}
@@ -2047,7 +2369,7 @@ func (s *Scenario) prepareCheckSubmitAssignmentAbnormal(ctx context.Context) err
 		return err
 	}
 	submissionClosedClass := model.NewClass(addClassRes.ClassID, classParam)
-	_, _, err = DownloadSubmissionsAction(ctx, teacher.Agent, inProgressCourse.ID, submissionClosedClass.ID)
+	_, err = SetSubmissionStatusAction(ctx, teacher.Agent, inProgressCourse.ID, submissionClosedClass.ID, api.SetSubmissionStatusRequest{Closed: true})
 	if err != nil {
 		return err
 	}
@@ -2091,6 +2413,15 @@ func (s *Scenario) prepareCheckSubmitAssignmentAbnormal(ctx context.Context) err
 		return err
 	}
 
+	// 履修済みの科目IDと別の科目の講義IDでの課題提出
+	hres, err = SubmitAssignmentAction(ctx, student.Agent, inProgressCourse.ID, submissionNotClosedClassOfNotRegisteredCourse.ID, fileName, submissionData)
+	if err == nil {
+		return errSubmitAssignmentForClassOfOtherCourse(hres)
+	}
+	if err := verifyStatusCode(hres, []int{http.StatusNotFound}); err != nil {
+		return err
+	}
+
 	// 履修していない科目への課題提出
 	hres, err = SubmitAssignmentAction(ctx, student.Agent, notRegisteredCourse.ID, submissionNotClosedClassOfNotRegisteredCourse.ID, fileName, submissionData)
 	if err == nil {
@@ -2146,6 +2477,9 @@ func (s *Scenario) prepareCheckPostGradeAbnormal(ctx context.Context) error {
 	errPostGradeForSubmissionNotClosedClass := func(hres *http.Response) error {
 		return fails.ErrorInvalidResponse(errors.New("課題提出が締め切られていない講義への採点結果登録が成功しました"), hres)
 	}
+	errPostGradeForClassOfOtherCourse := func(hres *http.Response) error {
+		return fails.ErrorInvalidResponse(errors.New("指定した科目に属さない講義への採点結果登録が成功しました"), hres)
+	}
 
 	// ======== 検証用データの準備 ========
 
@@ -2182,6 +2516,32 @@ func (s *Scenario) prepareCheckPostGradeAbnormal(ctx context.Context) error {
 	}
 	submissionNotClosedClass := model.NewClass(addClassRes.ClassID, classParam)
 
+	// course とは別の科目
+	courseParam = generate.CourseParam(0, 1, teacher)
+	_, addCourseRes, err = AddCourseAction(ctx, teacher.Agent, courseParam)
+	if err != nil {
+		return err
+	}
+	otherCourse := model.NewCourse(courseParam, addCourseRes.ID, teacher, prepareCourseCapacity, model.NewCapacityCounter())
+	_, err = SetCourseStatusInProgressAction(ctx, teacher.Agent, otherCourse.ID)
+	if err != nil {
+		return err
+	}
+	otherCourse.SetStatusToInProgress()
+
+	// otherCourse の課題提出が締め切られた講義
+	classParam = generate.ClassParam(otherCourse, 1)
+	_, addClassRes, err = AddClassAction(ctx, teacher.Agent, otherCourse, classParam)
+	if err != nil {
+		return err
+	}
+	submissionClosedClassOfOtherCourse := model.NewClass(addClassRes.ClassID, classParam)
+	_, err = SetSubmissionStatusAction(ctx, teacher.Agent, otherCourse.ID, submissionClosedClassOfOtherCourse.ID, api.SetSubmissionStatusRequest{Closed: true})
+	if err != nil {
+		return err
+	}
+	submissionClosedClassOfOtherCourse.CloseSubmission()
+
 	// ======== 検証 ========
 
 	scores := []StudentScore{
@@ -2200,6 +2560,15 @@ func (s *Scenario) prepareCheckPostGradeAbnormal(ctx context.Context) error {
 		return err
 	}
 
+	// 別の科目の講義IDでの採点結果登録
+	hres, err = PostGradeAction(ctx, teacher.Agent, course.ID, submissionClosedClassOfOtherCourse.ID, scores)
+	if err == nil {
+		return errPostGradeForClassOfOtherCourse(hres)
+	}
+	if err := verifyStatusCode(hres, []int{http.StatusNotFound}); err != nil {
+		return err
+	}
+
 	// 課題提出が締め切られていない講義の採点結果登録
 	hres, err = PostGradeAction(ctx, teacher.Agent, course.ID, submissionNotClosedClass.ID, scores)
 	if err == nil {
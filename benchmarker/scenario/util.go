@@ -37,6 +37,10 @@ func parseLinkHeader(hres *http.Response) (prev string, next string, err error)
 			if u.Scheme != "" || u.Host != "" {
 				return "", "", failure.NewError(fails.ErrApplication, fmt.Errorf("link header に scheme, host, もしくは port は設定できません"))
 			}
+			// ページの移動はカーソルで行う
+			if u.Query().Get("cursor") == "" {
+				return "", "", failure.NewError(fails.ErrApplication, fmt.Errorf("link header の URL に cursor が含まれていません"))
+			}
 			var s string
 			if u.RawQuery != "" {
 				s = u.Path + "?" + u.RawQuery
@@ -431,7 +431,8 @@ func calculateSummary(students map[string]*model.Student, userCode string) model
 
 	gpas := make([]float64, 0, n)
 	for _, student := range students {
-		if student.HasFinishedCourse() {
+		// pass_fail の科目のみを終了した学生はGPAの統計に含めない
+		if student.GPACredit() > 0 {
 			gpas = append(gpas, student.GPA())
 		}
 	}
@@ -301,6 +301,19 @@ func verifyAnnouncementsList(expectedMap map[string]*model.AnnouncementStatus, r
 	return nil
 }
 
+// verifyAnnouncementsNextPage はカーソルで次のページに進んだお知らせ一覧が前のページの続きになっているかを検証する
+// カーソルでのページングでは並行してお知らせが追加されてもページ間で重複しない
+func verifyAnnouncementsNextPage(pathParam string, prevLastID string, res *api.GetAnnouncementsResponse, hres *http.Response) error {
+	// 最初のページは検証しない
+	if pathParam == "" || prevLastID == "" {
+		return nil
+	}
+	if len(res.Announcements) > 0 && res.Announcements[0].ID >= prevLastID {
+		return fails.ErrorInvalidResponse(errors.New("お知らせ一覧の次のページに前のページまでのお知らせが含まれています"), hres)
+	}
+	return nil
+}
+
 func verifyClasses(expected []*model.Class, res []*api.GetClassResponse, student *model.Student, hres *http.Response) error {
 	if !AssertEqual("class_list length", len(expected), len(res)) {
 		return fails.ErrorInvalidResponse(errors.New("講義数が期待する数と一致しません"), hres)
@@ -334,7 +347,12 @@ func verifyAssignments(assignmentsData []byte, class *model.Class, mustVerify bo
 			if err != nil {
 				return fails.ErrorInvalidResponse(errors.New("課題zipのデータ読み込みに失敗しました"), hres)
 			}
-			downloadedAssignments[f.Name] = crc32.ChecksumIEEE(assignmentData)
+			checksum := crc32.ChecksumIEEE(assignmentData)
+			// ストリーミングで書き出された zip ではデータディスクリプタの CRC32 が中央ディレクトリに記録される
+			if f.CRC32 != checksum {
+				return fails.ErrorInvalidResponse(errors.New("課題zipに記録されたCRC32がファイルの内容と一致しません"), hres)
+			}
+			downloadedAssignments[f.Name] = checksum
 		}
 
 		expectedSubmissions := class.Submissions()
@@ -33,6 +33,7 @@ const (
 	RegGetRegisteredCourses         score.ScoreTag = "_R5.GetRegisteredCourses"
 	RegRegisterCourses              score.ScoreTag = "_R6.RegisterCourses"
 	RegRegisterCourseStudents       score.ScoreTag = "_R7.RegisterCourseStudents"
+	RegDropCourse                   score.ScoreTag = "_R8.DropCourse"
 
 	// read announcement scenario
 	UnreadGetAnnouncementList   score.ScoreTag = "_U1.GetAnnouncementList"
@@ -84,6 +85,7 @@ var Tags = []score.ScoreTag{
 	RegGetRegisteredCourses,
 	RegRegisterCourses,
 	RegRegisterCourseStudents,
+	RegDropCourse,
 
 	// read announcement scenario
 	UnreadGetAnnouncementList,
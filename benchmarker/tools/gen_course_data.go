@@ -15,6 +15,8 @@ import (
 const (
 	courseCount  = 30
 	teacherCount = 10
+	// semesterID 初期科目を開講する学期
+	semesterID = "01FF6J8XFQM9S346Q3D25VT4F5"
 )
 
 func main() {
@@ -134,7 +136,7 @@ func saveSql(courses []*model.Course, fileName string) {
 
 	sqlValues := make([]string, 0, len(courses))
 	for _, course := range courses {
-		sqlValues = append(sqlValues, fmt.Sprintf("('%s', '%s', '%s', '%s', '%s', %d, %d, '%s', '%s', '%s', '%s')",
+		sqlValues = append(sqlValues, fmt.Sprintf("('%s', '%s', '%s', '%s', '%s', %d, %d, '%s', '%s', '%s', '%s', '%s')",
 			course.ID,
 			course.Code,
 			course.Type,
@@ -145,10 +147,16 @@ func saveSql(courses []*model.Course, fileName string) {
 			api.DayOfWeekTable[course.DayOfWeek],
 			course.Teacher().ID,
 			course.Keywords,
-			course.Status()))
+			course.Status(),
+			semesterID))
+	}
+
+	_, err = sqlFile.WriteString(fmt.Sprintf("INSERT INTO `semesters` (`id`, `code`, `name`, `starts_on`) VALUES\n('%s', '2021-1', '2021年度 前期', '2021-04-05');\n", semesterID))
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	_, err = sqlFile.WriteString("INSERT INTO `courses` (`id`, `code`, `type`, `name`, `description`, `credit`, `period`, `day_of_week`, `teacher_id`, `keywords`, `status`) VALUES\n")
+	_, err = sqlFile.WriteString("INSERT INTO `courses` (`id`, `code`, `type`, `name`, `description`, `credit`, `period`, `day_of_week`, `teacher_id`, `keywords`, `status`, `semester_id`) VALUES\n")
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -38,3 +38,6 @@ _EOF_
 chmod 0700 ~isucon/.ssh
 chmod 0600 ~isucon/.ssh/authorized_keys
 chown isucon: ~isucon/.ssh -R
+
+# 開発環境ではサーバごとに署名鍵を生成する。複数台で動かす場合は同じ値に揃えること
+grep -q '^SESSION_SECRETS=' ~isucon/env.sh || echo "SESSION_SECRETS=$(openssl rand -hex 32)" >> ~isucon/env.sh
@@ -5,3 +5,5 @@ curl --retry 10 --retry-connrefused --max-time 10 --connect-timeout 5 "https://p
 chmod 0700 ~isucon/.ssh
 chmod 0600 ~isucon/.ssh/authorized_keys
 chown -R isucon:isucon ~isucon/.ssh
+
+grep -q '^SESSION_SECRETS=' ~isucon/env.sh || echo "SESSION_SECRETS=${session_secret}" >> ~isucon/env.sh
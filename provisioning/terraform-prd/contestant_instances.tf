@@ -1,3 +1,11 @@
+# チームの全サーバで共有するセッションの署名鍵
+resource "random_password" "session_secret" {
+  for_each = toset(var.team_ids)
+
+  length  = 64
+  special = false
+}
+
 resource "aws_instance" "contestant-1" {
   for_each = toset(var.team_ids)
 
@@ -30,7 +38,7 @@ resource "aws_instance" "contestant-1" {
     }
   }
 
-  user_data = templatefile("${path.module}/contestant-user-data.sh.tpl", { checker_token = var.checker_tokens[each.key] })
+  user_data = templatefile("${path.module}/contestant-user-data.sh.tpl", { checker_token = var.checker_tokens[each.key], session_secret = random_password.session_secret[each.key].result })
 }
 
 resource "aws_eip" "contestant-1" {
@@ -76,7 +84,7 @@ resource "aws_instance" "contestant-2" {
     }
   }
 
-  user_data = templatefile("${path.module}/contestant-user-data.sh.tpl", { checker_token = var.checker_tokens[each.key] })
+  user_data = templatefile("${path.module}/contestant-user-data.sh.tpl", { checker_token = var.checker_tokens[each.key], session_secret = random_password.session_secret[each.key].result })
 }
 
 resource "aws_eip" "contestant-2" {
@@ -122,7 +130,7 @@ resource "aws_instance" "contestant-3" {
     }
   }
 
-  user_data = templatefile("${path.module}/contestant-user-data.sh.tpl", { checker_token = var.checker_tokens[each.key] })
+  user_data = templatefile("${path.module}/contestant-user-data.sh.tpl", { checker_token = var.checker_tokens[each.key], session_secret = random_password.session_secret[each.key].result })
 }
 
 resource "aws_eip" "contestant-3" {
@@ -1,3 +1,11 @@
+# チームの全サーバで共有するセッションの署名鍵
+resource "random_password" "session_secret" {
+  for_each = toset(var.team_ids)
+
+  length  = 64
+  special = false
+}
+
 data "aws_ami" "contestant" {
   owners      = ["self"]
   most_recent = true
@@ -45,7 +53,7 @@ resource "aws_instance" "contestant-1" {
     }
   }
 
-  user_data = templatefile("${path.module}/contestant-user-data.sh.tpl", { checker_token = var.checker_tokens[each.key] })
+  user_data = templatefile("${path.module}/contestant-user-data.sh.tpl", { checker_token = var.checker_tokens[each.key], session_secret = random_password.session_secret[each.key].result })
 }
 
 resource "aws_eip" "contestant-1" {
@@ -90,7 +98,7 @@ resource "aws_instance" "contestant-2" {
     }
   }
 
-  user_data = templatefile("${path.module}/contestant-user-data.sh.tpl", { checker_token = var.checker_tokens[each.key] })
+  user_data = templatefile("${path.module}/contestant-user-data.sh.tpl", { checker_token = var.checker_tokens[each.key], session_secret = random_password.session_secret[each.key].result })
 }
 
 resource "aws_eip" "contestant-2" {
@@ -135,7 +143,7 @@ resource "aws_instance" "contestant-3" {
     }
   }
 
-  user_data = templatefile("${path.module}/contestant-user-data.sh.tpl", { checker_token = var.checker_tokens[each.key] })
+  user_data = templatefile("${path.module}/contestant-user-data.sh.tpl", { checker_token = var.checker_tokens[each.key], session_secret = random_password.session_secret[each.key].result })
 }
 
 resource "aws_eip" "contestant-3" {
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"time"
+)
+
+// 課題の提出期限
+//
+// 講義には課題の提出期限(deadline)と、期限後の提出を受け付ける期限(late_until)を設定できる。
+// 提出期限を過ぎてから late_until までに提出された課題は遅延提出となり、採点結果から late_penalty % 減点した点を総合点の計算に使う。
+// 提出期限のない講義は、これまでどおり submission_closed になるまで提出を受け付ける。
+
+const maxLatePenalty = 100
+
+type ClassDeadline struct {
+	Deadline    *time.Time `json:"deadline" db:"deadline"`
+	LateUntil   *time.Time `json:"late_until" db:"late_until"`
+	LatePenalty uint8      `json:"late_penalty" db:"late_penalty"` // 遅延提出の減点(百分率)
+}
+
+// acceptSubmission 時刻 now の提出を受け付けるかと、受け付ける場合は遅延提出かどうかを返す
+func (d ClassDeadline) acceptSubmission(now time.Time) (accepted bool, late bool) {
+	if d.Deadline == nil || !now.After(*d.Deadline) {
+		return true, false
+	}
+	if d.LateUntil != nil && !now.After(*d.LateUntil) {
+		return true, true
+	}
+	return false, false
+}
+
+// validate 不正な提出期限であればその理由を返す
+func (d ClassDeadline) validate() string {
+	if d.LateUntil != nil && (d.Deadline == nil || !d.LateUntil.After(*d.Deadline)) {
+		return "late_until must be after deadline."
+	}
+	if d.LatePenalty > maxLatePenalty {
+		return "Invalid late_penalty."
+	}
+	return ""
+}
+
+// equal DBに保存した提出期限と比較する。DATETIME(6) に合わせてマイクロ秒未満は無視する
+func (d ClassDeadline) equal(other ClassDeadline) bool {
+	sameTime := func(a, b *time.Time) bool {
+		if a == nil || b == nil {
+			return a == nil && b == nil
+		}
+		return a.Truncate(time.Microsecond).Equal(b.Truncate(time.Microsecond))
+	}
+	return sameTime(d.Deadline, other.Deadline) && sameTime(d.LateUntil, other.LateUntil) && d.LatePenalty == other.LatePenalty
+}
+
+// penalizedScore 遅延提出の減点を適用した得点
+func penalizedScore(score int, latePenalty uint8, late bool) int {
+	if !late {
+		return score
+	}
+	return score * (100 - int(latePenalty)) / 100
+}
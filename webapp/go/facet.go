@@ -0,0 +1,91 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+)
+
+// 科目検索のファセット
+//
+// ファセットごとに、そのファセット以外の絞り込み条件にマッチする科目の数を値ごとに数える。
+// 検索結果と同じ照合順序で比較するよう、ファセットごとに検索と同じ WHERE 句で GROUP BY して数える。
+
+type courseFacet struct {
+	// Name クエリパラメータとレスポンスのキー
+	Name   string
+	Column string
+}
+
+var courseFacets = []courseFacet{
+	{Name: "type", Column: "`courses`.`type`"},
+	{Name: "credit", Column: "`courses`.`credit`"},
+	{Name: "teacher", Column: "`users`.`name`"},
+	{Name: "period", Column: "`courses`.`period`"},
+	{Name: "day_of_week", Column: "`courses`.`day_of_week`"},
+	{Name: "status", Column: "`courses`.`status`"},
+}
+
+// courseFacetFilter ファセットでの絞り込み条件。Value が空の場合は絞り込まない
+type courseFacetFilter struct {
+	Value string
+	Arg   interface{}
+}
+
+// parseCourseFacetFilters courseFacets の順に絞り込み条件を返す
+func parseCourseFacetFilters(c echo.Context) []courseFacetFilter {
+	filters := make([]courseFacetFilter, len(courseFacets))
+	for i, facet := range courseFacets {
+		value := c.QueryParam(facet.Name)
+		switch facet.Name {
+		case "credit", "period":
+			// 無効な検索条件はエラーを返さず無視して良い
+			if n, err := strconv.Atoi(value); err == nil && n > 0 {
+				filters[i] = courseFacetFilter{Value: strconv.Itoa(n), Arg: n}
+			}
+		default:
+			if value != "" {
+				filters[i] = courseFacetFilter{Value: value, Arg: value}
+			}
+		}
+	}
+	return filters
+}
+
+type courseFacetCount struct {
+	Value string `db:"value"`
+	Count int    `db:"count"`
+}
+
+// countCourseFacets 検索語の条件 keywordCondition にマッチする科目から、ファセットごとの値ごとの科目数を数える
+func countCourseFacets(q sqlx.Queryer, filters []courseFacetFilter, keywordCondition string, keywordArgs []interface{}) (map[string]map[string]int, error) {
+	counts := make(map[string]map[string]int, len(courseFacets))
+	for i, facet := range courseFacets {
+		condition := keywordCondition
+		args := append([]interface{}(nil), keywordArgs...)
+		for j, filter := range filters {
+			if j == i || filter.Value == "" {
+				continue
+			}
+			condition += " AND " + courseFacets[j].Column + " = ?"
+			args = append(args, filter.Arg)
+		}
+
+		query := "SELECT " + facet.Column + " AS `value`, COUNT(*) AS `count`" +
+			" FROM `courses` JOIN `users` ON `courses`.`teacher_id` = `users`.`id`" +
+			" WHERE 1=1" + condition +
+			" GROUP BY " + facet.Column
+		var rows []courseFacetCount
+		if err := sqlx.Select(q, &rows, query, args...); err != nil {
+			return nil, err
+		}
+
+		counts[facet.Name] = make(map[string]int, len(rows))
+		for _, row := range rows {
+			counts[facet.Name][row.Value] = row.Count
+		}
+	}
+
+	return counts, nil
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 成績の集計値
+//
+// course_total_scores: 履修者ごとの科目の総合点(講義の得点の重み付き合計。遅延提出の得点は減点する)。履修登録時に0点で作成し、採点結果や成績評価の方針の登録時に再計算する
+// user_gpas: 修得した単位数、GPAの対象となる単位数、GP(×100)に単位数を掛けたものの合計。科目の終了時に加算する
+
+type GradingPolicyType string
+
+const (
+	// GradingScore 総合点/100をGPとする。成績評価の方針が未設定の科目はこれになる
+	GradingScore GradingPolicyType = "score"
+	// GradingLetter 総合点の区切りでS/A/B/C/Fの評語をつける
+	GradingLetter GradingPolicyType = "letter"
+	// GradingPassFail 合否のみを判定し、GPAの対象外とする
+	GradingPassFail GradingPolicyType = "pass_fail"
+)
+
+// defaultClassWeight 講義の重みの既定値(百分率)。全講義が既定値であれば総合点は得点の単純な合計になる
+const defaultClassWeight = 100
+
+// letterGradePoints 評語ごとのGP
+var letterGradePoints = map[string]int{
+	"S": 4,
+	"A": 3,
+	"B": 2,
+	"C": 1,
+	"F": 0,
+}
+
+type GradingPolicy struct {
+	CourseID  string            `json:"-" db:"course_id"`
+	Type      GradingPolicyType `json:"type" db:"type"`
+	PassScore int               `json:"pass_score" db:"pass_score"` // score, pass_fail の合格点
+	CutoffS   int               `json:"cutoff_s" db:"cutoff_s"`     // letter の各評語の下限
+	CutoffA   int               `json:"cutoff_a" db:"cutoff_a"`
+	CutoffB   int               `json:"cutoff_b" db:"cutoff_b"`
+	CutoffC   int               `json:"cutoff_c" db:"cutoff_c"`
+}
+
+// courseGrade 終了した科目の成績がGPAの集計値に寄与する値
+type courseGrade struct {
+	Grade               string // 評語。score では空文字、pass_fail では P か F
+	Credits             int    // 修得した単位数。合格した場合のみ
+	GPACredits          int    // GPAの対象となる単位数
+	WeightedGradePoints int    // GP×100×単位数
+}
+
+// getGradingPolicy 科目の成績評価の方針を返す。未設定であれば既定の方針を返す
+func getGradingPolicy(q sqlx.Queryer, courseID string) (GradingPolicy, error) {
+	policy := GradingPolicy{CourseID: courseID, Type: GradingScore}
+	if err := sqlx.Get(q, &policy, "SELECT `course_id`, `type`, `pass_score`, `cutoff_s`, `cutoff_a`, `cutoff_b`, `cutoff_c` FROM `grading_policies` WHERE `course_id` = ?", courseID); err != nil && err != sql.ErrNoRows {
+		return policy, err
+	}
+	return policy, nil
+}
+
+// getGradingPolicies 複数の科目の成績評価の方針を返す。未設定の科目は既定の方針になる
+func getGradingPolicies(q sqlx.Queryer, courseIDs []string) (map[string]GradingPolicy, error) {
+	res := make(map[string]GradingPolicy, len(courseIDs))
+	for _, courseID := range courseIDs {
+		res[courseID] = GradingPolicy{CourseID: courseID, Type: GradingScore}
+	}
+	if len(courseIDs) == 0 {
+		return res, nil
+	}
+
+	var policies []GradingPolicy
+	query, args, err := sqlx.In("SELECT `course_id`, `type`, `pass_score`, `cutoff_s`, `cutoff_a`, `cutoff_b`, `cutoff_c` FROM `grading_policies` WHERE `course_id` IN (?)", courseIDs)
+	if err != nil {
+		return nil, err
+	}
+	if err := sqlx.Select(q, &policies, query, args...); err != nil {
+		return nil, err
+	}
+	for _, policy := range policies {
+		res[policy.CourseID] = policy
+	}
+	return res, nil
+}
+
+// grade 総合点から科目の成績を判定する
+func (p GradingPolicy) grade(totalScore int, credit int) courseGrade {
+	switch p.Type {
+	case GradingLetter:
+		var grade string
+		switch {
+		case totalScore >= p.CutoffS:
+			grade = "S"
+		case totalScore >= p.CutoffA:
+			grade = "A"
+		case totalScore >= p.CutoffB:
+			grade = "B"
+		case totalScore >= p.CutoffC:
+			grade = "C"
+		default:
+			grade = "F"
+		}
+		res := courseGrade{
+			Grade:               grade,
+			GPACredits:          credit,
+			WeightedGradePoints: letterGradePoints[grade] * 100 * credit,
+		}
+		if grade != "F" {
+			res.Credits = credit
+		}
+		return res
+	case GradingPassFail:
+		if totalScore >= p.PassScore {
+			return courseGrade{Grade: "P", Credits: credit}
+		}
+		return courseGrade{Grade: "F"}
+	default:
+		res := courseGrade{
+			GPACredits:          credit,
+			WeightedGradePoints: totalScore * credit,
+		}
+		if totalScore >= p.PassScore {
+			res.Credits = credit
+		}
+		return res
+	}
+}
+
+// validate 不正な方針であればその理由を返す
+func (p GradingPolicy) validate() string {
+	switch p.Type {
+	case GradingScore, GradingPassFail:
+		if p.PassScore < 0 {
+			return "Invalid pass_score."
+		}
+	case GradingLetter:
+		if !(p.CutoffS > p.CutoffA && p.CutoffA > p.CutoffB && p.CutoffB > p.CutoffC && p.CutoffC >= 0) {
+			return "Cut-offs must be in descending order (S > A > B > C >= 0)."
+		}
+	default:
+		return "Invalid grading policy type."
+	}
+	return ""
+}
+
+type courseTotalScore struct {
+	UserID     string `db:"user_id"`
+	TotalScore int    `db:"total_score"`
+}
+
+// addCourseTotalScore 履修登録した学生の総合点を0点で作成する
+func addCourseTotalScore(tx *sqlx.Tx, courseID string, userID string) error {
+	_, err := tx.Exec("INSERT IGNORE INTO `course_total_scores` (`course_id`, `user_id`) VALUES (?, ?)", courseID, userID)
+	return err
+}
+
+// deleteCourseTotalScore 履修を取り消した学生の総合点を削除する
+func deleteCourseTotalScore(tx *sqlx.Tx, courseID string, userID string) error {
+	_, err := tx.Exec("DELETE FROM `course_total_scores` WHERE `course_id` = ? AND `user_id` = ?", courseID, userID)
+	return err
+}
+
+// refreshCourseTotalScores 科目の履修者の総合点を再計算する
+// 終了済みの科目であれば、成績の変化分をGPAの集計値にも反映する
+func refreshCourseTotalScores(tx *sqlx.Tx, courseID string) error {
+	policy, err := getGradingPolicy(tx, courseID)
+	if err != nil {
+		return err
+	}
+	return regradeCourse(tx, courseID, policy, policy)
+}
+
+// regradeCourse 科目の履修者の総合点を再計算し、終了済みの科目であれば変更前の方針による成績と
+// 変更後の方針による成績の差分をGPAの集計値に反映する
+func regradeCourse(tx *sqlx.Tx, courseID string, before GradingPolicy, after GradingPolicy) error {
+	var course Course
+	if err := tx.Get(&course, "SELECT * FROM `courses` WHERE `id` = ? FOR SHARE", courseID); err != nil {
+		return err
+	}
+
+	var beforeTotals []courseTotalScore
+	if err := tx.Select(&beforeTotals, "SELECT `user_id`, `total_score` FROM `course_total_scores` WHERE `course_id` = ? FOR UPDATE", courseID); err != nil {
+		return err
+	}
+
+	query := "UPDATE `course_total_scores`" +
+		" LEFT JOIN (" +
+		"     SELECT `submissions`.`user_id`, SUM(IF(`submissions`.`late`, `submissions`.`score` * (100 - `classes`.`late_penalty`) DIV 100, `submissions`.`score`) * IFNULL(`class_weights`.`weight`, ?)) DIV 100 AS `total_score`" +
+		"     FROM `submissions`" +
+		"     JOIN `classes` ON `submissions`.`class_id` = `classes`.`id`" +
+		"     LEFT JOIN `class_weights` ON `classes`.`id` = `class_weights`.`class_id`" +
+		"     WHERE `classes`.`course_id` = ?" +
+		"     GROUP BY `submissions`.`user_id`" +
+		" ) AS `totals` ON `course_total_scores`.`user_id` = `totals`.`user_id`" +
+		" SET `course_total_scores`.`total_score` = IFNULL(`totals`.`total_score`, 0)" +
+		" WHERE `course_total_scores`.`course_id` = ?"
+	if _, err := tx.Exec(query, defaultClassWeight, courseID, courseID); err != nil {
+		return err
+	}
+
+	if course.Status != StatusClosed {
+		return nil
+	}
+
+	var afterTotals []courseTotalScore
+	if err := tx.Select(&afterTotals, "SELECT `user_id`, `total_score` FROM `course_total_scores` WHERE `course_id` = ?", courseID); err != nil {
+		return err
+	}
+	beforeScores := make(map[string]int, len(beforeTotals))
+	for _, total := range beforeTotals {
+		beforeScores[total.UserID] = total.TotalScore
+	}
+	for _, total := range afterTotals {
+		prev := before.grade(beforeScores[total.UserID], int(course.Credit))
+		next := after.grade(total.TotalScore, int(course.Credit))
+		if prev == next {
+			continue
+		}
+		if _, err := tx.Exec("UPDATE `user_gpas` SET `credits` = `credits` + ?, `gpa_credits` = `gpa_credits` + ?, `weighted_grade_points` = `weighted_grade_points` + ? WHERE `user_id` = ?",
+			next.Credits-prev.Credits, next.GPACredits-prev.GPACredits, next.WeightedGradePoints-prev.WeightedGradePoints, total.UserID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addClosedCourseToGPAs 終了した科目の成績を履修者のGPAの集計値に加算する
+func addClosedCourseToGPAs(tx *sqlx.Tx, courseID string) error {
+	var course Course
+	if err := tx.Get(&course, "SELECT * FROM `courses` WHERE `id` = ?", courseID); err != nil {
+		return err
+	}
+	policy, err := getGradingPolicy(tx, courseID)
+	if err != nil {
+		return err
+	}
+
+	var totals []courseTotalScore
+	if err := tx.Select(&totals, "SELECT `user_id`, `total_score` FROM `course_total_scores` WHERE `course_id` = ?", courseID); err != nil {
+		return err
+	}
+	if len(totals) == 0 {
+		return nil
+	}
+
+	placeholders := make([]string, 0, len(totals))
+	args := make([]interface{}, 0, len(totals)*4)
+	for _, total := range totals {
+		grade := policy.grade(total.TotalScore, int(course.Credit))
+		placeholders = append(placeholders, "(?, ?, ?, ?)")
+		args = append(args, total.UserID, grade.Credits, grade.GPACredits, grade.WeightedGradePoints)
+	}
+	query := "INSERT INTO `user_gpas` (`user_id`, `credits`, `gpa_credits`, `weighted_grade_points`) VALUES " + strings.Join(placeholders, ", ") +
+		" ON DUPLICATE KEY UPDATE `credits` = `credits` + VALUES(`credits`), `gpa_credits` = `gpa_credits` + VALUES(`gpa_credits`), `weighted_grade_points` = `weighted_grade_points` + VALUES(`weighted_grade_points`)"
+	_, err = tx.Exec(query, args...)
+	return err
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+)
+
+// 抽選による履修登録
+//
+// 抽選の科目には履修登録期間中に先着順で履修登録できず、学生は抽選の科目に希望順位をつけて申し込む。
+// 教員が科目を in-progress にすると抽選待ちになり、LotteryAllocator がバックグラウンドで抽選待ちの科目をまとめて抽選する。
+//   - 希望順位の高い申し込みから順に、同じ順位の申し込みの間では科目のシードで決まるランダムな順に席を割り当てる
+//   - 定員に達した後の学生と、時間割の重複・履修単位数の上限・履修要件により履修できない学生は落選とする
+//   - 当選した学生は履修登録され、落選した学生とあわせて抽選結果のお知らせが届く
+//
+// 同時に抽選待ちになっている科目の間では、学生ごとに希望順位の高い科目から順に割り当てを試すため、
+// 同じコマの科目に申し込んだ学生は希望順位の高い科目から当選する。
+// 抽選待ちになる時期が異なる科目の間ではこの限りでなく、先に抽選された科目への当選が後の科目の抽選に影響する。
+
+type LotteryStatus string
+
+const (
+	LotteryOpen      LotteryStatus = "open"
+	LotteryPending   LotteryStatus = "pending"
+	LotteryAllocated LotteryStatus = "allocated"
+)
+
+type LotteryResult string
+
+const (
+	LotteryResultPending LotteryResult = "pending"
+	LotteryResultWon     LotteryResult = "won"
+	LotteryResultLost    LotteryResult = "lost"
+)
+
+const defaultLotteryInterval = time.Second
+
+type CourseLottery struct {
+	CourseID string        `db:"course_id"`
+	Seed     int64         `db:"seed"`
+	Status   LotteryStatus `db:"status"`
+}
+
+type lotteryCandidate struct {
+	UserID   string `db:"user_id"`
+	Priority int    `db:"priority"`
+}
+
+// newLotterySeed 抽選のシードをランダムに決める
+func newLotterySeed() (int64, error) {
+	b := make([]byte, 8)
+	if _, err := crand.Read(b); err != nil {
+		return 0, err
+	}
+	return int64(binary.BigEndian.Uint64(b) >> 1), nil
+}
+
+// lotteryOrder 席を割り当てる順に申し込みを並べる。同じシードと申し込みからは常に同じ順になる
+func lotteryOrder(seed int64, candidates []lotteryCandidate) []lotteryCandidate {
+	order := append(make([]lotteryCandidate, 0, len(candidates)), candidates...)
+	sort.Slice(order, func(i, j int) bool {
+		return order[i].UserID < order[j].UserID
+	})
+	r := rand.New(rand.NewSource(seed))
+	r.Shuffle(len(order), func(i, j int) {
+		order[i], order[j] = order[j], order[i]
+	})
+	sort.SliceStable(order, func(i, j int) bool {
+		return order[i].Priority < order[j].Priority
+	})
+	return order
+}
+
+// lotteryDraw 抽選待ちの科目一つ分の抽選
+type lotteryDraw struct {
+	Course  Course
+	Seats   int
+	Order   []lotteryCandidate // lotteryOrder で並べた申し込み
+	Winners []string
+	Losers  []string
+}
+
+// allocateLotterySeats draws の科目の席をまとめて割り当て、各科目の当選者と落選者を記録する
+// 希望順位の高い申し込みから順に、同じ順位の申し込みの間では draws の順・各科目の Order の順に割り当てる。
+// allocate が false を返した学生は、席が残っていても落選とする。allocate は当選させる学生を履修登録し、以降の判定に反映させる
+func allocateLotterySeats(draws []*lotteryDraw, allocate func(course Course, userID string) (bool, error)) error {
+	type application struct {
+		draw      *lotteryDraw
+		candidate lotteryCandidate
+	}
+	var applications []application
+	for _, draw := range draws {
+		for _, candidate := range draw.Order {
+			applications = append(applications, application{draw: draw, candidate: candidate})
+		}
+	}
+	sort.SliceStable(applications, func(i, j int) bool {
+		return applications[i].candidate.Priority < applications[j].candidate.Priority
+	})
+
+	for _, a := range applications {
+		if a.draw.Seats <= 0 {
+			a.draw.Losers = append(a.draw.Losers, a.candidate.UserID)
+			continue
+		}
+		ok, err := allocate(a.draw.Course, a.candidate.UserID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			a.draw.Losers = append(a.draw.Losers, a.candidate.UserID)
+			continue
+		}
+		a.draw.Winners = append(a.draw.Winners, a.candidate.UserID)
+		a.draw.Seats--
+	}
+	return nil
+}
+
+// LotteryAllocator 抽選待ちの科目に席を割り当てる
+// 複数のサーバで動かしても、抽選待ちの科目はいずれか一つのサーバで一度だけ処理される
+type LotteryAllocator struct {
+	DB       *sqlx.DB
+	Logger   echo.Logger
+	Interval time.Duration
+	wake     chan struct{}
+}
+
+func NewLotteryAllocator(db *sqlx.DB, logger echo.Logger) *LotteryAllocator {
+	return &LotteryAllocator{
+		DB:       db,
+		Logger:   logger,
+		Interval: defaultLotteryInterval,
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Notify 抽選待ちの科目が増えたことを知らせ、次の定期実行を待たずに処理させる
+func (a *LotteryAllocator) Notify() {
+	select {
+	case a.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run ctx が終了するまで抽選待ちの科目を処理し続ける
+func (a *LotteryAllocator) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.Interval)
+	defer ticker.Stop()
+	for {
+		if err := runLottery(a.DB); err != nil {
+			a.Logger.Error(err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-a.wake:
+		}
+	}
+}
+
+// runLottery 抽選待ちの科目をまとめて抽選する
+// 複数のサーバで同時に実行しても、抽選待ちの科目はいずれか一つのサーバで一度だけ抽選される
+func runLottery(db *sqlx.DB) error {
+	var courseIDs []string
+	if err := db.Select(&courseIDs, "SELECT `course_id` FROM `course_lotteries` WHERE `status` = ? ORDER BY `course_id`", LotteryPending); err != nil {
+		return err
+	}
+	if len(courseIDs) == 0 {
+		return nil
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// 履修登録と同様に科目の行を先にロックする
+	var courses []Course
+	query, args, err := sqlx.In("SELECT * FROM `courses` WHERE `id` IN (?) ORDER BY `id` FOR UPDATE", courseIDs)
+	if err != nil {
+		return err
+	}
+	if err := tx.Select(&courses, query, args...); err != nil {
+		return err
+	}
+	coursesByID := make(map[string]Course, len(courses))
+	for _, course := range courses {
+		coursesByID[course.ID] = course
+	}
+
+	// 他のサーバが抽選済みの科目は除く
+	var lotteries []CourseLottery
+	query, args, err = sqlx.In("SELECT `course_id`, `seed`, `status` FROM `course_lotteries` WHERE `course_id` IN (?) AND `status` = ? ORDER BY `course_id` FOR UPDATE", courseIDs, LotteryPending)
+	if err != nil {
+		return err
+	}
+	if err := tx.Select(&lotteries, query, args...); err != nil {
+		return err
+	}
+	if len(lotteries) == 0 {
+		return nil
+	}
+
+	draws := make([]*lotteryDraw, 0, len(lotteries))
+	for _, lottery := range lotteries {
+		course := coursesByID[lottery.CourseID]
+
+		var candidates []lotteryCandidate
+		if err := tx.Select(&candidates, "SELECT `user_id`, `priority` FROM `lottery_preferences` WHERE `course_id` = ? AND `result` = ?", course.ID, LotteryResultPending); err != nil {
+			return err
+		}
+
+		var registeredCount int
+		if err := tx.Get(&registeredCount, "SELECT COUNT(*) FROM `registrations` WHERE `course_id` = ?", course.ID); err != nil {
+			return err
+		}
+
+		draws = append(draws, &lotteryDraw{
+			Course: course,
+			Seats:  int(course.Capacity) - registeredCount,
+			Order:  lotteryOrder(lottery.Seed, candidates),
+		})
+	}
+
+	err = allocateLotterySeats(draws, func(course Course, userID string) (bool, error) {
+		ok, err := canAllocateSeat(tx, userID, course)
+		if err != nil || !ok {
+			return false, err
+		}
+		if _, err := tx.Exec("INSERT INTO `registrations` (`course_id`, `user_id`) VALUES (?, ?)", course.ID, userID); err != nil {
+			return false, err
+		}
+		if err := addCourseTotalScore(tx, course.ID, userID); err != nil {
+			return false, err
+		}
+		return true, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, draw := range draws {
+		if err := recordLotteryResults(tx, draw); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// recordLotteryResults 抽選結果を記録し、当選者と落選者のそれぞれにお知らせを送る
+func recordLotteryResults(tx *sqlx.Tx, draw *lotteryDraw) error {
+	course := draw.Course
+	for _, result := range []struct {
+		result  LotteryResult
+		userIDs []string
+		message string
+	}{
+		{LotteryResultWon, draw.Winners, "%s %s の抽選に当選し、履修登録されました。"},
+		{LotteryResultLost, draw.Losers, "%s %s の抽選に落選しました。"},
+	} {
+		if len(result.userIDs) == 0 {
+			continue
+		}
+		query, args, err := sqlx.In("UPDATE `lottery_preferences` SET `result` = ? WHERE `course_id` = ? AND `user_id` IN (?)", result.result, course.ID, result.userIDs)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(query, args...); err != nil {
+			return err
+		}
+
+		announcementID := newULID()
+		if _, err := tx.Exec("INSERT INTO `announcements` (`id`, `course_id`, `title`, `message`) VALUES (?, ?, ?, ?)",
+			announcementID, course.ID, "抽選結果のお知らせ", fmt.Sprintf(result.message, course.Code, course.Name)); err != nil {
+			return err
+		}
+		for _, userID := range result.userIDs {
+			if _, err := tx.Exec("INSERT INTO `unread_announcements` (`announcement_id`, `user_id`) VALUES (?, ?)", announcementID, userID); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err := tx.Exec("UPDATE `course_lotteries` SET `status` = ?, `allocated_at` = NOW(6) WHERE `course_id` = ?", LotteryAllocated, course.ID)
+	return err
+}
+
+// canAllocateSeat 学生が時間割の重複・履修単位数の上限・履修要件の制約を満たしたまま科目を履修できるかを返す
+func canAllocateSeat(tx *sqlx.Tx, userID string, course Course) (bool, error) {
+	var registered []Course
+	query := "SELECT `courses`.*" +
+		" FROM `courses`" +
+		" JOIN `registrations` ON `courses`.`id` = `registrations`.`course_id`" +
+		" WHERE `courses`.`status` != ? AND `registrations`.`user_id` = ?"
+	if err := tx.Select(&registered, query, StatusClosed, userID); err != nil {
+		return false, err
+	}
+	if hasScheduleConflict(course, registered) {
+		return false, nil
+	}
+
+	semesterID, err := resolveSemesterID(tx, course.SemesterID)
+	if err != nil {
+		return false, err
+	}
+	if exceeded, err := exceedsCreditLimit(tx, userID, semesterID, int(course.Credit)); err != nil || exceeded {
+		return false, err
+	}
+
+	prerequisiteNotMet, corequisiteNotMet, err := checkRequisites(tx, userID, []Course{course}, append(registered, course))
+	if err != nil {
+		return false, err
+	}
+	return len(prerequisiteNotMet) == 0 && len(corequisiteNotMet) == 0, nil
+}
+
+// hasScheduleConflict 科目が registered のいずれかの科目と同じコマに開講されるかを返す
+func hasScheduleConflict(course Course, registered []Course) bool {
+	for _, other := range registered {
+		if other.ID != course.ID && other.Period == course.Period && other.DayOfWeek == course.DayOfWeek {
+			return true
+		}
+	}
+	return false
+}
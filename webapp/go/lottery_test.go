@@ -0,0 +1,215 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func TestLotteryOrder(t *testing.T) {
+	candidates := []lotteryCandidate{
+		{UserID: "u1", Priority: 2},
+		{UserID: "u2", Priority: 1},
+		{UserID: "u3", Priority: 2},
+		{UserID: "u4", Priority: 1},
+		{UserID: "u5", Priority: 3},
+		{UserID: "u6", Priority: 2},
+	}
+	reversed := make([]lotteryCandidate, len(candidates))
+	for i, candidate := range candidates {
+		reversed[len(candidates)-1-i] = candidate
+	}
+
+	order := lotteryOrder(42, candidates)
+	if len(order) != len(candidates) {
+		t.Fatalf("got %d candidates, want %d", len(order), len(candidates))
+	}
+	for i := 1; i < len(order); i++ {
+		if order[i-1].Priority > order[i].Priority {
+			t.Fatalf("not ordered by priority: %v", order)
+		}
+	}
+
+	// 同じシードからは申し込みの順によらず同じ順になる
+	for i := 0; i < 10; i++ {
+		if got := lotteryOrder(42, reversed); !reflect.DeepEqual(got, order) {
+			t.Fatalf("order is not stable for the same seed: got %v, want %v", got, order)
+		}
+	}
+
+	// 引数の申し込みは並べ替えない
+	if candidates[0].UserID != "u1" || reversed[0].UserID != "u6" {
+		t.Fatalf("candidates were modified")
+	}
+}
+
+func TestAllocateLotterySeats(t *testing.T) {
+	// a と b は同じコマの科目
+	courseA := Course{ID: "a", DayOfWeek: Monday, Period: 1}
+	courseB := Course{ID: "b", DayOfWeek: Monday, Period: 1}
+	courseC := Course{ID: "c", DayOfWeek: Tuesday, Period: 1}
+	registered := map[string][]Course{
+		// u4 は a と同じコマの科目を履修済み
+		"u4": {{ID: "d", DayOfWeek: Monday, Period: 1}},
+	}
+	allocate := func(course Course, userID string) (bool, error) {
+		if hasScheduleConflict(course, registered[userID]) {
+			return false, nil
+		}
+		registered[userID] = append(registered[userID], course)
+		return true, nil
+	}
+
+	// u1 は a より先に抽選される b を第一希望にしている
+	draws := []*lotteryDraw{
+		{Course: courseA, Seats: 2, Order: []lotteryCandidate{{UserID: "u2", Priority: 1}, {UserID: "u4", Priority: 1}, {UserID: "u1", Priority: 2}}},
+		{Course: courseB, Seats: 2, Order: []lotteryCandidate{{UserID: "u1", Priority: 1}, {UserID: "u3", Priority: 2}}},
+		{Course: courseC, Seats: 1, Order: []lotteryCandidate{{UserID: "u3", Priority: 1}, {UserID: "u2", Priority: 2}}},
+	}
+	if err := allocateLotterySeats(draws, allocate); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, want := range []struct {
+		winners []string
+		losers  []string
+	}{
+		{winners: []string{"u2"}, losers: []string{"u4", "u1"}},
+		{winners: []string{"u1", "u3"}},
+		{winners: []string{"u3"}, losers: []string{"u2"}},
+	} {
+		draw := draws[i]
+		if !reflect.DeepEqual(draw.Winners, want.winners) || !reflect.DeepEqual(draw.Losers, want.losers) {
+			t.Errorf("course %s: winners = %v, losers = %v, want %v, %v", draw.Course.ID, draw.Winners, draw.Losers, want.winners, want.losers)
+		}
+	}
+}
+
+// openTestDB MYSQL_TEST_DATABASE で指定したデータベースにスキーマを作り直して接続する
+// テーブルはすべて削除されるため、テスト専用のデータベースを指定すること
+func openTestDB(t *testing.T) *sqlx.DB {
+	t.Helper()
+	database := os.Getenv("MYSQL_TEST_DATABASE")
+	if database == "" {
+		t.Skip("MYSQL_TEST_DATABASE is not set")
+	}
+	t.Setenv("MYSQL_DATABASE", database)
+	db, err := GetDB(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	schema, err := os.ReadFile(SQLDirectory + "1_schema.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(string(schema)); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestRunLottery(t *testing.T) {
+	db := openTestDB(t)
+
+	mustExec := func(query string, args ...interface{}) {
+		t.Helper()
+		if _, err := db.Exec(query, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, user := range []struct {
+		id       string
+		userType UserType
+	}{{"t1", Teacher}, {"s1", Student}, {"s2", Student}, {"s3", Student}, {"s4", Student}} {
+		mustExec("INSERT INTO `users` (`id`, `code`, `name`, `hashed_password`, `type`) VALUES (?, ?, ?, '', ?)", user.id, user.id, user.id, user.userType)
+	}
+	mustExec("INSERT INTO `semesters` (`id`, `code`, `name`, `starts_on`) VALUES ('sem', '2021-1', '2021年度 前期', '2021-04-05')")
+	// a と b は同じコマの科目。d は抽選のない履修済みの科目、e は申し込みを締め切っていない抽選の科目
+	for _, course := range []struct {
+		id        string
+		dayOfWeek DayOfWeek
+		period    int
+		capacity  int
+		status    CourseStatus
+	}{
+		{"a", Monday, 1, 2, StatusInProgress},
+		{"b", Monday, 1, 2, StatusInProgress},
+		{"c", Tuesday, 1, 1, StatusInProgress},
+		{"d", Monday, 1, 50, StatusInProgress},
+		{"e", Wednesday, 1, 50, StatusRegistration},
+	} {
+		mustExec("INSERT INTO `courses` (`id`, `code`, `type`, `name`, `description`, `credit`, `period`, `day_of_week`, `teacher_id`, `keywords`, `status`, `capacity`) VALUES (?, ?, ?, ?, '', 1, ?, ?, 't1', '', ?, ?)",
+			course.id, course.id, LiberalArts, course.id, course.period, course.dayOfWeek, course.status, course.capacity)
+	}
+	mustExec("INSERT INTO `registrations` (`course_id`, `user_id`) VALUES ('d', 's4')")
+	for _, lottery := range []struct {
+		courseID string
+		status   LotteryStatus
+	}{{"a", LotteryPending}, {"b", LotteryPending}, {"c", LotteryPending}, {"e", LotteryOpen}} {
+		mustExec("INSERT INTO `course_lotteries` (`course_id`, `seed`, `status`) VALUES (?, 1, ?)", lottery.courseID, lottery.status)
+	}
+	for _, p := range []struct {
+		userID   string
+		courseID string
+		priority int
+	}{
+		// s1 は a より先に抽選される b を第一希望にしている
+		{"s1", "b", 1}, {"s1", "a", 2},
+		{"s2", "a", 1}, {"s2", "c", 2},
+		{"s3", "c", 1}, {"s3", "b", 2},
+		// s4 は a と同じコマの d を履修済み
+		{"s4", "a", 1}, {"s4", "e", 2},
+	} {
+		mustExec("INSERT INTO `lottery_preferences` (`user_id`, `course_id`, `priority`) VALUES (?, ?, ?)", p.userID, p.courseID, p.priority)
+	}
+
+	if err := runLottery(db); err != nil {
+		t.Fatal(err)
+	}
+	// 抽選済みの科目は再び抽選しない
+	if err := runLottery(db); err != nil {
+		t.Fatal(err)
+	}
+
+	var registrations []string
+	if err := db.Select(&registrations, "SELECT CONCAT(`course_id`, ':', `user_id`) FROM `registrations` ORDER BY `course_id`, `user_id`"); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a:s2", "b:s1", "b:s3", "c:s3", "d:s4"}; !reflect.DeepEqual(registrations, want) {
+		t.Errorf("registrations = %v, want %v", registrations, want)
+	}
+
+	var results []string
+	if err := db.Select(&results, "SELECT CONCAT(`course_id`, ':', `user_id`, ':', `result`) FROM `lottery_preferences` ORDER BY `course_id`, `user_id`"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a:s1:lost", "a:s2:won", "a:s4:lost", "b:s1:won", "b:s3:won", "c:s2:lost", "c:s3:won", "e:s4:pending"}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("results = %v, want %v", results, want)
+	}
+
+	var statuses []string
+	if err := db.Select(&statuses, "SELECT CONCAT(`course_id`, ':', `status`) FROM `course_lotteries` ORDER BY `course_id`"); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a:allocated", "b:allocated", "c:allocated", "e:open"}; !reflect.DeepEqual(statuses, want) {
+		t.Errorf("lottery statuses = %v, want %v", statuses, want)
+	}
+
+	// 当選者にも落選者にも抽選結果のお知らせが一件ずつ届く
+	var unread []string
+	query := "SELECT CONCAT(`announcements`.`course_id`, ':', `unread_announcements`.`user_id`)" +
+		" FROM `unread_announcements`" +
+		" JOIN `announcements` ON `unread_announcements`.`announcement_id` = `announcements`.`id`" +
+		" ORDER BY `announcements`.`course_id`, `unread_announcements`.`user_id`"
+	if err := db.Select(&unread, query); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a:s1", "a:s2", "a:s4", "b:s1", "b:s3", "c:s2", "c:s3"}; !reflect.DeepEqual(unread, want) {
+		t.Errorf("announcements = %v, want %v", unread, want)
+	}
+}
@@ -1,18 +1,28 @@
 package main
 
 import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/csv"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"log"
+	"math"
+	"mime"
 	"net/http"
-	"net/url"
 	"os"
-	"os/exec"
+	"runtime"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 
 	"github.com/go-sql-driver/mysql"
 	"github.com/gorilla/sessions"
@@ -22,21 +32,37 @@ import (
 	"github.com/labstack/echo/v4/middleware"
 	"github.com/newrelic/go-agent/v3/integrations/nrecho-v4"
 	"github.com/newrelic/go-agent/v3/newrelic"
+	"github.com/oklog/ulid/v2"
 	"golang.org/x/crypto/bcrypt"
 
 	_ "net/http/pprof"
 )
 
 const (
-	SQLDirectory              = "../sql/"
-	AssignmentsDirectory      = "../assignments/"
-	InitDataDirectory         = "../data/"
-	SessionName               = "isucholar_go"
-	mysqlErrNumDuplicateEntry = 1062
+	SQLDirectory                = "../sql/"
+	AssignmentsDirectory        = "../assignments/"
+	InitDataDirectory           = "../data/"
+	SessionName                 = "isucholar_go"
+	mysqlErrNumDuplicateEntry   = 1062
+	defaultCourseCapacity       = 50
+	defaultHistogramBucketWidth = 10
+	minPasswordLength           = 8
+	passwordHashCost            = bcrypt.DefaultCost
+	passwordResetTokenTTL       = 24 * time.Hour
+	maxClassWeight              = 1000
 )
 
 type handlers struct {
-	DB *sqlx.DB
+	DB               *sqlx.DB
+	Sessions         *SessionStore
+	LoginCodeLimiter RateLimiter
+	LoginIPLimiter   RateLimiter
+	Timetable        TimetableConfig
+	Lottery          *LotteryAllocator
+	Storage          Storage
+	// CloseSubmissionsOnExport 課題の一括ダウンロードで提出を締め切る従来の動作を有効にする
+	// 提出の締め切りに PUT submission-status を使わない従来のベンチマーカーで負荷走行する場合のみ CLOSE_SUBMISSIONS_ON_EXPORT=true を設定する
+	CloseSubmissionsOnExport bool
 }
 
 func main() {
@@ -45,9 +71,14 @@ func main() {
 	e.Server.Addr = fmt.Sprintf(":%v", GetEnv("PORT", "7000"))
 	e.HideBanner = true
 
+	ipExtractor, err := NewIPExtractor(GetEnv("TRUSTED_PROXIES", ""))
+	if err != nil {
+		e.Logger.Fatal(err)
+	}
+	e.IPExtractor = ipExtractor
+
 	e.Use(middleware.Logger())
 	e.Use(middleware.Recover())
-	e.Use(session.Middleware(sessions.NewCookieStore([]byte("trapnomura"))))
 
 	// newRelic
 	app, err := newrelic.NewApplication(
@@ -63,34 +94,111 @@ func main() {
 	db, _ := GetDB(false)
 	db.SetMaxOpenConns(10)
 
-	h := &handlers{
-		DB: db,
+	sessionBackend, err := NewSessionBackend(GetEnv("SESSION_STORE", "mysql"), db)
+	if err != nil {
+		e.Logger.Fatal(err)
+	}
+	sessionSecrets, err := loadSessionSecrets()
+	if err != nil {
+		e.Logger.Fatal(err)
+	}
+	sessionStore := NewSessionStore(sessionBackend, sessionSecrets)
+	go RunSessionSweeper(context.Background(), sessionBackend, defaultSessionSweepInterval, e.Logger)
+	e.Use(session.Middleware(sessionStore))
+
+	timetableConfig, err := LoadTimetableConfig()
+	if err != nil {
+		e.Logger.Fatal(err)
+	}
+
+	storage, err := NewStorage(GetEnv("ASSIGNMENT_STORAGE", "local"))
+	if err != nil {
+		e.Logger.Fatal(err)
 	}
 
+	// 学内コードごとに5回、IPアドレスごとに50回の失敗でロックアウトする(失敗の記録は時間経過で回復する)
+	loginCodeLimiter := NewTokenBucketLimiter(TokenBucketConfig{
+		Capacity:       5,
+		RefillInterval: 30 * time.Second,
+		BaseLockout:    30 * time.Second,
+		MaxLockout:     15 * time.Minute,
+	})
+	loginIPLimiter := NewTokenBucketLimiter(TokenBucketConfig{
+		Capacity:       50,
+		RefillInterval: time.Second,
+		BaseLockout:    10 * time.Second,
+		MaxLockout:     5 * time.Minute,
+	})
+	go loginCodeLimiter.Run(context.Background(), defaultRateLimitSweepInterval)
+	go loginIPLimiter.Run(context.Background(), defaultRateLimitSweepInterval)
+
+	h := &handlers{
+		DB:               db,
+		Sessions:         sessionStore,
+		LoginCodeLimiter: loginCodeLimiter,
+		LoginIPLimiter:   loginIPLimiter,
+		Timetable:        timetableConfig,
+		Lottery:          NewLotteryAllocator(db, e.Logger),
+		Storage:          storage,
+		// 既存のクライアントは一括ダウンロードで提出が締め切られることを前提にしているため、既定では有効にする
+		CloseSubmissionsOnExport: GetEnv("CLOSE_SUBMISSIONS_ON_EXPORT", "false") == "true",
+	}
+	go h.Lottery.Run(context.Background())
+
 	e.POST("/initialize", h.Initialize)
 
 	e.POST("/login", h.Login)
 	e.POST("/logout", h.Logout)
+	e.POST("/password-reset", h.RedeemPasswordReset)
 	API := e.Group("/api", h.IsLoggedIn)
 	{
 		usersAPI := API.Group("/users")
 		{
+			usersAPI.POST("", h.AddUser, h.IsAdmin)
+			usersAPI.POST("/import", h.ImportUsers, h.IsAdmin)
 			usersAPI.GET("/me", h.GetMe)
 			usersAPI.GET("/me/courses", h.GetRegisteredCourses)
+			usersAPI.GET("/me/timetable", h.GetTimetable)
 			usersAPI.PUT("/me/courses", h.RegisterCourses)
+			usersAPI.DELETE("/me/courses/:courseID", h.UnregisterCourse)
+			usersAPI.GET("/me/lottery-preferences", h.GetLotteryPreferences)
+			usersAPI.PUT("/me/lottery-preferences", h.SetLotteryPreferences)
 			usersAPI.GET("/me/grades", h.GetGrades)
+			usersAPI.GET("/me/transcript", h.GetTranscript)
+			usersAPI.GET("/me/teaching", h.GetTeachingCourses, h.IsAdmin)
+			usersAPI.GET("/me/sessions", h.GetSessions)
+			usersAPI.POST("/me/sessions/revoke", h.RevokeSessions)
+			usersAPI.PUT("/me/password", h.ChangePassword)
+			usersAPI.POST("/:userCode/password-reset", h.IssuePasswordReset, h.IsAdmin)
 		}
 		coursesAPI := API.Group("/courses")
 		{
 			coursesAPI.GET("", h.SearchCourses)
 			coursesAPI.POST("", h.AddCourse, h.IsAdmin)
 			coursesAPI.GET("/:courseID", h.GetCourseDetail)
-			coursesAPI.PUT("/:courseID/status", h.SetCourseStatus, h.IsAdmin)
+			coursesAPI.PUT("/:courseID/status", h.SetCourseStatus, h.IsAdmin, h.IsCourseTeacher)
+			coursesAPI.GET("/:courseID/status/history", h.GetCourseStatusHistory, h.IsAdmin, h.IsCourseTeacher)
+			coursesAPI.PUT("/:courseID/teachers/:teacherCode", h.AddCourseTeacher, h.IsAdmin, h.IsCourseTeacher)
+			coursesAPI.DELETE("/:courseID/teachers/:teacherCode", h.RemoveCourseTeacher, h.IsAdmin, h.IsCourseTeacher)
+			coursesAPI.GET("/:courseID/statistics", h.GetCourseStatistics, h.IsAdmin, h.IsCourseTeacher)
+			coursesAPI.GET("/:courseID/grading-policy", h.GetGradingPolicy)
+			coursesAPI.PUT("/:courseID/grading-policy", h.SetGradingPolicy, h.IsAdmin, h.IsCourseTeacher)
+			coursesAPI.GET("/:courseID/requisites", h.GetCourseRequisites)
+			coursesAPI.PUT("/:courseID/requisites", h.SetCourseRequisites, h.IsAdmin, h.IsCourseTeacher)
+			coursesAPI.GET("/:courseID/lottery", h.GetCourseLottery)
+			coursesAPI.PUT("/:courseID/lottery", h.SetCourseLottery, h.IsAdmin, h.IsCourseTeacher)
 			coursesAPI.GET("/:courseID/classes", h.GetClasses)
-			coursesAPI.POST("/:courseID/classes", h.AddClass, h.IsAdmin)
+			coursesAPI.POST("/:courseID/classes", h.AddClass, h.IsAdmin, h.IsCourseTeacher)
 			coursesAPI.POST("/:courseID/classes/:classID/assignments", h.SubmitAssignment)
-			coursesAPI.PUT("/:courseID/classes/:classID/assignments/scores", h.RegisterScores, h.IsAdmin)
-			coursesAPI.GET("/:courseID/classes/:classID/assignments/export", h.DownloadSubmittedAssignments, h.IsAdmin)
+			coursesAPI.PUT("/:courseID/classes/:classID/assignments/scores", h.RegisterScores, h.IsAdmin, h.IsCourseTeacher)
+			coursesAPI.GET("/:courseID/classes/:classID/assignments/export", h.DownloadSubmittedAssignments, h.IsAdmin, h.IsCourseTeacher)
+			coursesAPI.PUT("/:courseID/classes/:classID/submission-status", h.SetSubmissionStatus, h.IsAdmin, h.IsCourseTeacher)
+		}
+		semestersAPI := API.Group("/semesters")
+		{
+			semestersAPI.GET("", h.GetSemesters)
+			semestersAPI.POST("", h.AddSemester, h.IsAdmin)
+			semestersAPI.PUT("/:semesterID/credit-limit", h.SetSemesterCreditLimit, h.IsAdmin)
 		}
 		announcementsAPI := API.Group("/announcements")
 		{
@@ -128,11 +236,7 @@ func (h *handlers) Initialize(c echo.Context) error {
 		}
 	}
 
-	if err := exec.Command("rm", "-rf", AssignmentsDirectory).Run(); err != nil {
-		c.Logger().Error(err)
-		return c.NoContent(http.StatusInternalServerError)
-	}
-	if err := exec.Command("cp", "-r", InitDataDirectory, AssignmentsDirectory).Run(); err != nil {
+	if err := resetStorage(c.Request().Context(), h.Storage, InitDataDirectory); err != nil {
 		c.Logger().Error(err)
 		return c.NoContent(http.StatusInternalServerError)
 	}
@@ -184,6 +288,62 @@ func (h *handlers) IsAdmin(next echo.HandlerFunc) echo.HandlerFunc {
 	}
 }
 
+// IsCourseTeacher 科目の担当教員確認用middleware
+func (h *handlers) IsCourseTeacher(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		userID, _, _, err := getUserInfo(c)
+		if err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+
+		courseID := c.Param("courseID")
+
+		var count int
+		if err := h.DB.Get(&count, "SELECT COUNT(*) FROM `courses` WHERE `id` = ?", courseID); err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		if count == 0 {
+			return c.String(http.StatusNotFound, "No such course.")
+		}
+
+		// 講義が指定されている場合はその科目に属する講義であることを確認する
+		if classID := c.Param("classID"); classID != "" {
+			var classCount int
+			if err := h.DB.Get(&classCount, "SELECT COUNT(*) FROM `classes` WHERE `id` = ? AND `course_id` = ?", classID, courseID); err != nil {
+				c.Logger().Error(err)
+				return c.NoContent(http.StatusInternalServerError)
+			}
+			if classCount == 0 {
+				return c.String(http.StatusNotFound, "No such class.")
+			}
+		}
+
+		isTeacher, err := isCourseTeacher(h.DB, courseID, userID)
+		if err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		if !isTeacher {
+			return c.String(http.StatusForbidden, "You are not a teacher of this course.")
+		}
+
+		return next(c)
+	}
+}
+
+// isCourseTeacher 科目の担当教員(共同担当を含む)かどうかを返す
+func isCourseTeacher(q sqlx.Queryer, courseID string, userID string) (bool, error) {
+	var isTeacher bool
+	query := "SELECT EXISTS(SELECT * FROM `courses` WHERE `id` = ? AND `teacher_id` = ?)" +
+		" OR EXISTS(SELECT * FROM `course_teachers` WHERE `course_id` = ? AND `teacher_id` = ?)"
+	if err := sqlx.Get(q, &isTeacher, query, courseID, userID, courseID, userID); err != nil {
+		return false, err
+	}
+	return isTeacher, nil
+}
+
 func getUserInfo(c echo.Context) (userID string, userName string, isAdmin bool, err error) {
 	sess, err := session.Get(SessionName, c)
 	if err != nil {
@@ -246,18 +406,27 @@ const (
 	StatusClosed       CourseStatus = "closed"
 )
 
+// courseStatusTransitions は各ステータスから遷移可能なステータスの一覧
+var courseStatusTransitions = map[CourseStatus][]CourseStatus{
+	StatusRegistration: {StatusInProgress},
+	StatusInProgress:   {StatusClosed},
+	StatusClosed:       {},
+}
+
 type Course struct {
-	ID          string       `db:"id"`
-	Code        string       `db:"code"`
-	Type        CourseType   `db:"type"`
-	Name        string       `db:"name"`
-	Description string       `db:"description"`
-	Credit      uint8        `db:"credit"`
-	Period      uint8        `db:"period"`
-	DayOfWeek   DayOfWeek    `db:"day_of_week"`
-	TeacherID   string       `db:"teacher_id"`
-	Keywords    string       `db:"keywords"`
-	Status      CourseStatus `db:"status"`
+	ID          string         `db:"id"`
+	Code        string         `db:"code"`
+	Type        CourseType     `db:"type"`
+	Name        string         `db:"name"`
+	Description string         `db:"description"`
+	Credit      uint8          `db:"credit"`
+	Period      uint8          `db:"period"`
+	DayOfWeek   DayOfWeek      `db:"day_of_week"`
+	TeacherID   string         `db:"teacher_id"`
+	Keywords    string         `db:"keywords"`
+	Status      CourseStatus   `db:"status"`
+	Capacity    uint16         `db:"capacity"`
+	SemesterID  sql.NullString `db:"semester_id"` // NULL の場合は現在の学期
 }
 
 // ---------- Public API ----------
@@ -267,6 +436,28 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
+// passwordAttemptRetryAfter 学内コードとIPアドレスのいずれかがロックアウト中であれば解除までの時間を返す
+func (h *handlers) passwordAttemptRetryAfter(codeKey string, ipKey string) (time.Duration, error) {
+	for _, limit := range []struct {
+		limiter RateLimiter
+		key     string
+	}{{h.LoginCodeLimiter, codeKey}, {h.LoginIPLimiter, ipKey}} {
+		retryAfter, err := limit.limiter.Check(limit.key)
+		if err != nil || retryAfter > 0 {
+			return retryAfter, err
+		}
+	}
+	return 0, nil
+}
+
+// failPasswordAttempt パスワードの検証の失敗を学内コードとIPアドレスの両方に記録する
+func (h *handlers) failPasswordAttempt(codeKey string, ipKey string) error {
+	if err := h.LoginCodeLimiter.Fail(codeKey); err != nil {
+		return err
+	}
+	return h.LoginIPLimiter.Fail(ipKey)
+}
+
 // Login POST /login ログイン
 func (h *handlers) Login(c echo.Context) error {
 	var req LoginRequest
@@ -274,16 +465,40 @@ func (h *handlers) Login(c echo.Context) error {
 		return c.String(http.StatusBadRequest, "Invalid format.")
 	}
 
+	// ロックアウト中はパスワードの検証を行わずに拒否する
+	codeKey := "code:" + req.Code
+	ipKey := "ip:" + c.RealIP()
+	if retryAfter, err := h.passwordAttemptRetryAfter(codeKey, ipKey); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	} else if retryAfter > 0 {
+		c.Response().Header().Set("Retry-After", retryAfterSeconds(retryAfter))
+		return c.String(http.StatusTooManyRequests, "Too many failed login attempts.")
+	}
+	loginFailed := func() error {
+		if err := h.failPasswordAttempt(codeKey, ipKey); err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		return c.String(http.StatusUnauthorized, "Code or Password is wrong.")
+	}
+
 	var user User
 	if err := h.DB.Get(&user, "SELECT * FROM `users` WHERE `code` = ?", req.Code); err != nil && err != sql.ErrNoRows {
 		c.Logger().Error(err)
 		return c.NoContent(http.StatusInternalServerError)
 	} else if err == sql.ErrNoRows {
-		return c.String(http.StatusUnauthorized, "Code or Password is wrong.")
+		return loginFailed()
 	}
 
 	if bcrypt.CompareHashAndPassword(user.HashedPassword, []byte(req.Password)) != nil {
-		return c.String(http.StatusUnauthorized, "Code or Password is wrong.")
+		return loginFailed()
+	}
+
+	// IPアドレスの記録は他のユーザの成功ではリセットしない
+	if err := h.LoginCodeLimiter.Reset(codeKey); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
 	}
 
 	sess, err := session.Get(SessionName, c)
@@ -296,6 +511,15 @@ func (h *handlers) Login(c echo.Context) error {
 		return c.String(http.StatusBadRequest, "You are already logged in.")
 	}
 
+	// 別のユーザのセッションは破棄して新しいセッションIDを発行する
+	if !sess.IsNew {
+		if err := h.Sessions.Backend.Delete(sess.ID); err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		sess.ID = ""
+	}
+
 	sess.Values["userID"] = user.ID
 	sess.Values["userName"] = user.Name
 	sess.Values["isAdmin"] = user.Type == Teacher
@@ -333,6 +557,79 @@ func (h *handlers) Logout(c echo.Context) error {
 	return c.NoContent(http.StatusOK)
 }
 
+type RedeemPasswordResetRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+// RedeemPasswordReset POST /password-reset パスワードリセット用トークンを使ってパスワードを再設定
+func (h *handlers) RedeemPasswordReset(c echo.Context) error {
+	var req RedeemPasswordResetRequest
+	if err := c.Bind(&req); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid format.")
+	}
+	if len(req.NewPassword) < minPasswordLength {
+		return c.String(http.StatusBadRequest, "Password is too short.")
+	}
+
+	tx, err := h.DB.Beginx()
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	defer tx.Rollback()
+
+	var user User
+	query := "SELECT `users`.* FROM `users`" +
+		" JOIN `password_reset_tokens` ON `users`.`id` = `password_reset_tokens`.`user_id`" +
+		" WHERE `password_reset_tokens`.`token_hash` = ? AND `password_reset_tokens`.`used_at` IS NULL AND `password_reset_tokens`.`expires_at` > NOW(6)" +
+		" FOR UPDATE"
+	if err := tx.Get(&user, query, hashPasswordResetToken(req.Token)); err != nil && err != sql.ErrNoRows {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	} else if err == sql.ErrNoRows {
+		return c.String(http.StatusBadRequest, "Invalid or expired token.")
+	}
+
+	// 有効なトークンの場合のみハッシュ化する
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), passwordHashCost)
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	if _, err := tx.Exec("UPDATE `password_reset_tokens` SET `used_at` = NOW(6) WHERE `token_hash` = ?", hashPasswordResetToken(req.Token)); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	if _, err := tx.Exec("UPDATE `users` SET `hashed_password` = ? WHERE `id` = ?", hashedPassword, user.ID); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	// パスワードが変わったので既存のセッションとログイン失敗の記録は全て破棄する
+	if err := h.Sessions.Backend.DeleteByUserID(user.ID); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	if err := h.LoginCodeLimiter.Reset("code:" + user.Code); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func hashPasswordResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 // ---------- Users API ----------
 
 type GetMeResponse struct {
@@ -362,162 +659,147 @@ func (h *handlers) GetMe(c echo.Context) error {
 	})
 }
 
-type GetRegisteredCourseResponseContent struct {
-	ID        string    `json:"id"`
-	Name      string    `json:"name"`
-	Teacher   string    `json:"teacher"`
-	Period    uint8     `json:"period"`
-	DayOfWeek DayOfWeek `json:"day_of_week"`
+type ActiveSession struct {
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	IsCurrent bool      `json:"is_current"`
 }
 
-// GetRegisteredCourses GET /api/users/me/courses 履修中の科目一覧取得
-func (h *handlers) GetRegisteredCourses(c echo.Context) error {
-	userID, _, _, err := getUserInfo(c)
+// GetSessions GET /api/users/me/sessions 自身の有効なセッション一覧を取得
+func (h *handlers) GetSessions(c echo.Context) error {
+	sess, err := session.Get(SessionName, c)
 	if err != nil {
 		c.Logger().Error(err)
 		return c.NoContent(http.StatusInternalServerError)
 	}
-
-	tx, err := h.DB.Beginx()
+	userID, _, _, err := getUserInfo(c)
 	if err != nil {
 		c.Logger().Error(err)
 		return c.NoContent(http.StatusInternalServerError)
 	}
-	defer tx.Rollback()
 
-	var courses []Course
-	query := "SELECT `courses`.*" +
-		" FROM `courses`" +
-		" JOIN `registrations` ON `courses`.`id` = `registrations`.`course_id`" +
-		" WHERE `courses`.`status` != ? AND `registrations`.`user_id` = ?"
-	if err := tx.Select(&courses, query, StatusClosed, userID); err != nil {
+	records, err := h.Sessions.Backend.ListByUserID(userID)
+	if err != nil {
 		c.Logger().Error(err)
 		return c.NoContent(http.StatusInternalServerError)
 	}
 
-	// 履修科目が0件の時は空配列を返却
-	res := make([]GetRegisteredCourseResponseContent, 0, len(courses))
-	for _, course := range courses {
-		var teacher User
-		if err := tx.Get(&teacher, "SELECT * FROM `users` WHERE `id` = ?", course.TeacherID); err != nil {
-			c.Logger().Error(err)
-			return c.NoContent(http.StatusInternalServerError)
-		}
-
-		res = append(res, GetRegisteredCourseResponseContent{
-			ID:        course.ID,
-			Name:      course.Name,
-			Teacher:   teacher.Name,
-			Period:    course.Period,
-			DayOfWeek: course.DayOfWeek,
+	res := make([]ActiveSession, 0, len(records))
+	for _, record := range records {
+		res = append(res, ActiveSession{
+			UserAgent: record.UserAgent,
+			CreatedAt: record.CreatedAt,
+			ExpiresAt: record.ExpiresAt,
+			IsCurrent: record.ID == sess.ID,
 		})
 	}
 
-	if err := tx.Commit(); err != nil {
+	return c.JSON(http.StatusOK, res)
+}
+
+// RevokeSessions POST /api/users/me/sessions/revoke 自身の全てのセッションを無効化
+func (h *handlers) RevokeSessions(c echo.Context) error {
+	sess, err := session.Get(SessionName, c)
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	userID, _, _, err := getUserInfo(c)
+	if err != nil {
 		c.Logger().Error(err)
 		return c.NoContent(http.StatusInternalServerError)
 	}
 
-	return c.JSON(http.StatusOK, res)
-}
+	if err := h.Sessions.Backend.DeleteByUserID(userID); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
 
-type RegisterCourseRequestContent struct {
-	ID string `json:"id"`
+	// 現在のセッションのcookieも破棄する
+	sess.Options = &sessions.Options{
+		Path:   "/",
+		MaxAge: -1,
+	}
+	if err := sess.Save(c.Request(), c.Response()); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.NoContent(http.StatusOK)
 }
 
-type RegisterCoursesErrorResponse struct {
-	CourseNotFound       []string `json:"course_not_found,omitempty"`
-	NotRegistrableStatus []string `json:"not_registrable_status,omitempty"`
-	ScheduleConflict     []string `json:"schedule_conflict,omitempty"`
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
 }
 
-// RegisterCourses PUT /api/users/me/courses 履修登録
-func (h *handlers) RegisterCourses(c echo.Context) error {
+// ChangePassword PUT /api/users/me/password 自身のパスワードを変更
+func (h *handlers) ChangePassword(c echo.Context) error {
+	sess, err := session.Get(SessionName, c)
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
 	userID, _, _, err := getUserInfo(c)
 	if err != nil {
 		c.Logger().Error(err)
 		return c.NoContent(http.StatusInternalServerError)
 	}
 
-	var req []RegisterCourseRequestContent
+	var req ChangePasswordRequest
 	if err := c.Bind(&req); err != nil {
 		return c.String(http.StatusBadRequest, "Invalid format.")
 	}
-	sort.Slice(req, func(i, j int) bool {
-		return req[i].ID < req[j].ID
-	})
+	if len(req.NewPassword) < minPasswordLength {
+		return c.String(http.StatusBadRequest, "Password is too short.")
+	}
 
-	tx, err := h.DB.Beginx()
-	if err != nil {
+	var user User
+	if err := h.DB.Get(&user, "SELECT * FROM `users` WHERE `id` = ?", userID); err != nil {
 		c.Logger().Error(err)
 		return c.NoContent(http.StatusInternalServerError)
 	}
-	defer tx.Rollback()
-
-	var errors RegisterCoursesErrorResponse
-	var newlyAdded []Course
-	for _, courseReq := range req {
-		courseID := courseReq.ID
-		var course Course
-		if err := tx.Get(&course, "SELECT * FROM `courses` WHERE `id` = ? FOR SHARE", courseID); err != nil && err != sql.ErrNoRows {
-			c.Logger().Error(err)
-			return c.NoContent(http.StatusInternalServerError)
-		} else if err == sql.ErrNoRows {
-			errors.CourseNotFound = append(errors.CourseNotFound, courseReq.ID)
-			continue
-		}
-
-		if course.Status != StatusRegistration {
-			errors.NotRegistrableStatus = append(errors.NotRegistrableStatus, course.ID)
-			continue
-		}
 
-		// すでに履修登録済みの科目は無視する
-		var count int
-		if err := tx.Get(&count, "SELECT COUNT(*) FROM `registrations` WHERE `course_id` = ? AND `user_id` = ?", course.ID, userID); err != nil {
+	// 現在のパスワードの検証はログインと同じ制限を受ける
+	codeKey := "code:" + user.Code
+	ipKey := "ip:" + c.RealIP()
+	if retryAfter, err := h.passwordAttemptRetryAfter(codeKey, ipKey); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	} else if retryAfter > 0 {
+		c.Response().Header().Set("Retry-After", retryAfterSeconds(retryAfter))
+		return c.String(http.StatusTooManyRequests, "Too many failed password attempts.")
+	}
+	if bcrypt.CompareHashAndPassword(user.HashedPassword, []byte(req.CurrentPassword)) != nil {
+		if err := h.failPasswordAttempt(codeKey, ipKey); err != nil {
 			c.Logger().Error(err)
 			return c.NoContent(http.StatusInternalServerError)
 		}
-		if count > 0 {
-			continue
-		}
-
-		newlyAdded = append(newlyAdded, course)
+		return c.String(http.StatusBadRequest, "Current password is wrong.")
 	}
-
-	var alreadyRegistered []Course
-	query := "SELECT `courses`.*" +
-		" FROM `courses`" +
-		" JOIN `registrations` ON `courses`.`id` = `registrations`.`course_id`" +
-		" WHERE `courses`.`status` != ? AND `registrations`.`user_id` = ?"
-	if err := tx.Select(&alreadyRegistered, query, StatusClosed, userID); err != nil {
+	if err := h.LoginCodeLimiter.Reset(codeKey); err != nil {
 		c.Logger().Error(err)
 		return c.NoContent(http.StatusInternalServerError)
 	}
 
-	alreadyRegistered = append(alreadyRegistered, newlyAdded...)
-	for _, course1 := range newlyAdded {
-		for _, course2 := range alreadyRegistered {
-			if course1.ID != course2.ID && course1.Period == course2.Period && course1.DayOfWeek == course2.DayOfWeek {
-				errors.ScheduleConflict = append(errors.ScheduleConflict, course1.ID)
-				break
-			}
-		}
+	newHashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), passwordHashCost)
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
 	}
-
-	if len(errors.CourseNotFound) > 0 || len(errors.NotRegistrableStatus) > 0 || len(errors.ScheduleConflict) > 0 {
-		return c.JSON(http.StatusBadRequest, errors)
+	if _, err := h.DB.Exec("UPDATE `users` SET `hashed_password` = ? WHERE `id` = ?", newHashedPassword, userID); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
 	}
 
-	for _, course := range newlyAdded {
-		_, err = tx.Exec("INSERT INTO `registrations` (`course_id`, `user_id`) VALUES (?, ?) ON DUPLICATE KEY UPDATE `course_id` = VALUES(`course_id`), `user_id` = VALUES(`user_id`)", course.ID, userID)
-		if err != nil {
-			c.Logger().Error(err)
-			return c.NoContent(http.StatusInternalServerError)
-		}
+	// 他のセッションは全て破棄し、現在のセッションは新しいセッションIDで継続する
+	if err := h.Sessions.Backend.DeleteByUserID(userID); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
 	}
-
-	if err = tx.Commit(); err != nil {
+	sess.ID = ""
+	if err := sess.Save(c.Request(), c.Response()); err != nil {
 		c.Logger().Error(err)
 		return c.NoContent(http.StatusInternalServerError)
 	}
@@ -525,407 +807,2453 @@ func (h *handlers) RegisterCourses(c echo.Context) error {
 	return c.NoContent(http.StatusOK)
 }
 
-type Class struct {
-	ID               string `db:"id"`
-	CourseID         string `db:"course_id"`
-	Part             uint8  `db:"part"`
-	Title            string `db:"title"`
-	Description      string `db:"description"`
-	SubmissionClosed bool   `db:"submission_closed"`
+type IssuePasswordResetResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
 }
 
-type GetGradeResponse struct {
-	Summary       Summary        `json:"summary"`
-	CourseResults []CourseResult `json:"courses"`
-}
+// IssuePasswordReset POST /api/users/:userCode/password-reset パスワードリセット用トークンを発行
+func (h *handlers) IssuePasswordReset(c echo.Context) error {
+	issuerID, _, _, err := getUserInfo(c)
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
 
-type Summary struct {
-	Credits   int     `json:"credits"`
-	GPA       float64 `json:"gpa"`
-	GpaTScore float64 `json:"gpa_t_score"` // 偏差値
-	GpaAvg    float64 `json:"gpa_avg"`     // 平均値
-	GpaMax    float64 `json:"gpa_max"`     // 最大値
-	GpaMin    float64 `json:"gpa_min"`     // 最小値
+	userCode := c.Param("userCode")
+
+	var user User
+	if err := h.DB.Get(&user, "SELECT * FROM `users` WHERE `code` = ?", userCode); err != nil && err != sql.ErrNoRows {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	} else if err == sql.ErrNoRows {
+		return c.String(http.StatusNotFound, "No such user.")
+	}
+	// 教員のアカウントは他の教員に乗っ取られないよう対象外とする
+	if user.Type == Teacher {
+		return c.String(http.StatusForbidden, "Password reset tokens can only be issued for students.")
+	}
+
+	token, err := newRandomToken()
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	expiresAt := time.Now().Add(passwordResetTokenTTL)
+
+	tx, err := h.DB.Beginx()
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	defer tx.Rollback()
+
+	// 未使用のトークンは新しいトークンの発行により無効になる
+	if _, err := tx.Exec("DELETE FROM `password_reset_tokens` WHERE `user_id` = ? AND `used_at` IS NULL", user.ID); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	if _, err := tx.Exec("INSERT INTO `password_reset_tokens` (`token_hash`, `user_id`, `issuer_id`, `expires_at`) VALUES (?, ?, ?, ?)",
+		hashPasswordResetToken(token), user.ID, issuerID, expiresAt); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.JSON(http.StatusCreated, IssuePasswordResetResponse{
+		Token:     token,
+		ExpiresAt: expiresAt,
+	})
 }
 
-type CourseResult struct {
-	Name             string       `json:"name"`
-	Code             string       `json:"code"`
-	TotalScore       int          `json:"total_score"`
-	TotalScoreTScore float64      `json:"total_score_t_score"` // 偏差値
-	TotalScoreAvg    float64      `json:"total_score_avg"`     // 平均値
-	TotalScoreMax    int          `json:"total_score_max"`     // 最大値
-	TotalScoreMin    int          `json:"total_score_min"`     // 最小値
-	ClassScores      []ClassScore `json:"class_scores"`
+type AddUserRequest struct {
+	Code     string   `json:"code"`
+	Name     string   `json:"name"`
+	Password string   `json:"password"`
+	Type     UserType `json:"type"`
 }
 
-type ClassScore struct {
-	ClassID    string `json:"class_id"`
-	Title      string `json:"title"`
-	Part       uint8  `json:"part"`
-	Score      *int   `json:"score"`      // 0~100点
-	Submitters int    `json:"submitters"` // 提出した学生数
+type AddUserResponse struct {
+	ID string `json:"id"`
 }
 
-// GetGrades GET /api/users/me/grades 成績取得
-func (h *handlers) GetGrades(c echo.Context) error {
-	userID, _, _, err := getUserInfo(c)
+// AddUser POST /api/users 新規ユーザ登録
+func (h *handlers) AddUser(c echo.Context) error {
+	var req AddUserRequest
+	if err := c.Bind(&req); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid format.")
+	}
+	if message := validateNewUser(req.Code, req.Name, req.Password, req.Type); message != "" {
+		return c.String(http.StatusBadRequest, message)
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), passwordHashCost)
 	if err != nil {
 		c.Logger().Error(err)
 		return c.NoContent(http.StatusInternalServerError)
 	}
 
-	// 履修している科目一覧取得
-	var registeredCourses []Course
-	query := "SELECT `courses`.*" +
-		" FROM `registrations`" +
-		" JOIN `courses` ON `registrations`.`course_id` = `courses`.`id`" +
-		" WHERE `user_id` = ?"
-	if err := h.DB.Select(&registeredCourses, query, userID); err != nil {
+	userID := newULID()
+	if _, err := h.DB.Exec("INSERT INTO `users` (`id`, `code`, `name`, `hashed_password`, `type`) VALUES (?, ?, ?, ?, ?)",
+		userID, req.Code, req.Name, hashedPassword, req.Type); err != nil {
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == uint16(mysqlErrNumDuplicateEntry) {
+			return c.String(http.StatusConflict, "A user with the same code already exists.")
+		}
 		c.Logger().Error(err)
 		return c.NoContent(http.StatusInternalServerError)
 	}
 
-	// 科目毎の成績計算処理
-	courseResults := make([]CourseResult, 0, len(registeredCourses))
-	myGPA := 0.0
-	myCredits := 0
-	for _, course := range registeredCourses {
-		// 講義一覧の取得
-		var classes []Class
-		query = "SELECT *" +
-			" FROM `classes`" +
-			" WHERE `course_id` = ?" +
-			" ORDER BY `part` DESC"
-		if err := h.DB.Select(&classes, query, course.ID); err != nil {
+	return c.JSON(http.StatusCreated, AddUserResponse{ID: userID})
+}
+
+// validateNewUser 新規ユーザの入力を検証し、不正な場合はエラーメッセージを返す
+func validateNewUser(code string, name string, password string, userType UserType) string {
+	if len(code) != 6 {
+		return "Invalid code."
+	}
+	if name == "" || utf8.RuneCountInString(name) > 255 {
+		return "Invalid name."
+	}
+	if len(password) < minPasswordLength {
+		return "Password is too short."
+	}
+	if userType != Student && userType != Teacher {
+		return "Invalid type."
+	}
+	return ""
+}
+
+type ImportUsersResponse struct {
+	Created int               `json:"created"`
+	Skipped int               `json:"skipped"`
+	Errors  []ImportUserError `json:"errors"`
+}
+
+type ImportUserError struct {
+	Line    int    `json:"line"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type importUserRow struct {
+	line           int
+	user           User
+	password       string
+	hashedPassword []byte
+}
+
+// ImportUsers POST /api/users/import?type=(student|teacher) TSVまたはCSVからユーザを一括登録
+// 各行は ID, 学内コード, 氏名, パスワード の4列で、IDが空の行にはULIDを割り当てる
+// 既に登録済みの学内コードの行は何もせずスキップする
+func (h *handlers) ImportUsers(c echo.Context) error {
+	userType := UserType(c.QueryParam("type"))
+	if userType != Student && userType != Teacher {
+		return c.String(http.StatusBadRequest, "Invalid type.")
+	}
+
+	reader := csv.NewReader(c.Request().Body)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	if mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType)); mediaType != "text/csv" {
+		reader.Comma = '\t'
+	}
+
+	res := ImportUsersResponse{
+		Errors: make([]ImportUserError, 0),
+	}
+	rows := make([]*importUserRow, 0)
+	seen := make(map[string]bool)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if parseErr, ok := err.(*csv.ParseError); ok {
+				return c.String(http.StatusBadRequest, fmt.Sprintf("Invalid format at line %d.", parseErr.Line))
+			}
 			c.Logger().Error(err)
 			return c.NoContent(http.StatusInternalServerError)
 		}
+		line, _ := reader.FieldPos(0)
 
-		// 講義毎の成績計算処理
-		classScores := make([]ClassScore, 0, len(classes))
-		var myTotalScore int
-		for _, class := range classes {
-			var submissionsCount int
-			if err := h.DB.Get(&submissionsCount, "SELECT COUNT(*) FROM `submissions` WHERE `class_id` = ?", class.ID); err != nil {
-				c.Logger().Error(err)
-				return c.NoContent(http.StatusInternalServerError)
+		if len(record) != 4 {
+			res.Errors = append(res.Errors, ImportUserError{Line: line, Message: "Invalid number of columns."})
+			continue
+		}
+		id, code, name, password := record[0], record[1], record[2], record[3]
+		if message := validateNewUser(code, name, password, userType); message != "" {
+			res.Errors = append(res.Errors, ImportUserError{Line: line, Code: code, Message: message})
+			continue
+		}
+		if id == "" {
+			id = newULID()
+		} else if _, err := ulid.ParseStrict(id); err != nil {
+			res.Errors = append(res.Errors, ImportUserError{Line: line, Code: code, Message: "Invalid id."})
+			continue
+		}
+		if seen[code] {
+			res.Errors = append(res.Errors, ImportUserError{Line: line, Code: code, Message: "Duplicate code in the file."})
+			continue
+		}
+		seen[code] = true
+
+		rows = append(rows, &importUserRow{
+			line: line,
+			user: User{
+				ID:   id,
+				Code: code,
+				Name: name,
+				Type: userType,
+			},
+			password: password,
+		})
+	}
+
+	// 登録済みの学内コードはパスワードのハッシュ化の前に除外する
+	if len(rows) > 0 {
+		codes := make([]string, 0, len(rows))
+		for _, row := range rows {
+			codes = append(codes, row.user.Code)
+		}
+		query, args, err := sqlx.In("SELECT `code` FROM `users` WHERE `code` IN (?)", codes)
+		if err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		var existingCodes []string
+		if err := h.DB.Select(&existingCodes, query, args...); err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		existing := make(map[string]bool, len(existingCodes))
+		for _, code := range existingCodes {
+			existing[code] = true
+		}
+		newRows := make([]*importUserRow, 0, len(rows))
+		for _, row := range rows {
+			if existing[row.user.Code] {
+				res.Skipped++
+				continue
 			}
+			newRows = append(newRows, row)
+		}
+		rows = newRows
+	}
+
+	if err := hashImportUserPasswords(rows); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
 
-			var myScore sql.NullInt64
-			if err := h.DB.Get(&myScore, "SELECT `submissions`.`score` FROM `submissions` WHERE `user_id` = ? AND `class_id` = ?", userID, class.ID); err != nil && err != sql.ErrNoRows {
+	tx, err := h.DB.Beginx()
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	defer tx.Rollback()
+
+	for _, row := range rows {
+		result, err := tx.Exec("INSERT IGNORE INTO `users` (`id`, `code`, `name`, `hashed_password`, `type`) VALUES (?, ?, ?, ?, ?)",
+			row.user.ID, row.user.Code, row.user.Name, row.hashedPassword, row.user.Type)
+		if err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		if affected == 0 {
+			// 同時に登録された学内コードか、既存のユーザとIDが重複している
+			var count int
+			if err := tx.Get(&count, "SELECT COUNT(*) FROM `users` WHERE `code` = ?", row.user.Code); err != nil {
 				c.Logger().Error(err)
 				return c.NoContent(http.StatusInternalServerError)
-			} else if err == sql.ErrNoRows || !myScore.Valid {
-				classScores = append(classScores, ClassScore{
-					ClassID:    class.ID,
-					Part:       class.Part,
-					Title:      class.Title,
-					Score:      nil,
-					Submitters: submissionsCount,
-				})
+			}
+			if count > 0 {
+				res.Skipped++
 			} else {
-				score := int(myScore.Int64)
-				myTotalScore += score
-				classScores = append(classScores, ClassScore{
-					ClassID:    class.ID,
-					Part:       class.Part,
-					Title:      class.Title,
-					Score:      &score,
-					Submitters: submissionsCount,
-				})
+				res.Errors = append(res.Errors, ImportUserError{Line: row.line, Code: row.user.Code, Message: "Duplicate id."})
 			}
+			continue
 		}
+		res.Created++
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	sort.Slice(res.Errors, func(i, j int) bool {
+		return res.Errors[i].Line < res.Errors[j].Line
+	})
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// hashImportUserPasswords CPU数を上限とした並列数でパスワードをハッシュ化する
+func hashImportUserPasswords(rows []*importUserRow) error {
+	jobs := make(chan *importUserRow)
+	errs := make(chan error, 1)
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for row := range jobs {
+				hashedPassword, err := bcrypt.GenerateFromPassword([]byte(row.password), passwordHashCost)
+				if err != nil {
+					select {
+					case errs <- err:
+					default:
+					}
+					continue
+				}
+				row.hashedPassword = hashedPassword
+			}
+		}()
+	}
+	for _, row := range rows {
+		jobs <- row
+	}
+	close(jobs)
+	wg.Wait()
+
+	select {
+	case err := <-errs:
+		return err
+	default:
+		return nil
+	}
+}
+
+type GetRegisteredCourseResponseContent struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Teacher   string    `json:"teacher"`
+	Period    uint8     `json:"period"`
+	DayOfWeek DayOfWeek `json:"day_of_week"`
+}
+
+// GetRegisteredCourses GET /api/users/me/courses 履修中の科目一覧取得
+func (h *handlers) GetRegisteredCourses(c echo.Context) error {
+	userID, _, _, err := getUserInfo(c)
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	tx, err := h.DB.Beginx()
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	defer tx.Rollback()
+
+	var courses []Course
+	query := "SELECT `courses`.*" +
+		" FROM `courses`" +
+		" JOIN `registrations` ON `courses`.`id` = `registrations`.`course_id`" +
+		" WHERE `courses`.`status` != ? AND `registrations`.`user_id` = ?"
+	args := []interface{}{StatusClosed, userID}
+	// semester を指定した場合はその学期の科目に絞り込む
+	if semesterID := c.QueryParam("semester"); semesterID != "" {
+		query += " AND " + courseSemesterIDColumn + " = ?"
+		args = append(args, semesterID)
+	}
+	if err := tx.Select(&courses, query, args...); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	// 履修科目が0件の時は空配列を返却
+	res := make([]GetRegisteredCourseResponseContent, 0, len(courses))
+	for _, course := range courses {
+		var teacher User
+		if err := tx.Get(&teacher, "SELECT * FROM `users` WHERE `id` = ?", course.TeacherID); err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+
+		res = append(res, GetRegisteredCourseResponseContent{
+			ID:        course.ID,
+			Name:      course.Name,
+			Teacher:   teacher.Name,
+			Period:    course.Period,
+			DayOfWeek: course.DayOfWeek,
+		})
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// GetTimetable GET /api/users/me/timetable 履修中の科目の時間割取得
+func (h *handlers) GetTimetable(c echo.Context) error {
+	userID, _, _, err := getUserInfo(c)
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	format := c.QueryParam("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "ics" {
+		return c.String(http.StatusBadRequest, "Unknown format.")
+	}
+
+	tx, err := h.DB.Beginx()
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	defer tx.Rollback()
+
+	var cells []*TimetableCell
+	query := "SELECT `courses`.`id` AS `course_id`, `courses`.`code`, `courses`.`name`, `users`.`name` AS `teacher`, `courses`.`period`, `courses`.`day_of_week`" +
+		" FROM `courses`" +
+		" JOIN `registrations` ON `courses`.`id` = `registrations`.`course_id`" +
+		" JOIN `users` ON `courses`.`teacher_id` = `users`.`id`" +
+		" WHERE `courses`.`status` != ? AND `registrations`.`user_id` = ?"
+	if err := tx.Select(&cells, query, StatusClosed, userID); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	if len(cells) > 0 && format == "json" {
+		courseIDs := make([]string, 0, len(cells))
+		cellByCourse := make(map[string]*TimetableCell, len(cells))
+		for _, cell := range cells {
+			courseIDs = append(courseIDs, cell.CourseID)
+			cellByCourse[cell.CourseID] = cell
+		}
+
+		var classes []struct {
+			ID               string `db:"id"`
+			CourseID         string `db:"course_id"`
+			Part             uint8  `db:"part"`
+			Title            string `db:"title"`
+			SubmissionClosed bool   `db:"submission_closed"`
+			ClassDeadline
+			Submitted bool `db:"submitted"`
+		}
+		query, args, err := sqlx.In("SELECT `classes`.`id`, `classes`.`course_id`, `classes`.`part`, `classes`.`title`, `classes`.`submission_closed`,"+
+			" `classes`.`deadline`, `classes`.`late_until`, `classes`.`late_penalty`, `submissions`.`user_id` IS NOT NULL AS `submitted`"+
+			" FROM `classes`"+
+			" LEFT JOIN `submissions` ON `classes`.`id` = `submissions`.`class_id` AND `submissions`.`user_id` = ?"+
+			" WHERE `classes`.`course_id` IN (?)"+
+			" ORDER BY `classes`.`course_id`, `classes`.`part`", userID, courseIDs)
+		if err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		if err := tx.Select(&classes, query, args...); err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+
+		now := time.Now()
+		for _, class := range classes {
+			cell := cellByCourse[class.CourseID]
+			cell.ClassCount++
+			accepted, _ := class.acceptSubmission(now)
+			if cell.NextAssignment == nil && !class.SubmissionClosed && accepted && !class.Submitted {
+				cell.NextAssignment = &TimetableAssignment{
+					ClassID: class.ID,
+					Part:    class.Part,
+					Title:   class.Title,
+				}
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	timetable := newTimetable()
+	for _, cell := range cells {
+		putTimetableCell(timetable, cell)
+	}
+
+	if format == "ics" {
+		var buf bytes.Buffer
+		if err := writeTimetableICS(&buf, h.Timetable, timetable, time.Now()); err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		c.Response().Header().Set(echo.HeaderContentDisposition, "attachment; filename=\"timetable.ics\"")
+		return c.Blob(http.StatusOK, "text/calendar; charset=utf-8", buf.Bytes())
+	}
+
+	return c.JSON(http.StatusOK, GetTimetableResponse{
+		Timetable: timetable,
+		Periods:   h.Timetable.Periods,
+	})
+}
+
+type RegisterCourseRequestContent struct {
+	ID string `json:"id"`
+}
+
+type RegisterCoursesErrorResponse struct {
+	CourseNotFound       []string `json:"course_not_found,omitempty"`
+	NotRegistrableStatus []string `json:"not_registrable_status,omitempty"`
+	ScheduleConflict     []string `json:"schedule_conflict,omitempty"`
+	CapacityExceeded     []string `json:"capacity_exceeded,omitempty"`
+	PrerequisiteNotMet   []string `json:"prerequisite_not_met,omitempty"`
+	CorequisiteNotMet    []string `json:"corequisite_not_met,omitempty"`
+	CreditLimitExceeded  []string `json:"credit_limit_exceeded,omitempty"`
+	LotteryCourse        []string `json:"lottery_course,omitempty"`
+}
+
+// RegisterCoursesResponse 定員に達していたためキャンセル待ちに登録した科目
+type RegisterCoursesResponse struct {
+	Waitlisted []string `json:"waitlisted"`
+}
+
+// RegisterCourses PUT /api/users/me/courses 履修登録
+func (h *handlers) RegisterCourses(c echo.Context) error {
+	userID, _, _, err := getUserInfo(c)
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	var req []RegisterCourseRequestContent
+	if err := c.Bind(&req); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid format.")
+	}
+	sort.Slice(req, func(i, j int) bool {
+		return req[i].ID < req[j].ID
+	})
+	// 同じ科目が複数回指定された場合は一度だけ扱う
+	uniqueReq := req[:0]
+	for _, courseReq := range req {
+		if len(uniqueReq) == 0 || courseReq.ID != uniqueReq[len(uniqueReq)-1].ID {
+			uniqueReq = append(uniqueReq, courseReq)
+		}
+	}
+	req = uniqueReq
+
+	tx, err := h.DB.Beginx()
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	defer tx.Rollback()
+
+	var errors RegisterCoursesErrorResponse
+	var newlyAdded []Course
+	for _, courseReq := range req {
+		courseID := courseReq.ID
+		// 定員の判定を直列化するため科目の行を排他ロックする
+		var course Course
+		if err := tx.Get(&course, "SELECT * FROM `courses` WHERE `id` = ? FOR UPDATE", courseID); err != nil && err != sql.ErrNoRows {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		} else if err == sql.ErrNoRows {
+			errors.CourseNotFound = append(errors.CourseNotFound, courseReq.ID)
+			continue
+		}
+
+		if course.Status != StatusRegistration {
+			errors.NotRegistrableStatus = append(errors.NotRegistrableStatus, course.ID)
+			continue
+		}
+
+		// 抽選の科目には希望を申し込む
+		var lotteryCount int
+		if err := tx.Get(&lotteryCount, "SELECT COUNT(*) FROM `course_lotteries` WHERE `course_id` = ?", course.ID); err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		if lotteryCount > 0 {
+			errors.LotteryCourse = append(errors.LotteryCourse, course.ID)
+			continue
+		}
+
+		// すでに履修登録済みの科目は無視する
+		var count int
+		if err := tx.Get(&count, "SELECT COUNT(*) FROM `registrations` WHERE `course_id` = ? AND `user_id` = ?", course.ID, userID); err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		if count > 0 {
+			continue
+		}
+
+		newlyAdded = append(newlyAdded, course)
+	}
+
+	var alreadyRegistered []Course
+	query := "SELECT `courses`.*" +
+		" FROM `courses`" +
+		" JOIN `registrations` ON `courses`.`id` = `registrations`.`course_id`" +
+		" WHERE `courses`.`status` != ? AND `registrations`.`user_id` = ?"
+	if err := tx.Select(&alreadyRegistered, query, StatusClosed, userID); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	alreadyRegistered = append(alreadyRegistered, newlyAdded...)
+	for _, course1 := range newlyAdded {
+		for _, course2 := range alreadyRegistered {
+			if course1.ID != course2.ID && course1.Period == course2.Period && course1.DayOfWeek == course2.DayOfWeek {
+				errors.ScheduleConflict = append(errors.ScheduleConflict, course1.ID)
+				break
+			}
+		}
+	}
+
+	prerequisiteNotMet, corequisiteNotMet, err := checkRequisites(tx, userID, newlyAdded, alreadyRegistered)
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	errors.PrerequisiteNotMet = prerequisiteNotMet
+	errors.CorequisiteNotMet = corequisiteNotMet
+
+	errors.CreditLimitExceeded, err = checkCreditLimits(tx, userID, newlyAdded)
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	for _, course := range newlyAdded {
+		var registeredCount int
+		if err := tx.Get(&registeredCount, "SELECT COUNT(*) FROM `registrations` WHERE `course_id` = ?", course.ID); err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		if registeredCount >= int(course.Capacity) {
+			errors.CapacityExceeded = append(errors.CapacityExceeded, course.ID)
+		}
+	}
+
+	if len(errors.CourseNotFound) > 0 || len(errors.NotRegistrableStatus) > 0 || len(errors.ScheduleConflict) > 0 ||
+		len(errors.PrerequisiteNotMet) > 0 || len(errors.CorequisiteNotMet) > 0 || len(errors.CreditLimitExceeded) > 0 ||
+		len(errors.LotteryCourse) > 0 {
+		return c.JSON(http.StatusBadRequest, errors)
+	}
+
+	// 定員に達した科目はキャンセル待ちに登録し、それ以外の科目を履修登録する
+	full := make(map[string]bool, len(errors.CapacityExceeded))
+	for _, courseID := range errors.CapacityExceeded {
+		full[courseID] = true
+		if _, err := tx.Exec("INSERT IGNORE INTO `waitlists` (`course_id`, `user_id`) VALUES (?, ?)", courseID, userID); err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+	}
+
+	for _, course := range newlyAdded {
+		if full[course.ID] {
+			continue
+		}
+		_, err = tx.Exec("INSERT INTO `registrations` (`course_id`, `user_id`) VALUES (?, ?) ON DUPLICATE KEY UPDATE `course_id` = VALUES(`course_id`), `user_id` = VALUES(`user_id`)", course.ID, userID)
+		if err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		if err := addCourseTotalScore(tx, course.ID, userID); err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		if _, err := tx.Exec("DELETE FROM `waitlists` WHERE `course_id` = ? AND `user_id` = ?", course.ID, userID); err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	if len(errors.CapacityExceeded) > 0 {
+		return c.JSON(http.StatusOK, RegisterCoursesResponse{Waitlisted: errors.CapacityExceeded})
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+// UnregisterCourse DELETE /api/users/me/courses/:courseID 履修取り消し
+func (h *handlers) UnregisterCourse(c echo.Context) error {
+	userID, _, _, err := getUserInfo(c)
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	courseID := c.Param("courseID")
+
+	tx, err := h.DB.Beginx()
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	defer tx.Rollback()
+
+	var course Course
+	if err := tx.Get(&course, "SELECT * FROM `courses` WHERE `id` = ? FOR UPDATE", courseID); err != nil && err != sql.ErrNoRows {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	} else if err == sql.ErrNoRows {
+		return c.String(http.StatusNotFound, "No such course.")
+	}
+	if course.Status != StatusRegistration {
+		return c.String(http.StatusBadRequest, "This course is not in registration.")
+	}
+
+	result, err := tx.Exec("DELETE FROM `registrations` WHERE `course_id` = ? AND `user_id` = ?", courseID, userID)
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	if deleted == 0 {
+		// 履修していない科目でもキャンセル待ちしていればキャンセル待ちを取り消す
+		result, err := tx.Exec("DELETE FROM `waitlists` WHERE `course_id` = ? AND `user_id` = ?", courseID, userID)
+		if err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		if waitlisted, err := result.RowsAffected(); err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		} else if waitlisted == 0 {
+			return c.String(http.StatusBadRequest, "You have not taken this course.")
+		}
+	} else {
+		query := "DELETE `unread_announcements`" +
+			" FROM `unread_announcements`" +
+			" JOIN `announcements` ON `unread_announcements`.`announcement_id` = `announcements`.`id`" +
+			" WHERE `announcements`.`course_id` = ? AND `unread_announcements`.`user_id` = ?"
+		if _, err := tx.Exec(query, courseID, userID); err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+
+		if err := deleteCourseTotalScore(tx, courseID, userID); err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+
+		if err := promoteWaitlist(tx, course); err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// promoteWaitlist は科目の空き枠をキャンセル待ちの先着順に繰り上げて埋める
+// 呼び出し元で科目の行を FOR UPDATE でロックしておくこと
+func promoteWaitlist(tx *sqlx.Tx, course Course) error {
+	if course.Status != StatusRegistration {
+		return nil
+	}
+
+	var registeredCount int
+	if err := tx.Get(&registeredCount, "SELECT COUNT(*) FROM `registrations` WHERE `course_id` = ?", course.ID); err != nil {
+		return err
+	}
+	vacancies := int(course.Capacity) - registeredCount
+	if vacancies <= 0 {
+		return nil
+	}
+
+	var waitingUserIDs []string
+	if err := tx.Select(&waitingUserIDs, "SELECT `user_id` FROM `waitlists` WHERE `course_id` = ? ORDER BY `id` FOR UPDATE", course.ID); err != nil {
+		return err
+	}
+
+	for _, userID := range waitingUserIDs {
+		if vacancies == 0 {
+			break
+		}
+		if _, err := tx.Exec("DELETE FROM `waitlists` WHERE `course_id` = ? AND `user_id` = ?", course.ID, userID); err != nil {
+			return err
+		}
+
+		// キャンセル待ちの間に時間割の重複・履修単位数の上限・履修要件により履修できなくなった学生は
+		// キャンセル待ちから外し、繰り上げない
+		if ok, err := canAllocateSeat(tx, userID, course); err != nil {
+			return err
+		} else if !ok {
+			continue
+		}
+
+		if _, err := tx.Exec("INSERT INTO `registrations` (`course_id`, `user_id`) VALUES (?, ?)", course.ID, userID); err != nil {
+			return err
+		}
+		if err := addCourseTotalScore(tx, course.ID, userID); err != nil {
+			return err
+		}
+		vacancies--
+	}
+
+	return nil
+}
+
+type Class struct {
+	ID               string `db:"id"`
+	CourseID         string `db:"course_id"`
+	Part             uint8  `db:"part"`
+	Title            string `db:"title"`
+	Description      string `db:"description"`
+	SubmissionClosed bool   `db:"submission_closed"`
+	ClassDeadline
+}
+
+type GetGradeResponse struct {
+	Summary       Summary        `json:"summary"`
+	CourseResults []CourseResult `json:"courses"`
+}
+
+type Summary struct {
+	Credits   int     `json:"credits"`
+	GPA       float64 `json:"gpa"`
+	GpaTScore float64 `json:"gpa_t_score"` // 偏差値
+	GpaAvg    float64 `json:"gpa_avg"`     // 平均値
+	GpaMax    float64 `json:"gpa_max"`     // 最大値
+	GpaMin    float64 `json:"gpa_min"`     // 最小値
+}
+
+type CourseResult struct {
+	Name             string       `json:"name"`
+	Code             string       `json:"code"`
+	TotalScore       int          `json:"total_score"`
+	TotalScoreTScore float64      `json:"total_score_t_score"` // 偏差値
+	TotalScoreAvg    float64      `json:"total_score_avg"`     // 平均値
+	TotalScoreMax    int          `json:"total_score_max"`     // 最大値
+	TotalScoreMin    int          `json:"total_score_min"`     // 最小値
+	Grade            string       `json:"grade"`               // 評語。科目が終了していない場合や成績評価の方針が score の場合は空文字
+	ClassScores      []ClassScore `json:"class_scores"`
+}
+
+type GradeClass struct {
+	Class
+	Submitters int           `db:"submitters"`
+	MyScore    sql.NullInt64 `db:"my_score"`
+	MyLate     bool          `db:"my_late"`
+	Weight     int           `db:"weight"`
+}
+
+type ClassScore struct {
+	ClassID    string `json:"class_id"`
+	Title      string `json:"title"`
+	Part       uint8  `json:"part"`
+	Score      *int   `json:"score"`      // 0~100点。遅延提出の場合は減点後の点
+	Late       bool   `json:"late"`       // 遅延提出かどうか
+	Submitters int    `json:"submitters"` // 提出した学生数
+}
+
+// GetGrades GET /api/users/me/grades 成績取得
+func (h *handlers) GetGrades(c echo.Context) error {
+	userID, _, _, err := getUserInfo(c)
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	// 履修している科目一覧取得
+	// semester を指定した場合はその学期の科目に絞り込み、自分の単位数とGPA、学内でのGPAの統計値もその学期の科目から計算する
+	var registeredCourses []Course
+	query := "SELECT `courses`.*" +
+		" FROM `registrations`" +
+		" JOIN `courses` ON `registrations`.`course_id` = `courses`.`id`" +
+		" WHERE `user_id` = ?"
+	args := []interface{}{userID}
+	semesterID := c.QueryParam("semester")
+	if semesterID != "" {
+		query += " AND " + courseSemesterIDColumn + " = ?"
+		args = append(args, semesterID)
+	}
+	if err := h.DB.Select(&registeredCourses, query, args...); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	courseIDs := make([]string, 0, len(registeredCourses))
+	for _, course := range registeredCourses {
+		courseIDs = append(courseIDs, course.ID)
+	}
+
+	// 履修している科目の講義一覧と、講義毎の提出者数・自分の得点・重み
+	classesByCourse := make(map[string][]GradeClass, len(registeredCourses))
+	// 履修している科目の履修者全員の総合点
+	totalsByCourse := make(map[string][]int, len(registeredCourses))
+	if len(courseIDs) > 0 {
+		var classes []GradeClass
+		query, args, err := sqlx.In("SELECT `classes`.*,"+
+			" (SELECT COUNT(*) FROM `submissions` WHERE `submissions`.`class_id` = `classes`.`id`) AS `submitters`,"+
+			" `my_submissions`.`score` AS `my_score`,"+
+			" IFNULL(`my_submissions`.`late`, false) AS `my_late`,"+
+			" IFNULL(`class_weights`.`weight`, ?) AS `weight`"+
+			" FROM `classes`"+
+			" LEFT JOIN `submissions` AS `my_submissions` ON `my_submissions`.`class_id` = `classes`.`id` AND `my_submissions`.`user_id` = ?"+
+			" LEFT JOIN `class_weights` ON `class_weights`.`class_id` = `classes`.`id`"+
+			" WHERE `classes`.`course_id` IN (?)"+
+			" ORDER BY `classes`.`part` DESC", defaultClassWeight, userID, courseIDs)
+		if err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		if err := h.DB.Select(&classes, query, args...); err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		for _, class := range classes {
+			classesByCourse[class.CourseID] = append(classesByCourse[class.CourseID], class)
+		}
+
+		var totals []struct {
+			CourseID   string `db:"course_id"`
+			TotalScore int    `db:"total_score"`
+		}
+		query, args, err = sqlx.In("SELECT `course_id`, `total_score` FROM `course_total_scores` WHERE `course_id` IN (?) ORDER BY `course_id`, `user_id`", courseIDs)
+		if err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		if err := h.DB.Select(&totals, query, args...); err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		for _, total := range totals {
+			totalsByCourse[total.CourseID] = append(totalsByCourse[total.CourseID], total.TotalScore)
+		}
+	}
+
+	// 履修している科目の成績評価の方針
+	policiesByCourse, err := getGradingPolicies(h.DB, courseIDs)
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	// 科目毎の成績計算処理
+	courseResults := make([]CourseResult, 0, len(registeredCourses))
+	myGPA := 0.0
+	myCredits := 0
+	myGPACredits := 0
+	for _, course := range registeredCourses {
+		// 講義毎の成績計算処理
+		classes := classesByCourse[course.ID]
+		classScores := make([]ClassScore, 0, len(classes))
+		var myWeightedScore int
+		for _, class := range classes {
+			if !class.MyScore.Valid {
+				classScores = append(classScores, ClassScore{
+					ClassID:    class.ID,
+					Part:       class.Part,
+					Title:      class.Title,
+					Score:      nil,
+					Submitters: class.Submitters,
+				})
+			} else {
+				score := penalizedScore(int(class.MyScore.Int64), class.LatePenalty, class.MyLate)
+				myWeightedScore += score * class.Weight
+				classScores = append(classScores, ClassScore{
+					ClassID:    class.ID,
+					Part:       class.Part,
+					Title:      class.Title,
+					Score:      &score,
+					Late:       class.MyLate,
+					Submitters: class.Submitters,
+				})
+			}
+		}
+
+		myTotalScore := myWeightedScore / 100
+
+		// この科目を履修している学生のTotalScore一覧
+		totals := totalsByCourse[course.ID]
+
+		var grade courseGrade
+		if course.Status == StatusClosed {
+			grade = policiesByCourse[course.ID].grade(myTotalScore, int(course.Credit))
+		}
+
+		courseResults = append(courseResults, CourseResult{
+			Name:             course.Name,
+			Code:             course.Code,
+			TotalScore:       myTotalScore,
+			TotalScoreTScore: tScoreInt(myTotalScore, totals),
+			TotalScoreAvg:    averageInt(totals, 0),
+			TotalScoreMax:    maxInt(totals, 0),
+			TotalScoreMin:    minInt(totals, 0),
+			Grade:            grade.Grade,
+			ClassScores:      classScores,
+		})
+
+		// 自分のGPA計算
+		myGPA += float64(grade.WeightedGradePoints)
+		myCredits += grade.Credits
+		myGPACredits += grade.GPACredits
+	}
+	if myGPACredits > 0 {
+		myGPA = myGPA / 100 / float64(myGPACredits)
+	}
+
+	// GPAの統計値
+	// GPAの対象となる科目を一つでも修了した学生のGPA一覧
+	var gpas []float64
+	if semesterID != "" {
+		gpas, err = semesterGPAs(h.DB, semesterID)
+		if err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+	} else {
+		query = "SELECT `user_gpas`.`weighted_grade_points` / 100 / `user_gpas`.`gpa_credits` AS `gpa`" +
+			" FROM `user_gpas`" +
+			" JOIN `users` ON `user_gpas`.`user_id` = `users`.`id`" +
+			" WHERE `users`.`type` = ? AND `user_gpas`.`gpa_credits` > 0" +
+			" ORDER BY `user_gpas`.`user_id`"
+		if err := h.DB.Select(&gpas, query, Student); err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+	}
+
+	res := GetGradeResponse{
+		Summary: Summary{
+			Credits:   myCredits,
+			GPA:       myGPA,
+			GpaTScore: tScoreFloat64(myGPA, gpas),
+			GpaAvg:    averageFloat64(gpas, 0),
+			GpaMax:    maxFloat64(gpas, 0),
+			GpaMin:    minFloat64(gpas, 0),
+		},
+		CourseResults: courseResults,
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// GetTranscript GET /api/users/me/transcript 成績証明書の取得
+func (h *handlers) GetTranscript(c echo.Context) error {
+	userID, userName, _, err := getUserInfo(c)
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	format := c.QueryParam("format")
+	if format == "" {
+		format = "pdf"
+	}
+	if format != "pdf" && format != "csv" {
+		return c.String(http.StatusBadRequest, "Unknown format.")
+	}
+
+	transcript := Transcript{
+		UserName: userName,
+		IssuedAt: time.Now(),
+	}
+	if err := h.DB.Get(&transcript.UserCode, "SELECT `code` FROM `users` WHERE `id` = ?", userID); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	// 修了した科目のみが対象
+	transcript.Courses = make([]TranscriptCourse, 0)
+	query := "SELECT `courses`.`id`, `courses`.`code`, `courses`.`name`, `courses`.`credit`, IFNULL(`course_total_scores`.`total_score`, 0) AS `total_score`" +
+		" FROM `registrations`" +
+		" JOIN `courses` ON `registrations`.`course_id` = `courses`.`id`" +
+		" LEFT JOIN `course_total_scores` ON `course_total_scores`.`course_id` = `registrations`.`course_id` AND `course_total_scores`.`user_id` = `registrations`.`user_id`" +
+		" WHERE `registrations`.`user_id` = ? AND `courses`.`status` = ?" +
+		" ORDER BY `courses`.`code`"
+	if err := h.DB.Select(&transcript.Courses, query, userID, StatusClosed); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	courseIDs := make([]string, 0, len(transcript.Courses))
+	for _, course := range transcript.Courses {
+		courseIDs = append(courseIDs, course.ID)
+	}
+	policies, err := getGradingPolicies(h.DB, courseIDs)
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	weightedGradePoints := 0
+	gpaCredits := 0
+	for i := range transcript.Courses {
+		course := &transcript.Courses[i]
+		course.Grade = policies[course.ID].grade(course.TotalScore, int(course.Credit))
+		weightedGradePoints += course.Grade.WeightedGradePoints
+		gpaCredits += course.Grade.GPACredits
+		transcript.Credits += course.Grade.Credits
+	}
+	if gpaCredits > 0 {
+		transcript.GPA = float64(weightedGradePoints) / 100 / float64(gpaCredits)
+	}
+
+	var buf bytes.Buffer
+	contentType := "application/pdf"
+	if format == "csv" {
+		contentType = "text/csv; charset=UTF-8"
+		err = writeTranscriptCSV(&buf, &transcript)
+	} else {
+		err = writeTranscriptPDF(&buf, &transcript)
+	}
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=\"transcript_%s.%s\"", transcript.UserCode, format))
+	return c.Blob(http.StatusOK, contentType, buf.Bytes())
+}
+
+type TeachingCourse struct {
+	ID                      string          `json:"id"`
+	Code                    string          `json:"code"`
+	Type                    CourseType      `json:"type"`
+	Name                    string          `json:"name"`
+	Credit                  uint8           `json:"credit"`
+	Period                  uint8           `json:"period"`
+	DayOfWeek               DayOfWeek       `json:"day_of_week"`
+	Status                  CourseStatus    `json:"status"`
+	Capacity                uint16          `json:"capacity"`
+	IsOwner                 bool            `json:"is_owner"`
+	RegistrationCount       int             `json:"registration_count"`
+	ClassCount              int             `json:"class_count"`
+	UngradedSubmissionCount int             `json:"ungraded_submission_count"`
+	Classes                 []TeachingClass `json:"classes"`
+}
+
+type TeachingClass struct {
+	ID                      string `json:"id" db:"id"`
+	CourseID                string `json:"-" db:"course_id"`
+	Part                    uint8  `json:"part" db:"part"`
+	Title                   string `json:"title" db:"title"`
+	SubmissionClosed        bool   `json:"submission_closed" db:"submission_closed"`
+	SubmissionCount         int    `json:"submission_count" db:"submission_count"`
+	UngradedSubmissionCount int    `json:"ungraded_submission_count" db:"ungraded_submission_count"`
+}
+
+// GetTeachingCourses GET /api/users/me/teaching 担当している科目の一覧と集計を取得
+func (h *handlers) GetTeachingCourses(c echo.Context) error {
+	userID, _, _, err := getUserInfo(c)
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	var courses []Course
+	query := "SELECT * FROM `courses`" +
+		" WHERE `teacher_id` = ? OR `id` IN (SELECT `course_id` FROM `course_teachers` WHERE `teacher_id` = ?)" +
+		" ORDER BY `code`"
+	if err := h.DB.Select(&courses, query, userID, userID); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	// 担当科目が0件の時は空配列を返却
+	res := make([]TeachingCourse, 0, len(courses))
+	if len(courses) == 0 {
+		return c.JSON(http.StatusOK, res)
+	}
+
+	courseIDs := make([]string, 0, len(courses))
+	for _, course := range courses {
+		courseIDs = append(courseIDs, course.ID)
+	}
+
+	// 科目ごとの履修者数
+	var registrationCounts []struct {
+		CourseID string `db:"course_id"`
+		Count    int    `db:"count"`
+	}
+	query, args, err := sqlx.In("SELECT `course_id`, COUNT(*) AS `count` FROM `registrations` WHERE `course_id` IN (?) GROUP BY `course_id`", courseIDs)
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	if err := h.DB.Select(&registrationCounts, query, args...); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	registrationCountByCourse := make(map[string]int, len(registrationCounts))
+	for _, rc := range registrationCounts {
+		registrationCountByCourse[rc.CourseID] = rc.Count
+	}
+
+	// 講義ごとの提出数と未採点数
+	var classes []TeachingClass
+	query, args, err = sqlx.In("SELECT `classes`.`id`, `classes`.`course_id`, `classes`.`part`, `classes`.`title`, `classes`.`submission_closed`,"+
+		" COUNT(`submissions`.`user_id`) AS `submission_count`,"+
+		" COUNT(`submissions`.`user_id`) - COUNT(`submissions`.`score`) AS `ungraded_submission_count`"+
+		" FROM `classes`"+
+		" LEFT JOIN `submissions` ON `classes`.`id` = `submissions`.`class_id`"+
+		" WHERE `classes`.`course_id` IN (?)"+
+		" GROUP BY `classes`.`id`"+
+		" ORDER BY `classes`.`course_id`, `classes`.`part`", courseIDs)
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	if err := h.DB.Select(&classes, query, args...); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	classesByCourse := make(map[string][]TeachingClass, len(courses))
+	for _, class := range classes {
+		classesByCourse[class.CourseID] = append(classesByCourse[class.CourseID], class)
+	}
+
+	for _, course := range courses {
+		// 講義が0件の時は空配列を返却
+		courseClasses := make([]TeachingClass, 0, len(classesByCourse[course.ID]))
+		ungradedSubmissionCount := 0
+		for _, class := range classesByCourse[course.ID] {
+			courseClasses = append(courseClasses, class)
+			ungradedSubmissionCount += class.UngradedSubmissionCount
+		}
+
+		res = append(res, TeachingCourse{
+			ID:                      course.ID,
+			Code:                    course.Code,
+			Type:                    course.Type,
+			Name:                    course.Name,
+			Credit:                  course.Credit,
+			Period:                  course.Period,
+			DayOfWeek:               course.DayOfWeek,
+			Status:                  course.Status,
+			Capacity:                course.Capacity,
+			IsOwner:                 course.TeacherID == userID,
+			RegistrationCount:       registrationCountByCourse[course.ID],
+			ClassCount:              len(courseClasses),
+			UngradedSubmissionCount: ungradedSubmissionCount,
+			Classes:                 courseClasses,
+		})
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// ---------- Courses API ----------
+
+type SearchCoursesWithFacetsResponse struct {
+	Courses []GetCourseDetailResponse `json:"courses"`
+	// Facets ファセットの名前 -> 値 -> 他のファセットの絞り込み条件にマッチする科目数
+	Facets map[string]map[string]int `json:"facets"`
+}
+
+// SearchCourses GET /api/courses 科目検索
+func (h *handlers) SearchCourses(c echo.Context) error {
+	query := "SELECT `courses`.*, `users`.`name` AS `teacher`" +
+		" FROM `courses` JOIN `users` ON `courses`.`teacher_id` = `users`.`id`" +
+		" WHERE 1=1"
+	var condition string
+	var args []interface{}
+
+	// 無効な検索条件はエラーを返さず無視して良い
+
+	filters := parseCourseFacetFilters(c)
+	for i, filter := range filters {
+		if filter.Value != "" {
+			condition += " AND " + courseFacets[i].Column + " = ?"
+			args = append(args, filter.Arg)
+		}
+	}
+
+	// sort=relevance の場合は全文検索インデックスを使い、関連度の高い順に並べる
+	// それ以外の場合は科目名またはキーワードが空白区切りの語をすべて部分文字列として含む科目を科目コード順に並べる
+	sortByRelevance := c.QueryParam("sort") == "relevance"
+	var keywordCondition string
+	var keywordArgs []interface{}
+	var score string
+	var scoreArgs []interface{}
+	if keywords := c.QueryParam("keywords"); keywords != "" {
+		if sortByRelevance {
+			keywordCondition, keywordArgs, score, scoreArgs = buildFulltextSearch(keywords)
+		} else {
+			arr := strings.Split(keywords, " ")
+			var nameCondition string
+			for _, keyword := range arr {
+				nameCondition += " AND `courses`.`name` LIKE ?"
+				keywordArgs = append(keywordArgs, "%"+keyword+"%")
+			}
+			var keywordsCondition string
+			for _, keyword := range arr {
+				keywordsCondition += " AND `courses`.`keywords` LIKE ?"
+				keywordArgs = append(keywordArgs, "%"+keyword+"%")
+			}
+			keywordCondition = fmt.Sprintf(" AND ((1=1%s) OR (1=1%s))", nameCondition, keywordsCondition)
+		}
+	}
+	condition += keywordCondition
+	args = append(args, keywordArgs...)
+
+	p, msg := parsePaging(c)
+	if msg != "" {
+		return c.String(http.StatusBadRequest, msg)
+	}
+	// 関連度順のカーソルは関連度を持ち、それ以外のカーソルは持たない
+	if p.cursor != nil && (p.cursor.Score != nil) != (score != "") {
+		return c.String(http.StatusBadRequest, "Invalid cursor.")
+	}
+
+	if score != "" {
+		// カーソルに関連度を含めるため、関連度も取得する
+		query = "SELECT `courses`.*, `users`.`name` AS `teacher`, " + score + " AS `score`" +
+			" FROM `courses` JOIN `users` ON `courses`.`teacher_id` = `users`.`id`" +
+			" WHERE 1=1"
+		args = append(append([]interface{}{}, scoreArgs...), args...)
+	}
+
+	if p.cursor != nil {
+		if score != "" {
+			if p.cursor.Before {
+				condition += " AND (" + score + " > ? OR (" + score + " = ? AND `courses`.`code` < ?))"
+			} else {
+				condition += " AND (" + score + " < ? OR (" + score + " = ? AND `courses`.`code` > ?))"
+			}
+			args = append(args, scoreArgs...)
+			args = append(args, *p.cursor.Score)
+			args = append(args, scoreArgs...)
+			args = append(args, *p.cursor.Score, p.cursor.Key)
+		} else {
+			if p.cursor.Before {
+				condition += " AND `courses`.`code` < ?"
+			} else {
+				condition += " AND `courses`.`code` > ?"
+			}
+			args = append(args, p.cursor.Key)
+		}
+	}
+
+	// 前のページは逆順に取得する
+	asc, desc := "", " DESC"
+	if p.backward() {
+		asc, desc = " DESC", ""
+	}
+	if score != "" {
+		condition += " ORDER BY `score`" + desc + ", `courses`.`code`" + asc
+	} else {
+		condition += " ORDER BY `courses`.`code`" + asc
+	}
+
+	// limitより多く上限を設定し、実際にlimitより多くレコードが取得できた場合は次のページが存在する
+	condition += " LIMIT ? OFFSET ?"
+	args = append(args, p.limit+1, p.offset)
+
+	var courses []struct {
+		GetCourseDetailResponse
+		Score float64 `db:"score"`
+	}
+	if err := h.DB.Select(&courses, query+condition, args...); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	hasPrev, hasNext := p.hasPrevNext(len(courses))
+	if len(courses) > p.limit {
+		courses = courses[:p.limit]
+	}
+	if p.backward() {
+		for i, j := 0, len(courses)-1; i < j; i, j = i+1, j-1 {
+			courses[i], courses[j] = courses[j], courses[i]
+		}
+	}
+
+	// 結果が0件の時は空配列を返却
+	res := make([]GetCourseDetailResponse, 0, len(courses))
+	for _, course := range courses {
+		res = append(res, course.GetCourseDetailResponse)
+	}
+
+	if len(courses) > 0 {
+		cursorAt := func(i int, before bool) *pageCursor {
+			cursor := &pageCursor{Before: before, Key: courses[i].Code}
+			if score != "" {
+				cursor.Score = &courses[i].Score
+			}
+			return cursor
+		}
+		var prev, next *pageCursor
+		if hasPrev {
+			prev = cursorAt(0, true)
+		}
+		if hasNext {
+			next = cursorAt(len(courses)-1, false)
+		}
+		if err := setPagingLinks(c, prev, next); err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+	}
+
+	// facets=true の場合は検索結果とあわせてファセットごとの科目数を返す
+	if c.QueryParam("facets") == "true" {
+		facets, err := countCourseFacets(h.DB, filters, keywordCondition, keywordArgs)
+		if err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		return c.JSON(http.StatusOK, SearchCoursesWithFacetsResponse{
+			Courses: res,
+			Facets:  facets,
+		})
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+type AddCourseRequest struct {
+	Code        string     `json:"code"`
+	Type        CourseType `json:"type"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Credit      int        `json:"credit"`
+	Period      int        `json:"period"`
+	DayOfWeek   DayOfWeek  `json:"day_of_week"`
+	Keywords    string     `json:"keywords"`
+	Capacity    int        `json:"capacity"`    // 省略時は defaultCourseCapacity
+	SemesterID  string     `json:"semester_id"` // 省略時は現在の学期
+}
+
+type AddCourseResponse struct {
+	ID string `json:"id"`
+}
+
+// AddCourse POST /api/courses 新規科目登録
+func (h *handlers) AddCourse(c echo.Context) error {
+	userID, _, _, err := getUserInfo(c)
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	var req AddCourseRequest
+	if err := c.Bind(&req); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid format.")
+	}
+
+	if req.Type != LiberalArts && req.Type != MajorSubjects {
+		return c.String(http.StatusBadRequest, "Invalid course type.")
+	}
+	if !contains(daysOfWeek, req.DayOfWeek) {
+		return c.String(http.StatusBadRequest, "Invalid day of week.")
+	}
+	if req.Capacity == 0 {
+		req.Capacity = defaultCourseCapacity
+	}
+	if req.Capacity < 0 || req.Capacity > math.MaxUint16 {
+		return c.String(http.StatusBadRequest, "Invalid capacity.")
+	}
+	if req.SemesterID == "" {
+		req.SemesterID, err = getCurrentSemesterID(h.DB)
+		if err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+	} else {
+		var count int
+		if err := h.DB.Get(&count, "SELECT COUNT(*) FROM `semesters` WHERE `id` = ?", req.SemesterID); err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		if count == 0 {
+			return c.String(http.StatusBadRequest, "No such semester.")
+		}
+	}
+
+	courseID := newULID()
+	_, err = h.DB.Exec("INSERT INTO `courses` (`id`, `code`, `type`, `name`, `description`, `credit`, `period`, `day_of_week`, `teacher_id`, `keywords`, `capacity`, `semester_id`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		courseID, req.Code, req.Type, req.Name, req.Description, req.Credit, req.Period, req.DayOfWeek, userID, req.Keywords, req.Capacity, req.SemesterID)
+	if err != nil {
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == uint16(mysqlErrNumDuplicateEntry) {
+			var course Course
+			if err := h.DB.Get(&course, "SELECT * FROM `courses` WHERE `code` = ?", req.Code); err != nil {
+				c.Logger().Error(err)
+				return c.NoContent(http.StatusInternalServerError)
+			}
+			semesterID, err := resolveSemesterID(h.DB, course.SemesterID)
+			if err != nil {
+				c.Logger().Error(err)
+				return c.NoContent(http.StatusInternalServerError)
+			}
+			if req.Type != course.Type || req.Name != course.Name || req.Description != course.Description || req.Credit != int(course.Credit) || req.Period != int(course.Period) || req.DayOfWeek != course.DayOfWeek || req.Keywords != course.Keywords || req.Capacity != int(course.Capacity) || req.SemesterID != semesterID {
+				return c.String(http.StatusConflict, "A course with the same code already exists.")
+			}
+			return c.JSON(http.StatusCreated, AddCourseResponse{ID: course.ID})
+		}
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.JSON(http.StatusCreated, AddCourseResponse{ID: courseID})
+}
+
+type GetCourseDetailResponse struct {
+	ID          string       `json:"id" db:"id"`
+	Code        string       `json:"code" db:"code"`
+	Type        string       `json:"type" db:"type"`
+	Name        string       `json:"name" db:"name"`
+	Description string       `json:"description" db:"description"`
+	Credit      uint8        `json:"credit" db:"credit"`
+	Period      uint8        `json:"period" db:"period"`
+	DayOfWeek   string       `json:"day_of_week" db:"day_of_week"`
+	TeacherID   string       `json:"-" db:"teacher_id"`
+	Keywords    string       `json:"keywords" db:"keywords"`
+	Status      CourseStatus `json:"status" db:"status"`
+	Capacity    uint16       `json:"capacity" db:"capacity"`
+	SemesterID  *string      `json:"semester_id" db:"semester_id"`
+	Teacher     string       `json:"teacher" db:"teacher"`
+}
+
+// GetCourseDetail GET /api/courses/:courseID 科目詳細の取得
+func (h *handlers) GetCourseDetail(c echo.Context) error {
+	courseID := c.Param("courseID")
+
+	var res GetCourseDetailResponse
+	query := "SELECT `courses`.*, `users`.`name` AS `teacher`" +
+		" FROM `courses`" +
+		" JOIN `users` ON `courses`.`teacher_id` = `users`.`id`" +
+		" WHERE `courses`.`id` = ?"
+	if err := h.DB.Get(&res, query, courseID); err != nil && err != sql.ErrNoRows {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	} else if err == sql.ErrNoRows {
+		return c.String(http.StatusNotFound, "No such course.")
+	}
+	if res.SemesterID == nil {
+		semesterID, err := getCurrentSemesterID(h.DB)
+		if err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		res.SemesterID = &semesterID
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+type SetCourseStatusRequest struct {
+	Status CourseStatus `json:"status"`
+}
+
+type SetCourseStatusErrorResponse struct {
+	CurrentStatus   CourseStatus   `json:"current_status"`
+	RequestedStatus CourseStatus   `json:"requested_status"`
+	AllowedStatuses []CourseStatus `json:"allowed_statuses"`
+}
+
+// SetCourseStatus PUT /api/courses/:courseID/status 科目のステータスを変更
+func (h *handlers) SetCourseStatus(c echo.Context) error {
+	userID, _, _, err := getUserInfo(c)
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	courseID := c.Param("courseID")
+
+	var req SetCourseStatusRequest
+	if err := c.Bind(&req); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid format.")
+	}
+	if _, ok := courseStatusTransitions[req.Status]; !ok {
+		return c.String(http.StatusBadRequest, "Invalid status.")
+	}
+
+	tx, err := h.DB.Beginx()
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	defer tx.Rollback()
+
+	var currentStatus CourseStatus
+	if err := tx.Get(&currentStatus, "SELECT `status` FROM `courses` WHERE `id` = ? FOR UPDATE", courseID); err != nil && err != sql.ErrNoRows {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	} else if err == sql.ErrNoRows {
+		return c.String(http.StatusNotFound, "No such course.")
+	}
+
+	// 同じステータスへの変更はリトライとみなして何もせず成功させる
+	if currentStatus == req.Status {
+		return c.NoContent(http.StatusOK)
+	}
+
+	allowed := courseStatusTransitions[currentStatus]
+	isAllowed := false
+	for _, status := range allowed {
+		if status == req.Status {
+			isAllowed = true
+			break
+		}
+	}
+	if !isAllowed {
+		return c.JSON(http.StatusConflict, SetCourseStatusErrorResponse{
+			CurrentStatus:   currentStatus,
+			RequestedStatus: req.Status,
+			AllowedStatuses: append(make([]CourseStatus, 0, len(allowed)), allowed...),
+		})
+	}
+
+	if _, err := tx.Exec("UPDATE `courses` SET `status` = ? WHERE `id` = ?", req.Status, courseID); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	if _, err := tx.Exec("INSERT INTO `course_status_history` (`course_id`, `from_status`, `to_status`, `actor_id`) VALUES (?, ?, ?, ?)",
+		courseID, currentStatus, req.Status, userID); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	if req.Status == StatusClosed {
+		if err := addClosedCourseToGPAs(tx, courseID); err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+	}
+
+	// 抽選の科目は申し込みを締め切り、席の割り当てを LotteryAllocator に任せる
+	lotteryPending := false
+	if req.Status == StatusInProgress {
+		result, err := tx.Exec("UPDATE `course_lotteries` SET `status` = ? WHERE `course_id` = ? AND `status` = ?", LotteryPending, courseID, LotteryOpen)
+		if err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		} else if n > 0 {
+			lotteryPending = true
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	if lotteryPending {
+		h.Lottery.Notify()
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+type CourseStatusHistory struct {
+	From      CourseStatus `json:"from" db:"from_status"`
+	To        CourseStatus `json:"to" db:"to_status"`
+	ActorCode string       `json:"actor_code" db:"actor_code"`
+	ActorName string       `json:"actor_name" db:"actor_name"`
+	ChangedAt time.Time    `json:"changed_at" db:"changed_at"`
+}
+
+// GetCourseStatusHistory GET /api/courses/:courseID/status/history 科目のステータス変更履歴の取得
+func (h *handlers) GetCourseStatusHistory(c echo.Context) error {
+	courseID := c.Param("courseID")
+
+	tx, err := h.DB.Beginx()
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	defer tx.Rollback()
+
+	var count int
+	if err := tx.Get(&count, "SELECT COUNT(*) FROM `courses` WHERE `id` = ?", courseID); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	if count == 0 {
+		return c.String(http.StatusNotFound, "No such course.")
+	}
+
+	// 履歴が0件の時は空配列を返却
+	history := make([]CourseStatusHistory, 0)
+	query := "SELECT `course_status_history`.`from_status`, `course_status_history`.`to_status`, `users`.`code` AS `actor_code`, `users`.`name` AS `actor_name`, `course_status_history`.`created_at` AS `changed_at`" +
+		" FROM `course_status_history`" +
+		" JOIN `users` ON `course_status_history`.`actor_id` = `users`.`id`" +
+		" WHERE `course_status_history`.`course_id` = ?" +
+		" ORDER BY `course_status_history`.`id`"
+	if err := tx.Select(&history, query, courseID); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.JSON(http.StatusOK, history)
+}
+
+// AddCourseTeacher PUT /api/courses/:courseID/teachers/:teacherCode 科目に共同担当教員を追加
+func (h *handlers) AddCourseTeacher(c echo.Context) error {
+	courseID := c.Param("courseID")
+	teacherCode := c.Param("teacherCode")
+
+	var teacher User
+	if err := h.DB.Get(&teacher, "SELECT * FROM `users` WHERE `code` = ? AND `type` = ?", teacherCode, Teacher); err != nil && err != sql.ErrNoRows {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	} else if err == sql.ErrNoRows {
+		return c.String(http.StatusNotFound, "No such teacher.")
+	}
+
+	// 既に担当教員である場合は何もせず成功させる
+	if _, err := h.DB.Exec("INSERT IGNORE INTO `course_teachers` (`course_id`, `teacher_id`) SELECT `id`, ? FROM `courses` WHERE `id` = ? AND `teacher_id` <> ?",
+		teacher.ID, courseID, teacher.ID); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// RemoveCourseTeacher DELETE /api/courses/:courseID/teachers/:teacherCode 科目から共同担当教員を削除
+func (h *handlers) RemoveCourseTeacher(c echo.Context) error {
+	courseID := c.Param("courseID")
+	teacherCode := c.Param("teacherCode")
+
+	var teacher User
+	if err := h.DB.Get(&teacher, "SELECT * FROM `users` WHERE `code` = ? AND `type` = ?", teacherCode, Teacher); err != nil && err != sql.ErrNoRows {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	} else if err == sql.ErrNoRows {
+		return c.String(http.StatusNotFound, "No such teacher.")
+	}
+
+	result, err := h.DB.Exec("DELETE FROM `course_teachers` WHERE `course_id` = ? AND `teacher_id` = ?", courseID, teacher.ID)
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	// 主担当教員は科目から外せない
+	if affected == 0 {
+		return c.String(http.StatusBadRequest, "The teacher is not a co-teacher of this course.")
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+type GetCourseStatisticsResponse struct {
+	RegistrationCount int               `json:"registration_count"`
+	TotalScore        ScoreStatistics   `json:"total_score"`
+	Histogram         []HistogramBucket `json:"histogram"`
+	Classes           []ClassStatistics `json:"classes"`
+}
+
+type ScoreStatistics struct {
+	Count  int     `json:"count"`
+	Avg    float64 `json:"avg"`     // 平均値
+	StdDev float64 `json:"std_dev"` // 標準偏差
+	Max    int     `json:"max"`     // 最大値
+	Min    int     `json:"min"`     // 最小値
+	Q1     float64 `json:"q1"`      // 第1四分位数
+	Median float64 `json:"median"`  // 中央値
+	Q3     float64 `json:"q3"`      // 第3四分位数
+	P90    float64 `json:"p90"`     // 90パーセンタイル
+}
+
+type HistogramBucket struct {
+	Min    int     `json:"min"` // 下限(この値を含む)
+	Max    int     `json:"max"` // 上限(この値を含む)
+	Count  int     `json:"count"`
+	TScore float64 `json:"t_score"` // 下限の偏差値
+}
+
+type ClassStatistics struct {
+	ID              string          `json:"id"`
+	Part            uint8           `json:"part"`
+	Title           string          `json:"title"`
+	SubmissionCount int             `json:"submission_count"`
+	SubmissionRate  float64         `json:"submission_rate"` // 履修者に対する提出者の割合
+	Score           ScoreStatistics `json:"score"`           // 採点済みの提出課題の得点
+}
+
+type ClassWithSubmitters struct {
+	Class
+	Submitters int `db:"submitters"`
+}
+
+func newScoreStatistics(scores []int) ScoreStatistics {
+	avg := averageInt(scores, 0)
+	return ScoreStatistics{
+		Count:  len(scores),
+		Avg:    avg,
+		StdDev: stdDevInt(scores, avg),
+		Max:    maxInt(scores, 0),
+		Min:    minInt(scores, 0),
+		Q1:     percentileInt(scores, 25, 0),
+		Median: medianInt(scores, 0),
+		Q3:     percentileInt(scores, 75, 0),
+		P90:    percentileInt(scores, 90, 0),
+	}
+}
+
+// GetCourseStatistics GET /api/courses/:courseID/statistics?bucket_width=10 科目の成績分布の取得
+func (h *handlers) GetCourseStatistics(c echo.Context) error {
+	courseID := c.Param("courseID")
+
+	bucketWidth := defaultHistogramBucketWidth
+	if param := c.QueryParam("bucket_width"); param != "" {
+		var err error
+		bucketWidth, err = strconv.Atoi(param)
+		if err != nil || bucketWidth <= 0 {
+			return c.String(http.StatusBadRequest, "Invalid bucket_width.")
+		}
+	}
+
+	// 履修者全員の総合点
+	var totals []int
+	if err := h.DB.Select(&totals, "SELECT `total_score` FROM `course_total_scores` WHERE `course_id` = ? ORDER BY `user_id`", courseID); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	var classes []ClassWithSubmitters
+	query := "SELECT `classes`.*, COUNT(`submissions`.`user_id`) AS `submitters`" +
+		" FROM `classes`" +
+		" LEFT JOIN `submissions` ON `classes`.`id` = `submissions`.`class_id`" +
+		" WHERE `classes`.`course_id` = ?" +
+		" GROUP BY `classes`.`id`" +
+		" ORDER BY `classes`.`part`"
+	if err := h.DB.Select(&classes, query, courseID); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	var scores []struct {
+		ClassID string `db:"class_id"`
+		Score   int    `db:"score"`
+	}
+	query = "SELECT `submissions`.`class_id`, `submissions`.`score`" +
+		" FROM `submissions`" +
+		" JOIN `classes` ON `submissions`.`class_id` = `classes`.`id`" +
+		" WHERE `classes`.`course_id` = ? AND `submissions`.`score` IS NOT NULL"
+	if err := h.DB.Select(&scores, query, courseID); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	scoresByClass := make(map[string][]int, len(classes))
+	for _, score := range scores {
+		scoresByClass[score.ClassID] = append(scoresByClass[score.ClassID], score.Score)
+	}
+
+	// 総合点は 0 ~ 講義の重みの合計 点なので、その範囲を bucketWidth 点刻みで区切る
+	var maxTotalScore int
+	query = "SELECT IFNULL(SUM(IFNULL(`class_weights`.`weight`, ?)), 0)" +
+		" FROM `classes`" +
+		" LEFT JOIN `class_weights` ON `classes`.`id` = `class_weights`.`class_id`" +
+		" WHERE `classes`.`course_id` = ?"
+	if err := h.DB.Get(&maxTotalScore, query, defaultClassWeight, courseID); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	// 重みを変更する前に計算された総合点が範囲外になっている場合に備える
+	if max := maxInt(totals, 0); max > maxTotalScore {
+		maxTotalScore = max
+	}
+	histogram := make([]HistogramBucket, 0, maxTotalScore/bucketWidth+1)
+	for min := 0; min <= maxTotalScore; min += bucketWidth {
+		histogram = append(histogram, HistogramBucket{
+			Min:    min,
+			Max:    min + bucketWidth - 1,
+			TScore: tScoreInt(min, totals),
+		})
+	}
+	for _, total := range totals {
+		i := total / bucketWidth
+		if i >= len(histogram) {
+			i = len(histogram) - 1
+		}
+		histogram[i].Count++
+	}
+
+	classStatistics := make([]ClassStatistics, 0, len(classes))
+	for _, class := range classes {
+		submissionRate := 0.0
+		if len(totals) > 0 {
+			submissionRate = float64(class.Submitters) / float64(len(totals))
+		}
+		classStatistics = append(classStatistics, ClassStatistics{
+			ID:              class.ID,
+			Part:            class.Part,
+			Title:           class.Title,
+			SubmissionCount: class.Submitters,
+			SubmissionRate:  submissionRate,
+			Score:           newScoreStatistics(scoresByClass[class.ID]),
+		})
+	}
+
+	return c.JSON(http.StatusOK, GetCourseStatisticsResponse{
+		RegistrationCount: len(totals),
+		TotalScore:        newScoreStatistics(totals),
+		Histogram:         histogram,
+		Classes:           classStatistics,
+	})
+}
+
+type ClassWeight struct {
+	ClassID string `json:"class_id" db:"class_id"`
+	Weight  int    `json:"weight" db:"weight"` // 百分率。100で等倍
+}
+
+type GradingPolicyResponse struct {
+	GradingPolicy
+	ClassWeights []ClassWeight `json:"class_weights"`
+}
+
+type SetGradingPolicyRequest struct {
+	GradingPolicy
+	ClassWeights []ClassWeight `json:"class_weights"` // 指定されなかった講義は既定の重みになる
+}
+
+// GetGradingPolicy GET /api/courses/:courseID/grading-policy 成績評価の方針の取得
+func (h *handlers) GetGradingPolicy(c echo.Context) error {
+	courseID := c.Param("courseID")
+
+	tx, err := h.DB.Beginx()
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	defer tx.Rollback()
+
+	var count int
+	if err := tx.Get(&count, "SELECT COUNT(*) FROM `courses` WHERE `id` = ?", courseID); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	if count == 0 {
+		return c.String(http.StatusNotFound, "No such course.")
+	}
+
+	policy, err := getGradingPolicy(tx, courseID)
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	weights := make([]ClassWeight, 0)
+	query := "SELECT `classes`.`id` AS `class_id`, IFNULL(`class_weights`.`weight`, ?) AS `weight`" +
+		" FROM `classes`" +
+		" LEFT JOIN `class_weights` ON `classes`.`id` = `class_weights`.`class_id`" +
+		" WHERE `classes`.`course_id` = ?" +
+		" ORDER BY `classes`.`part`"
+	if err := tx.Select(&weights, query, defaultClassWeight, courseID); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.JSON(http.StatusOK, GradingPolicyResponse{
+		GradingPolicy: policy,
+		ClassWeights:  weights,
+	})
+}
+
+// SetGradingPolicy PUT /api/courses/:courseID/grading-policy 成績評価の方針の設定
+func (h *handlers) SetGradingPolicy(c echo.Context) error {
+	courseID := c.Param("courseID")
+
+	var req SetGradingPolicyRequest
+	if err := c.Bind(&req); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid format.")
+	}
+	req.CourseID = courseID
+	if msg := req.GradingPolicy.validate(); msg != "" {
+		return c.String(http.StatusBadRequest, msg)
+	}
+	// 方針の種類に関係しない値は保存しない
+	if req.Type == GradingLetter {
+		req.PassScore = 0
+	} else {
+		req.CutoffS, req.CutoffA, req.CutoffB, req.CutoffC = 0, 0, 0, 0
+	}
+
+	tx, err := h.DB.Beginx()
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	defer tx.Rollback()
+
+	// 採点結果の登録と同様に、総合点の再計算が並行して行われないよう科目をロックする
+	var course Course
+	if err := tx.Get(&course, "SELECT * FROM `courses` WHERE `id` = ? FOR UPDATE", courseID); err != nil && err != sql.ErrNoRows {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	} else if err == sql.ErrNoRows {
+		return c.String(http.StatusNotFound, "No such course.")
+	}
+
+	var classIDs []string
+	if err := tx.Select(&classIDs, "SELECT `id` FROM `classes` WHERE `course_id` = ?", courseID); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	isCourseClass := make(map[string]bool, len(classIDs))
+	for _, classID := range classIDs {
+		isCourseClass[classID] = true
+	}
+	seen := make(map[string]bool, len(req.ClassWeights))
+	for _, weight := range req.ClassWeights {
+		if !isCourseClass[weight.ClassID] {
+			return c.String(http.StatusBadRequest, "No such class in this course.")
+		}
+		if seen[weight.ClassID] {
+			return c.String(http.StatusBadRequest, "Duplicate class_id.")
+		}
+		seen[weight.ClassID] = true
+		if weight.Weight < 0 || weight.Weight > maxClassWeight {
+			return c.String(http.StatusBadRequest, "Invalid weight.")
+		}
+	}
+
+	before, err := getGradingPolicy(tx, courseID)
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	if _, err := tx.Exec("INSERT INTO `grading_policies` (`course_id`, `type`, `pass_score`, `cutoff_s`, `cutoff_a`, `cutoff_b`, `cutoff_c`) VALUES (?, ?, ?, ?, ?, ?, ?)"+
+		" ON DUPLICATE KEY UPDATE `type` = VALUES(`type`), `pass_score` = VALUES(`pass_score`), `cutoff_s` = VALUES(`cutoff_s`), `cutoff_a` = VALUES(`cutoff_a`), `cutoff_b` = VALUES(`cutoff_b`), `cutoff_c` = VALUES(`cutoff_c`)",
+		courseID, req.Type, req.PassScore, req.CutoffS, req.CutoffA, req.CutoffB, req.CutoffC); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
 
-		// この科目を履修している学生のTotalScore一覧を取得
-		var totals []int
-		query := "SELECT IFNULL(SUM(`submissions`.`score`), 0) AS `total_score`" +
-			" FROM `users`" +
-			" JOIN `registrations` ON `users`.`id` = `registrations`.`user_id`" +
-			" JOIN `courses` ON `registrations`.`course_id` = `courses`.`id`" +
-			" LEFT JOIN `classes` ON `courses`.`id` = `classes`.`course_id`" +
-			" LEFT JOIN `submissions` ON `users`.`id` = `submissions`.`user_id` AND `submissions`.`class_id` = `classes`.`id`" +
-			" WHERE `courses`.`id` = ?" +
-			" GROUP BY `users`.`id`"
-		if err := h.DB.Select(&totals, query, course.ID); err != nil {
+	if _, err := tx.Exec("DELETE `class_weights` FROM `class_weights` JOIN `classes` ON `class_weights`.`class_id` = `classes`.`id` WHERE `classes`.`course_id` = ?", courseID); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	for _, weight := range req.ClassWeights {
+		if _, err := tx.Exec("INSERT INTO `class_weights` (`class_id`, `weight`) VALUES (?, ?)", weight.ClassID, weight.Weight); err != nil {
 			c.Logger().Error(err)
 			return c.NoContent(http.StatusInternalServerError)
 		}
+	}
 
-		courseResults = append(courseResults, CourseResult{
-			Name:             course.Name,
-			Code:             course.Code,
-			TotalScore:       myTotalScore,
-			TotalScoreTScore: tScoreInt(myTotalScore, totals),
-			TotalScoreAvg:    averageInt(totals, 0),
-			TotalScoreMax:    maxInt(totals, 0),
-			TotalScoreMin:    minInt(totals, 0),
-			ClassScores:      classScores,
-		})
+	if err := regradeCourse(tx, courseID, before, req.GradingPolicy); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
 
-		// 自分のGPA計算
-		if course.Status == StatusClosed {
-			myGPA += float64(myTotalScore * int(course.Credit))
-			myCredits += int(course.Credit)
-		}
+	if err := tx.Commit(); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+type RequisiteCourse struct {
+	ID   string        `json:"id" db:"id"`
+	Code string        `json:"code" db:"code"`
+	Name string        `json:"name" db:"name"`
+	Type RequisiteType `json:"-" db:"type"`
+}
+
+type GetCourseRequisitesResponse struct {
+	Prerequisites []RequisiteCourse `json:"prerequisites"`
+	Corequisites  []RequisiteCourse `json:"corequisites"`
+}
+
+// GetCourseRequisites GET /api/courses/:courseID/requisites 科目の履修要件の取得
+func (h *handlers) GetCourseRequisites(c echo.Context) error {
+	courseID := c.Param("courseID")
+
+	tx, err := h.DB.Beginx()
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	defer tx.Rollback()
+
+	var count int
+	if err := tx.Get(&count, "SELECT COUNT(*) FROM `courses` WHERE `id` = ?", courseID); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
 	}
-	if myCredits > 0 {
-		myGPA = myGPA / 100 / float64(myCredits)
+	if count == 0 {
+		return c.String(http.StatusNotFound, "No such course.")
 	}
 
-	// GPAの統計値
-	// 一つでも修了した科目がある学生のGPA一覧
-	var gpas []float64
-	query = "SELECT IFNULL(SUM(`submissions`.`score` * `courses`.`credit`), 0) / 100 / `credits`.`credits` AS `gpa`" +
-		" FROM `users`" +
-		" JOIN (" +
-		"     SELECT `users`.`id` AS `user_id`, SUM(`courses`.`credit`) AS `credits`" +
-		"     FROM `users`" +
-		"     JOIN `registrations` ON `users`.`id` = `registrations`.`user_id`" +
-		"     JOIN `courses` ON `registrations`.`course_id` = `courses`.`id` AND `courses`.`status` = ?" +
-		"     GROUP BY `users`.`id`" +
-		" ) AS `credits` ON `credits`.`user_id` = `users`.`id`" +
-		" JOIN `registrations` ON `users`.`id` = `registrations`.`user_id`" +
-		" JOIN `courses` ON `registrations`.`course_id` = `courses`.`id` AND `courses`.`status` = ?" +
-		" LEFT JOIN `classes` ON `courses`.`id` = `classes`.`course_id`" +
-		" LEFT JOIN `submissions` ON `users`.`id` = `submissions`.`user_id` AND `submissions`.`class_id` = `classes`.`id`" +
-		" WHERE `users`.`type` = ?" +
-		" GROUP BY `users`.`id`"
-	if err := h.DB.Select(&gpas, query, StatusClosed, StatusClosed, Student); err != nil {
+	var requisites []RequisiteCourse
+	query := "SELECT `courses`.`id`, `courses`.`code`, `courses`.`name`, `course_requisites`.`type`" +
+		" FROM `course_requisites`" +
+		" JOIN `courses` ON `course_requisites`.`required_course_id` = `courses`.`id`" +
+		" WHERE `course_requisites`.`course_id` = ?" +
+		" ORDER BY `courses`.`code`"
+	if err := tx.Select(&requisites, query, courseID); err != nil {
 		c.Logger().Error(err)
 		return c.NoContent(http.StatusInternalServerError)
 	}
 
-	res := GetGradeResponse{
-		Summary: Summary{
-			Credits:   myCredits,
-			GPA:       myGPA,
-			GpaTScore: tScoreFloat64(myGPA, gpas),
-			GpaAvg:    averageFloat64(gpas, 0),
-			GpaMax:    maxFloat64(gpas, 0),
-			GpaMin:    minFloat64(gpas, 0),
-		},
-		CourseResults: courseResults,
+	if err := tx.Commit(); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	// 要件が0件の時は空配列を返却
+	res := GetCourseRequisitesResponse{
+		Prerequisites: make([]RequisiteCourse, 0),
+		Corequisites:  make([]RequisiteCourse, 0),
+	}
+	for _, r := range requisites {
+		switch r.Type {
+		case Prerequisite:
+			res.Prerequisites = append(res.Prerequisites, r)
+		case Corequisite:
+			res.Corequisites = append(res.Corequisites, r)
+		}
 	}
 
 	return c.JSON(http.StatusOK, res)
 }
 
-// ---------- Courses API ----------
-
-// SearchCourses GET /api/courses 科目検索
-func (h *handlers) SearchCourses(c echo.Context) error {
-	query := "SELECT `courses`.*, `users`.`name` AS `teacher`" +
-		" FROM `courses` JOIN `users` ON `courses`.`teacher_id` = `users`.`id`" +
-		" WHERE 1=1"
-	var condition string
-	var args []interface{}
+type SetCourseRequisitesRequest struct {
+	Prerequisites []string `json:"prerequisites"`
+	Corequisites  []string `json:"corequisites"`
+}
 
-	// 無効な検索条件はエラーを返さず無視して良い
+// SetCourseRequisites PUT /api/courses/:courseID/requisites 科目の履修要件の設定
+func (h *handlers) SetCourseRequisites(c echo.Context) error {
+	courseID := c.Param("courseID")
 
-	if courseType := c.QueryParam("type"); courseType != "" {
-		condition += " AND `courses`.`type` = ?"
-		args = append(args, courseType)
+	var req SetCourseRequisitesRequest
+	if err := c.Bind(&req); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid format.")
 	}
 
-	if credit, err := strconv.Atoi(c.QueryParam("credit")); err == nil && credit > 0 {
-		condition += " AND `courses`.`credit` = ?"
-		args = append(args, credit)
+	requisites := make([]CourseRequisite, 0, len(req.Prerequisites)+len(req.Corequisites))
+	for _, id := range req.Prerequisites {
+		requisites = append(requisites, CourseRequisite{CourseID: courseID, RequiredCourseID: id, Type: Prerequisite})
 	}
-
-	if teacher := c.QueryParam("teacher"); teacher != "" {
-		condition += " AND `users`.`name` = ?"
-		args = append(args, teacher)
+	for _, id := range req.Corequisites {
+		requisites = append(requisites, CourseRequisite{CourseID: courseID, RequiredCourseID: id, Type: Corequisite})
+	}
+	seen := make(map[string]bool, len(requisites))
+	for _, r := range requisites {
+		if r.RequiredCourseID == courseID {
+			return c.String(http.StatusBadRequest, "A course cannot require itself.")
+		}
+		if seen[r.RequiredCourseID] {
+			return c.String(http.StatusBadRequest, "Duplicate course ID.")
+		}
+		seen[r.RequiredCourseID] = true
 	}
 
-	if period, err := strconv.Atoi(c.QueryParam("period")); err == nil && period > 0 {
-		condition += " AND `courses`.`period` = ?"
-		args = append(args, period)
+	tx, err := h.DB.Beginx()
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
 	}
+	defer tx.Rollback()
 
-	if dayOfWeek := c.QueryParam("day_of_week"); dayOfWeek != "" {
-		condition += " AND `courses`.`day_of_week` = ?"
-		args = append(args, dayOfWeek)
+	var count int
+	if err := tx.Get(&count, "SELECT COUNT(*) FROM `courses` WHERE `id` = ?", courseID); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	if count == 0 {
+		return c.String(http.StatusNotFound, "No such course.")
 	}
 
-	if keywords := c.QueryParam("keywords"); keywords != "" {
-		arr := strings.Split(keywords, " ")
-		var nameCondition string
-		for _, keyword := range arr {
-			nameCondition += " AND `courses`.`name` LIKE ?"
-			args = append(args, "%"+keyword+"%")
+	if len(requisites) > 0 {
+		requiredCourseIDs := make([]string, 0, len(requisites))
+		for _, r := range requisites {
+			requiredCourseIDs = append(requiredCourseIDs, r.RequiredCourseID)
+		}
+		query, args, err := sqlx.In("SELECT COUNT(*) FROM `courses` WHERE `id` IN (?)", requiredCourseIDs)
+		if err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		if err := tx.Get(&count, query, args...); err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
 		}
-		var keywordsCondition string
-		for _, keyword := range arr {
-			keywordsCondition += " AND `courses`.`keywords` LIKE ?"
-			args = append(args, "%"+keyword+"%")
+		if count != len(requiredCourseIDs) {
+			return c.String(http.StatusBadRequest, "No such required course.")
 		}
-		condition += fmt.Sprintf(" AND ((1=1%s) OR (1=1%s))", nameCondition, keywordsCondition)
 	}
 
-	if status := c.QueryParam("status"); status != "" {
-		condition += " AND `courses`.`status` = ?"
-		args = append(args, status)
+	if cycle, err := findRequisiteCycle(tx, courseID, requisites); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	} else if cycle != "" {
+		return c.String(http.StatusBadRequest, fmt.Sprintf("Requiring %s makes a cycle of requisites.", cycle))
 	}
 
-	condition += " ORDER BY `courses`.`code`"
-
-	var page int
-	if c.QueryParam("page") == "" {
-		page = 1
-	} else {
-		var err error
-		page, err = strconv.Atoi(c.QueryParam("page"))
-		if err != nil || page <= 0 {
-			return c.String(http.StatusBadRequest, "Invalid page.")
+	if _, err := tx.Exec("DELETE FROM `course_requisites` WHERE `course_id` = ?", courseID); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	for _, r := range requisites {
+		if _, err := tx.Exec("INSERT INTO `course_requisites` (`course_id`, `required_course_id`, `type`) VALUES (?, ?, ?)", r.CourseID, r.RequiredCourseID, r.Type); err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
 		}
 	}
-	limit := 20
-	offset := limit * (page - 1)
 
-	// limitより多く上限を設定し、実際にlimitより多くレコードが取得できた場合は次のページが存在する
-	condition += " LIMIT ? OFFSET ?"
-	args = append(args, limit+1, offset)
+	if err := tx.Commit(); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
 
-	// 結果が0件の時は空配列を返却
-	res := make([]GetCourseDetailResponse, 0)
-	if err := h.DB.Select(&res, query+condition, args...); err != nil {
+	return c.NoContent(http.StatusOK)
+}
+
+type GetCourseLotteryResponse struct {
+	Enabled    bool          `json:"enabled"`
+	Status     LotteryStatus `json:"status,omitempty"`
+	Applicants int           `json:"applicants"`
+	// Seed 教員にのみ返す
+	Seed *int64 `json:"seed,omitempty"`
+}
+
+// GetCourseLottery GET /api/courses/:courseID/lottery 科目の抽選の状態の取得
+func (h *handlers) GetCourseLottery(c echo.Context) error {
+	_, _, isAdmin, err := getUserInfo(c)
+	if err != nil {
 		c.Logger().Error(err)
 		return c.NoContent(http.StatusInternalServerError)
 	}
 
-	var links []string
-	linkURL, err := url.Parse(c.Request().URL.Path + "?" + c.Request().URL.RawQuery)
+	courseID := c.Param("courseID")
+
+	tx, err := h.DB.Beginx()
 	if err != nil {
 		c.Logger().Error(err)
 		return c.NoContent(http.StatusInternalServerError)
 	}
+	defer tx.Rollback()
 
-	q := linkURL.Query()
-	if page > 1 {
-		q.Set("page", strconv.Itoa(page-1))
-		linkURL.RawQuery = q.Encode()
-		links = append(links, fmt.Sprintf("<%v>; rel=\"prev\"", linkURL))
+	var count int
+	if err := tx.Get(&count, "SELECT COUNT(*) FROM `courses` WHERE `id` = ?", courseID); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
 	}
-	if len(res) > limit {
-		q.Set("page", strconv.Itoa(page+1))
-		linkURL.RawQuery = q.Encode()
-		links = append(links, fmt.Sprintf("<%v>; rel=\"next\"", linkURL))
+	if count == 0 {
+		return c.String(http.StatusNotFound, "No such course.")
 	}
-	if len(links) > 0 {
-		c.Response().Header().Set("Link", strings.Join(links, ","))
+
+	var res GetCourseLotteryResponse
+	var lottery CourseLottery
+	if err := tx.Get(&lottery, "SELECT `course_id`, `seed`, `status` FROM `course_lotteries` WHERE `course_id` = ?", courseID); err != nil && err != sql.ErrNoRows {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	} else if err == nil {
+		res.Enabled = true
+		res.Status = lottery.Status
+		if isAdmin {
+			res.Seed = &lottery.Seed
+		}
+		if err := tx.Get(&res.Applicants, "SELECT COUNT(*) FROM `lottery_preferences` WHERE `course_id` = ?", courseID); err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
 	}
 
-	if len(res) == limit+1 {
-		res = res[:len(res)-1]
+	if err := tx.Commit(); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
 	}
 
 	return c.JSON(http.StatusOK, res)
 }
 
-type AddCourseRequest struct {
-	Code        string     `json:"code"`
-	Type        CourseType `json:"type"`
-	Name        string     `json:"name"`
-	Description string     `json:"description"`
-	Credit      int        `json:"credit"`
-	Period      int        `json:"period"`
-	DayOfWeek   DayOfWeek  `json:"day_of_week"`
-	Keywords    string     `json:"keywords"`
+type SetCourseLotteryRequest struct {
+	Enabled bool `json:"enabled"`
+	// Seed 抽選のシード。省略した場合はランダムに決める
+	Seed *int64 `json:"seed"`
 }
 
-type AddCourseResponse struct {
-	ID string `json:"id"`
-}
+// SetCourseLottery PUT /api/courses/:courseID/lottery 科目の抽選の設定
+func (h *handlers) SetCourseLottery(c echo.Context) error {
+	courseID := c.Param("courseID")
 
-// AddCourse POST /api/courses 新規科目登録
-func (h *handlers) AddCourse(c echo.Context) error {
-	userID, _, _, err := getUserInfo(c)
+	var req SetCourseLotteryRequest
+	if err := c.Bind(&req); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid format.")
+	}
+
+	tx, err := h.DB.Beginx()
 	if err != nil {
 		c.Logger().Error(err)
 		return c.NoContent(http.StatusInternalServerError)
 	}
+	defer tx.Rollback()
 
-	var req AddCourseRequest
-	if err := c.Bind(&req); err != nil {
-		return c.String(http.StatusBadRequest, "Invalid format.")
-	}
-
-	if req.Type != LiberalArts && req.Type != MajorSubjects {
-		return c.String(http.StatusBadRequest, "Invalid course type.")
+	var status CourseStatus
+	if err := tx.Get(&status, "SELECT `status` FROM `courses` WHERE `id` = ? FOR UPDATE", courseID); err != nil && err != sql.ErrNoRows {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	} else if err == sql.ErrNoRows {
+		return c.String(http.StatusNotFound, "No such course.")
 	}
-	if !contains(daysOfWeek, req.DayOfWeek) {
-		return c.String(http.StatusBadRequest, "Invalid day of week.")
+	if status != StatusRegistration {
+		return c.String(http.StatusBadRequest, "This course is not in registration.")
 	}
 
-	courseID := newULID()
-	_, err = h.DB.Exec("INSERT INTO `courses` (`id`, `code`, `type`, `name`, `description`, `credit`, `period`, `day_of_week`, `teacher_id`, `keywords`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
-		courseID, req.Code, req.Type, req.Name, req.Description, req.Credit, req.Period, req.DayOfWeek, userID, req.Keywords)
-	if err != nil {
-		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == uint16(mysqlErrNumDuplicateEntry) {
-			var course Course
-			if err := h.DB.Get(&course, "SELECT * FROM `courses` WHERE `code` = ?", req.Code); err != nil {
+	if req.Enabled {
+		var registeredCount int
+		if err := tx.Get(&registeredCount, "SELECT COUNT(*) FROM `registrations` WHERE `course_id` = ?", courseID); err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		if registeredCount > 0 {
+			return c.String(http.StatusBadRequest, "This course already has registered students.")
+		}
+
+		seed := req.Seed
+		if seed == nil {
+			s, err := newLotterySeed()
+			if err != nil {
 				c.Logger().Error(err)
 				return c.NoContent(http.StatusInternalServerError)
 			}
-			if req.Type != course.Type || req.Name != course.Name || req.Description != course.Description || req.Credit != int(course.Credit) || req.Period != int(course.Period) || req.DayOfWeek != course.DayOfWeek || req.Keywords != course.Keywords {
-				return c.String(http.StatusConflict, "A course with the same code already exists.")
-			}
-			return c.JSON(http.StatusCreated, AddCourseResponse{ID: course.ID})
+			seed = &s
+		}
+		if _, err := tx.Exec("INSERT INTO `course_lotteries` (`course_id`, `seed`) VALUES (?, ?) ON DUPLICATE KEY UPDATE `seed` = VALUES(`seed`)", courseID, *seed); err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		// 先着順で履修登録するためにキャンセル待ちしていた学生は、改めて抽選に申し込む
+		if _, err := tx.Exec("DELETE FROM `waitlists` WHERE `course_id` = ?", courseID); err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+	} else {
+		if _, err := tx.Exec("DELETE FROM `lottery_preferences` WHERE `course_id` = ?", courseID); err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
 		}
+		if _, err := tx.Exec("DELETE FROM `course_lotteries` WHERE `course_id` = ?", courseID); err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
 		c.Logger().Error(err)
 		return c.NoContent(http.StatusInternalServerError)
 	}
 
-	return c.JSON(http.StatusCreated, AddCourseResponse{ID: courseID})
+	return c.NoContent(http.StatusOK)
 }
 
-type GetCourseDetailResponse struct {
-	ID          string       `json:"id" db:"id"`
-	Code        string       `json:"code" db:"code"`
-	Type        string       `json:"type" db:"type"`
-	Name        string       `json:"name" db:"name"`
-	Description string       `json:"description" db:"description"`
-	Credit      uint8        `json:"credit" db:"credit"`
-	Period      uint8        `json:"period" db:"period"`
-	DayOfWeek   string       `json:"day_of_week" db:"day_of_week"`
-	TeacherID   string       `json:"-" db:"teacher_id"`
-	Keywords    string       `json:"keywords" db:"keywords"`
-	Status      CourseStatus `json:"status" db:"status"`
-	Teacher     string       `json:"teacher" db:"teacher"`
+type LotteryPreference struct {
+	CourseID string        `json:"course_id" db:"course_id"`
+	Code     string        `json:"code" db:"code"`
+	Name     string        `json:"name" db:"name"`
+	Priority int           `json:"priority" db:"priority"`
+	Result   LotteryResult `json:"result" db:"result"`
 }
 
-// GetCourseDetail GET /api/courses/:courseID 科目詳細の取得
-func (h *handlers) GetCourseDetail(c echo.Context) error {
-	courseID := c.Param("courseID")
+// GetLotteryPreferences GET /api/users/me/lottery-preferences 抽選の申し込みと結果の一覧取得
+func (h *handlers) GetLotteryPreferences(c echo.Context) error {
+	userID, _, _, err := getUserInfo(c)
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
 
-	var res GetCourseDetailResponse
-	query := "SELECT `courses`.*, `users`.`name` AS `teacher`" +
-		" FROM `courses`" +
-		" JOIN `users` ON `courses`.`teacher_id` = `users`.`id`" +
-		" WHERE `courses`.`id` = ?"
-	if err := h.DB.Get(&res, query, courseID); err != nil && err != sql.ErrNoRows {
+	// 申し込みが0件の時は空配列を返却
+	preferences := make([]LotteryPreference, 0)
+	query := "SELECT `courses`.`id` AS `course_id`, `courses`.`code`, `courses`.`name`, `lottery_preferences`.`priority`, `lottery_preferences`.`result`" +
+		" FROM `lottery_preferences`" +
+		" JOIN `courses` ON `lottery_preferences`.`course_id` = `courses`.`id`" +
+		" WHERE `lottery_preferences`.`user_id` = ?" +
+		" ORDER BY `lottery_preferences`.`priority`, `courses`.`code`"
+	if err := h.DB.Select(&preferences, query, userID); err != nil {
 		c.Logger().Error(err)
 		return c.NoContent(http.StatusInternalServerError)
-	} else if err == sql.ErrNoRows {
-		return c.String(http.StatusNotFound, "No such course.")
 	}
 
-	return c.JSON(http.StatusOK, res)
+	return c.JSON(http.StatusOK, preferences)
 }
 
-type SetCourseStatusRequest struct {
-	Status CourseStatus `json:"status"`
+type SetLotteryPreferenceRequestContent struct {
+	CourseID string `json:"course_id"`
+	// Priority 希望順位。1が最も高い
+	Priority int `json:"priority"`
 }
 
-// SetCourseStatus PUT /api/courses/:courseID/status 科目のステータスを変更
-func (h *handlers) SetCourseStatus(c echo.Context) error {
-	courseID := c.Param("courseID")
+// SetLotteryPreferences PUT /api/users/me/lottery-preferences 抽選への申し込み
+// 申し込みを締め切っていない抽選への申し込みをリクエストの内容で置き換える
+func (h *handlers) SetLotteryPreferences(c echo.Context) error {
+	userID, _, _, err := getUserInfo(c)
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
 
-	var req SetCourseStatusRequest
+	var req []SetLotteryPreferenceRequestContent
 	if err := c.Bind(&req); err != nil {
 		return c.String(http.StatusBadRequest, "Invalid format.")
 	}
+	courseIDs := make([]string, 0, len(req))
+	seenCourses := make(map[string]bool, len(req))
+	seenPriorities := make(map[int]bool, len(req))
+	for _, p := range req {
+		if p.Priority < 1 || p.Priority > math.MaxUint8 {
+			return c.String(http.StatusBadRequest, "Invalid priority.")
+		}
+		if seenCourses[p.CourseID] {
+			return c.String(http.StatusBadRequest, "Duplicate course ID.")
+		}
+		if seenPriorities[p.Priority] {
+			return c.String(http.StatusBadRequest, "Duplicate priority.")
+		}
+		seenCourses[p.CourseID] = true
+		seenPriorities[p.Priority] = true
+		courseIDs = append(courseIDs, p.CourseID)
+	}
 
 	tx, err := h.DB.Beginx()
 	if err != nil {
@@ -934,19 +3262,37 @@ func (h *handlers) SetCourseStatus(c echo.Context) error {
 	}
 	defer tx.Rollback()
 
-	var count int
-	if err := tx.Get(&count, "SELECT COUNT(*) FROM `courses` WHERE `id` = ? FOR UPDATE", courseID); err != nil {
-		c.Logger().Error(err)
-		return c.NoContent(http.StatusInternalServerError)
-	}
-	if count == 0 {
-		return c.String(http.StatusNotFound, "No such course.")
+	// 申し込みの締め切りと直列化するため抽選の行を共有ロックする
+	if len(courseIDs) > 0 {
+		var count int
+		query, args, err := sqlx.In("SELECT COUNT(*) FROM `course_lotteries` WHERE `course_id` IN (?) AND `status` = ? FOR SHARE", courseIDs, LotteryOpen)
+		if err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		if err := tx.Get(&count, query, args...); err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		if count != len(courseIDs) {
+			return c.String(http.StatusBadRequest, "No such open lottery.")
+		}
 	}
 
-	if _, err := tx.Exec("UPDATE `courses` SET `status` = ? WHERE `id` = ?", req.Status, courseID); err != nil {
+	query := "DELETE `lottery_preferences`" +
+		" FROM `lottery_preferences`" +
+		" JOIN `course_lotteries` ON `lottery_preferences`.`course_id` = `course_lotteries`.`course_id`" +
+		" WHERE `lottery_preferences`.`user_id` = ? AND `course_lotteries`.`status` = ?"
+	if _, err := tx.Exec(query, userID, LotteryOpen); err != nil {
 		c.Logger().Error(err)
 		return c.NoContent(http.StatusInternalServerError)
 	}
+	for _, p := range req {
+		if _, err := tx.Exec("INSERT INTO `lottery_preferences` (`user_id`, `course_id`, `priority`) VALUES (?, ?, ?)", userID, p.CourseID, p.Priority); err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+	}
 
 	if err := tx.Commit(); err != nil {
 		c.Logger().Error(err)
@@ -963,7 +3309,9 @@ type ClassWithSubmitted struct {
 	Title            string `db:"title"`
 	Description      string `db:"description"`
 	SubmissionClosed bool   `db:"submission_closed"`
-	Submitted        bool   `db:"submitted"`
+	ClassDeadline
+	Submitted bool `db:"submitted"`
+	Late      bool `db:"late"`
 }
 
 type GetClassResponse struct {
@@ -972,7 +3320,9 @@ type GetClassResponse struct {
 	Title            string `json:"title"`
 	Description      string `json:"description"`
 	SubmissionClosed bool   `json:"submission_closed"`
-	Submitted        bool   `json:"submitted"`
+	ClassDeadline
+	Submitted bool `json:"submitted"`
+	Late      bool `json:"late"` // 自分の提出が遅延提出かどうか
 }
 
 // GetClasses GET /api/courses/:courseID/classes 科目に紐づく講義一覧の取得
@@ -1002,7 +3352,7 @@ func (h *handlers) GetClasses(c echo.Context) error {
 	}
 
 	var classes []ClassWithSubmitted
-	query := "SELECT `classes`.*, `submissions`.`user_id` IS NOT NULL AS `submitted`" +
+	query := "SELECT `classes`.*, `submissions`.`user_id` IS NOT NULL AS `submitted`, IFNULL(`submissions`.`late`, false) AS `late`" +
 		" FROM `classes`" +
 		" LEFT JOIN `submissions` ON `classes`.`id` = `submissions`.`class_id` AND `submissions`.`user_id` = ?" +
 		" WHERE `classes`.`course_id` = ?" +
@@ -1026,7 +3376,9 @@ func (h *handlers) GetClasses(c echo.Context) error {
 			Title:            class.Title,
 			Description:      class.Description,
 			SubmissionClosed: class.SubmissionClosed,
+			ClassDeadline:    class.ClassDeadline,
 			Submitted:        class.Submitted,
+			Late:             class.Late,
 		})
 	}
 
@@ -1037,6 +3389,7 @@ type AddClassRequest struct {
 	Part        uint8  `json:"part"`
 	Title       string `json:"title"`
 	Description string `json:"description"`
+	ClassDeadline
 }
 
 type AddClassResponse struct {
@@ -1051,6 +3404,9 @@ func (h *handlers) AddClass(c echo.Context) error {
 	if err := c.Bind(&req); err != nil {
 		return c.String(http.StatusBadRequest, "Invalid format.")
 	}
+	if msg := req.ClassDeadline.validate(); msg != "" {
+		return c.String(http.StatusBadRequest, msg)
+	}
 
 	tx, err := h.DB.Beginx()
 	if err != nil {
@@ -1071,8 +3427,8 @@ func (h *handlers) AddClass(c echo.Context) error {
 	}
 
 	classID := newULID()
-	if _, err := tx.Exec("INSERT INTO `classes` (`id`, `course_id`, `part`, `title`, `description`) VALUES (?, ?, ?, ?, ?)",
-		classID, courseID, req.Part, req.Title, req.Description); err != nil {
+	if _, err := tx.Exec("INSERT INTO `classes` (`id`, `course_id`, `part`, `title`, `description`, `deadline`, `late_until`, `late_penalty`) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		classID, courseID, req.Part, req.Title, req.Description, req.Deadline, req.LateUntil, req.LatePenalty); err != nil {
 		_ = tx.Rollback()
 		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == uint16(mysqlErrNumDuplicateEntry) {
 			var class Class
@@ -1080,7 +3436,7 @@ func (h *handlers) AddClass(c echo.Context) error {
 				c.Logger().Error(err)
 				return c.NoContent(http.StatusInternalServerError)
 			}
-			if req.Title != class.Title || req.Description != class.Description {
+			if req.Title != class.Title || req.Description != class.Description || !req.ClassDeadline.equal(class.ClassDeadline) {
 				return c.String(http.StatusConflict, "A class with the same part already exists.")
 			}
 			return c.JSON(http.StatusCreated, AddClassResponse{ClassID: class.ID})
@@ -1108,6 +3464,29 @@ func (h *handlers) SubmitAssignment(c echo.Context) error {
 	courseID := c.Param("courseID")
 	classID := c.Param("classID")
 
+	file, header, err := c.Request().FormFile("file")
+	if err != nil {
+		return c.String(http.StatusBadRequest, "Invalid file.")
+	}
+	defer file.Close()
+
+	// 講義の行をロックしたまま書き込まないよう、課題ファイルはトランザクションの前に提出ごとの新しいキーで書き込む
+	// 提出を記録できなかった場合は書き込んだファイルを削除し、記録できた場合は前回の提出のファイルを削除する
+	key := newSubmissionKey(classID, userID)
+	if err := h.Storage.Put(c.Request().Context(), key, file); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	obsoleteKey := key
+	defer func() {
+		if obsoleteKey == "" {
+			return
+		}
+		if err := h.Storage.Delete(context.Background(), obsoleteKey); err != nil {
+			c.Logger().Error(err)
+		}
+	}()
+
 	tx, err := h.DB.Beginx()
 	if err != nil {
 		c.Logger().Error(err)
@@ -1135,43 +3514,40 @@ func (h *handlers) SubmitAssignment(c echo.Context) error {
 		return c.String(http.StatusBadRequest, "You have not taken this course.")
 	}
 
-	var submissionClosed bool
-	if err := tx.Get(&submissionClosed, "SELECT `submission_closed` FROM `classes` WHERE `id` = ? FOR SHARE", classID); err != nil && err != sql.ErrNoRows {
+	var class Class
+	if err := tx.Get(&class, "SELECT * FROM `classes` WHERE `id` = ? AND `course_id` = ? FOR SHARE", classID, courseID); err != nil && err != sql.ErrNoRows {
 		c.Logger().Error(err)
 		return c.NoContent(http.StatusInternalServerError)
 	} else if err == sql.ErrNoRows {
 		return c.String(http.StatusNotFound, "No such class.")
 	}
-	if submissionClosed {
+	if class.SubmissionClosed {
 		return c.String(http.StatusBadRequest, "Submission has been closed for this class.")
 	}
-
-	file, header, err := c.Request().FormFile("file")
-	if err != nil {
-		return c.String(http.StatusBadRequest, "Invalid file.")
+	accepted, late := class.acceptSubmission(time.Now())
+	if !accepted {
+		return c.String(http.StatusBadRequest, "The deadline for this assignment has passed.")
 	}
-	defer file.Close()
 
-	if _, err := tx.Exec("INSERT INTO `submissions` (`user_id`, `class_id`, `file_name`) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE `file_name` = VALUES(`file_name`)", userID, classID, header.Filename); err != nil {
+	var previous []Submission
+	if err := tx.Select(&previous, "SELECT `user_id`, `storage_key` FROM `submissions` WHERE `user_id` = ? AND `class_id` = ? FOR UPDATE", userID, classID); err != nil {
 		c.Logger().Error(err)
 		return c.NoContent(http.StatusInternalServerError)
 	}
 
-	data, err := io.ReadAll(file)
-	if err != nil {
+	if _, err := tx.Exec("INSERT INTO `submissions` (`user_id`, `class_id`, `file_name`, `storage_key`, `late`) VALUES (?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE `file_name` = VALUES(`file_name`), `storage_key` = VALUES(`storage_key`), `late` = VALUES(`late`), `submitted_at` = NOW(6)", userID, classID, header.Filename, key, late); err != nil {
 		c.Logger().Error(err)
 		return c.NoContent(http.StatusInternalServerError)
 	}
 
-	dst := AssignmentsDirectory + classID + "-" + userID + ".pdf"
-	if err := os.WriteFile(dst, data, 0666); err != nil {
+	if err := tx.Commit(); err != nil {
 		c.Logger().Error(err)
 		return c.NoContent(http.StatusInternalServerError)
 	}
 
-	if err := tx.Commit(); err != nil {
-		c.Logger().Error(err)
-		return c.NoContent(http.StatusInternalServerError)
+	obsoleteKey = ""
+	if len(previous) > 0 {
+		obsoleteKey = previous[0].key(classID)
 	}
 
 	return c.NoContent(http.StatusNoContent)
@@ -1184,6 +3560,7 @@ type Score struct {
 
 // RegisterScores PUT /api/courses/:courseID/classes/:classID/assignments/scores 採点結果登録
 func (h *handlers) RegisterScores(c echo.Context) error {
+	courseID := c.Param("courseID")
 	classID := c.Param("classID")
 
 	tx, err := h.DB.Beginx()
@@ -1194,7 +3571,7 @@ func (h *handlers) RegisterScores(c echo.Context) error {
 	defer tx.Rollback()
 
 	var submissionClosed bool
-	if err := tx.Get(&submissionClosed, "SELECT `submission_closed` FROM `classes` WHERE `id` = ? FOR SHARE", classID); err != nil && err != sql.ErrNoRows {
+	if err := tx.Get(&submissionClosed, "SELECT `submission_closed` FROM `classes` WHERE `id` = ? AND `course_id` = ? FOR SHARE", classID, courseID); err != nil && err != sql.ErrNoRows {
 		c.Logger().Error(err)
 		return c.NoContent(http.StatusInternalServerError)
 	} else if err == sql.ErrNoRows {
@@ -1210,6 +3587,13 @@ func (h *handlers) RegisterScores(c echo.Context) error {
 		return c.String(http.StatusBadRequest, "Invalid format.")
 	}
 
+	// 同じ科目の総合点の再計算が並行して行われないよう、採点結果の更新前に科目をロックする
+	var course Course
+	if err := tx.Get(&course, "SELECT * FROM `courses` WHERE `id` = ? FOR UPDATE", courseID); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
 	for _, score := range req {
 		if _, err := tx.Exec("UPDATE `submissions` JOIN `users` ON `users`.`id` = `submissions`.`user_id` SET `score` = ? WHERE `users`.`code` = ? AND `class_id` = ?", score.Score, score.UserCode, classID); err != nil {
 			c.Logger().Error(err)
@@ -1217,6 +3601,11 @@ func (h *handlers) RegisterScores(c echo.Context) error {
 		}
 	}
 
+	if err := refreshCourseTotalScores(tx, courseID); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
 	if err := tx.Commit(); err != nil {
 		c.Logger().Error(err)
 		return c.NoContent(http.StatusInternalServerError)
@@ -1225,16 +3614,20 @@ func (h *handlers) RegisterScores(c echo.Context) error {
 	return c.NoContent(http.StatusNoContent)
 }
 
-type Submission struct {
-	UserID   string `db:"user_id"`
-	UserCode string `db:"user_code"`
-	FileName string `db:"file_name"`
+type SetSubmissionStatusRequest struct {
+	Closed bool `json:"closed"`
 }
 
-// DownloadSubmittedAssignments GET /api/courses/:courseID/classes/:classID/assignments/export 提出済みの課題ファイルをzip形式で一括ダウンロード
-func (h *handlers) DownloadSubmittedAssignments(c echo.Context) error {
+// SetSubmissionStatus PUT /api/courses/:courseID/classes/:classID/submission-status 課題の提出の締め切り・再開
+func (h *handlers) SetSubmissionStatus(c echo.Context) error {
+	courseID := c.Param("courseID")
 	classID := c.Param("classID")
 
+	var req SetSubmissionStatusRequest
+	if err := c.Bind(&req); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid format.")
+	}
+
 	tx, err := h.DB.Beginx()
 	if err != nil {
 		c.Logger().Error(err)
@@ -1242,65 +3635,297 @@ func (h *handlers) DownloadSubmittedAssignments(c echo.Context) error {
 	}
 	defer tx.Rollback()
 
-	var classCount int
-	if err := tx.Get(&classCount, "SELECT COUNT(*) FROM `classes` WHERE `id` = ? FOR UPDATE", classID); err != nil {
+	var status CourseStatus
+	if err := tx.Get(&status, "SELECT `status` FROM `courses` WHERE `id` = ? FOR SHARE", courseID); err != nil && err != sql.ErrNoRows {
 		c.Logger().Error(err)
 		return c.NoContent(http.StatusInternalServerError)
+	} else if err == sql.ErrNoRows {
+		return c.String(http.StatusNotFound, "No such course.")
 	}
-	if classCount == 0 {
+
+	// 提出中の課題の書き込みを待ってから締め切るため、講義の行を排他ロックする
+	var submissionClosed bool
+	if err := tx.Get(&submissionClosed, "SELECT `submission_closed` FROM `classes` WHERE `id` = ? AND `course_id` = ? FOR UPDATE", classID, courseID); err != nil && err != sql.ErrNoRows {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	} else if err == sql.ErrNoRows {
 		return c.String(http.StatusNotFound, "No such class.")
 	}
-	var submissions []Submission
-	query := "SELECT `submissions`.`user_id`, `submissions`.`file_name`, `users`.`code` AS `user_code`" +
-		" FROM `submissions`" +
-		" JOIN `users` ON `users`.`id` = `submissions`.`user_id`" +
-		" WHERE `class_id` = ?"
-	if err := tx.Select(&submissions, query, classID); err != nil {
+
+	// 同じ状態への変更はリトライとみなして何もせず成功させる
+	if submissionClosed == req.Closed {
+		return c.NoContent(http.StatusOK)
+	}
+	if !req.Closed && status != StatusInProgress {
+		return c.String(http.StatusBadRequest, "This course is not in progress.")
+	}
+
+	if _, err := tx.Exec("UPDATE `classes` SET `submission_closed` = ? WHERE `id` = ?", req.Closed, classID); err != nil {
 		c.Logger().Error(err)
 		return c.NoContent(http.StatusInternalServerError)
 	}
 
-	zipFilePath := AssignmentsDirectory + classID + ".zip"
-	if err := createSubmissionsZip(zipFilePath, classID, submissions); err != nil {
+	if err := tx.Commit(); err != nil {
 		c.Logger().Error(err)
 		return c.NoContent(http.StatusInternalServerError)
 	}
 
-	if _, err := tx.Exec("UPDATE `classes` SET `submission_closed` = true WHERE `id` = ?", classID); err != nil {
+	return c.NoContent(http.StatusOK)
+}
+
+type Submission struct {
+	UserID      string         `db:"user_id"`
+	UserCode    string         `db:"user_code"`
+	FileName    string         `db:"file_name"`
+	StorageKey  sql.NullString `db:"storage_key"`
+	SubmittedAt time.Time      `db:"submitted_at"`
+}
+
+// key 提出された課題ファイルの Storage でのキー
+func (s Submission) key(classID string) string {
+	if s.StorageKey.Valid {
+		return s.StorageKey.String
+	}
+	return submissionKey(classID, s.UserID)
+}
+
+// DownloadSubmittedAssignments GET /api/courses/:courseID/classes/:classID/assignments/export 提出済みの課題ファイルをzip形式で一括ダウンロード
+// 提出された課題が前回のダウンロードから変わっていなければ 304 を返す
+// CloseSubmissionsOnExport が有効な場合は、従来どおりダウンロードの前に課題の提出を締め切る。
+// ただし If-None-Match を付けた条件付きリクエストは従来のクライアントからは送られないため、締め切らずに応答する
+func (h *handlers) DownloadSubmittedAssignments(c echo.Context) error {
+	courseID := c.Param("courseID")
+	classID := c.Param("classID")
+
+	if h.CloseSubmissionsOnExport && c.Request().Header.Get("If-None-Match") == "" {
+		result, err := h.DB.Exec("UPDATE `classes` SET `submission_closed` = true WHERE `id` = ? AND `course_id` = ?", classID, courseID)
+		if err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		} else if n == 0 {
+			// すでに締め切られている講義も更新されないため、存在するかを改めて確認する
+			var classCount int
+			if err := h.DB.Get(&classCount, "SELECT COUNT(*) FROM `classes` WHERE `id` = ? AND `course_id` = ?", classID, courseID); err != nil {
+				c.Logger().Error(err)
+				return c.NoContent(http.StatusInternalServerError)
+			}
+			if classCount == 0 {
+				return c.String(http.StatusNotFound, "No such class.")
+			}
+		}
+	} else {
+		var classCount int
+		if err := h.DB.Get(&classCount, "SELECT COUNT(*) FROM `classes` WHERE `id` = ? AND `course_id` = ?", classID, courseID); err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		if classCount == 0 {
+			return c.String(http.StatusNotFound, "No such class.")
+		}
+	}
+
+	var submissions []Submission
+	query := "SELECT `submissions`.`user_id`, `submissions`.`file_name`, `submissions`.`storage_key`, `submissions`.`submitted_at`, `users`.`code` AS `user_code`" +
+		" FROM `submissions`" +
+		" JOIN `users` ON `users`.`id` = `submissions`.`user_id`" +
+		" WHERE `class_id` = ?" +
+		" ORDER BY `users`.`code`"
+	if err := h.DB.Select(&submissions, query, classID); err != nil {
 		c.Logger().Error(err)
 		return c.NoContent(http.StatusInternalServerError)
 	}
 
-	if err := tx.Commit(); err != nil {
+	etag := submissionsETag(classID, submissions)
+	c.Response().Header().Set("ETag", etag)
+	if etagMatches(c.Request().Header.Get("If-None-Match"), etag) {
+		return c.NoContent(http.StatusNotModified)
+	}
+
+	// レスポンスを書き始めた後はエラーを返せないため、先に課題ファイルが揃っていることを確認する
+	stored, err := h.Storage.List(c.Request().Context(), classID+"-")
+	if err != nil {
 		c.Logger().Error(err)
 		return c.NoContent(http.StatusInternalServerError)
 	}
+	storedKeys := make(map[string]bool, len(stored))
+	for _, object := range stored {
+		storedKeys[object.Key] = true
+	}
+	for _, submission := range submissions {
+		if key := submission.key(classID); !storedKeys[key] {
+			c.Logger().Error(fmt.Errorf("assignment file not found: %s", key))
+			return c.NoContent(http.StatusInternalServerError)
+		}
+	}
 
-	return c.File(zipFilePath)
+	c.Response().Header().Set(echo.HeaderContentType, "application/zip")
+	c.Response().Header().Set(echo.HeaderContentDisposition, mime.FormatMediaType("attachment", map[string]string{"filename": classID + ".zip"}))
+	c.Response().WriteHeader(http.StatusOK)
+	if err := writeSubmissionsZip(c.Request().Context(), h.Storage, c.Response(), classID, submissions); err != nil {
+		// 中央ディレクトリが書き込まれないため、クライアントからは壊れた zip に見える
+		c.Logger().Error(err)
+	}
+	return nil
 }
 
-func createSubmissionsZip(zipFilePath string, classID string, submissions []Submission) error {
-	tmpDir := AssignmentsDirectory + classID + "/"
-	if err := exec.Command("rm", "-rf", tmpDir).Run(); err != nil {
-		return err
+// submissionsETag 提出された課題の一覧から zip の ETag を求める
+func submissionsETag(classID string, submissions []Submission) string {
+	h := sha256.New()
+	io.WriteString(h, classID)
+	for _, submission := range submissions {
+		fmt.Fprintf(h, "\x00%s\x00%s\x00%d", submission.UserCode, submission.FileName, submission.SubmittedAt.UnixNano())
 	}
-	if err := exec.Command("mkdir", tmpDir).Run(); err != nil {
-		return err
+	return `"` + hex.EncodeToString(h.Sum(nil)) + `"`
+}
+
+// etagMatches If-None-Match ヘッダの値が etag にマッチするかを返す。比較は弱い比較で行う
+func etagMatches(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
 	}
+	return false
+}
+
+// submissionKey storage_key が NULL の提出(初期データなど)の課題ファイルの Storage でのキー
+func submissionKey(classID string, userID string) string {
+	return classID + "-" + userID + ".pdf"
+}
+
+// newSubmissionKey 新たに提出された課題ファイルの Storage でのキー。提出のたびに異なるキーになる
+func newSubmissionKey(classID string, userID string) string {
+	return classID + "-" + userID + "-" + newULID() + ".pdf"
+}
 
-	// ファイル名を指定の形式に変更
+// submissionEntryName zip 内の課題ファイルの名前(<学内コード>-<提出時のファイル名>)
+// 展開時に別のディレクトリへ書き込まれないよう、パスの区切り文字を置き換える
+func submissionEntryName(submission Submission) string {
+	name := submission.UserCode + "-" + submission.FileName
+	return strings.NewReplacer("/", "_", "\\", "_").Replace(name)
+}
+
+// writeSubmissionsZip 提出された課題ファイルを一つずつ読み、zip 形式で w に書き出す
+func writeSubmissionsZip(ctx context.Context, storage Storage, w io.Writer, classID string, submissions []Submission) error {
+	zw := zip.NewWriter(w)
 	for _, submission := range submissions {
-		if err := exec.Command(
-			"cp",
-			AssignmentsDirectory+classID+"-"+submission.UserID+".pdf",
-			tmpDir+submission.UserCode+"-"+submission.FileName,
-		).Run(); err != nil {
+		if err := addSubmissionToZip(ctx, storage, zw, classID, submission); err != nil {
 			return err
 		}
 	}
+	return zw.Close()
+}
+
+func addSubmissionToZip(ctx context.Context, storage Storage, zw *zip.Writer, classID string, submission Submission) error {
+	file, err := storage.Get(ctx, submission.key(classID))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	entry, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     submissionEntryName(submission),
+		Method:   zip.Deflate,
+		Modified: submission.SubmittedAt,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(entry, file)
+	return err
+}
+
+// ---------- Semester API ----------
+
+// GetSemesters GET /api/semesters 学期一覧の取得
+func (h *handlers) GetSemesters(c echo.Context) error {
+	// 学期が0件の時は空配列を返却
+	semesters := make([]Semester, 0)
+	if err := h.DB.Select(&semesters, "SELECT "+semesterColumns+" FROM `semesters` ORDER BY `starts_on`, `id`"); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.JSON(http.StatusOK, semesters)
+}
+
+type AddSemesterRequest struct {
+	Code        string `json:"code"`
+	Name        string `json:"name"`
+	StartsOn    string `json:"starts_on"`    // YYYY-MM-DD
+	CreditLimit *int   `json:"credit_limit"` // 省略時は上限なし
+}
+
+type AddSemesterResponse struct {
+	ID string `json:"id"`
+}
+
+// AddSemester POST /api/semesters 学期の追加
+func (h *handlers) AddSemester(c echo.Context) error {
+	var req AddSemesterRequest
+	if err := c.Bind(&req); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid format.")
+	}
+	if req.Code == "" {
+		return c.String(http.StatusBadRequest, "Invalid code.")
+	}
+	if _, err := time.Parse("2006-01-02", req.StartsOn); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid starts_on.")
+	}
+	if req.CreditLimit != nil && *req.CreditLimit < 0 {
+		return c.String(http.StatusBadRequest, "Invalid credit_limit.")
+	}
+
+	semesterID := newULID()
+	if _, err := h.DB.Exec("INSERT INTO `semesters` (`id`, `code`, `name`, `starts_on`, `credit_limit`) VALUES (?, ?, ?, ?, ?)",
+		semesterID, req.Code, req.Name, req.StartsOn, req.CreditLimit); err != nil {
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == uint16(mysqlErrNumDuplicateEntry) {
+			return c.String(http.StatusConflict, "A semester with the same code already exists.")
+		}
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.JSON(http.StatusCreated, AddSemesterResponse{ID: semesterID})
+}
+
+type SetSemesterCreditLimitRequest struct {
+	CreditLimit *int `json:"credit_limit"` // null の場合は上限なし
+}
+
+// SetSemesterCreditLimit PUT /api/semesters/:semesterID/credit-limit 学期の履修単位数の上限の設定
+// 既に上限を超えて履修している学生の履修登録は取り消さない
+func (h *handlers) SetSemesterCreditLimit(c echo.Context) error {
+	semesterID := c.Param("semesterID")
+
+	var req SetSemesterCreditLimitRequest
+	if err := c.Bind(&req); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid format.")
+	}
+	if req.CreditLimit != nil && *req.CreditLimit < 0 {
+		return c.String(http.StatusBadRequest, "Invalid credit_limit.")
+	}
+
+	var count int
+	if err := h.DB.Get(&count, "SELECT COUNT(*) FROM `semesters` WHERE `id` = ?", semesterID); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	if count == 0 {
+		return c.String(http.StatusNotFound, "No such semester.")
+	}
 
-	// -i 'tmpDir/*': 空zipを許す
-	return exec.Command("zip", "-j", "-r", zipFilePath, tmpDir, "-i", tmpDir+"*").Run()
+	if _, err := h.DB.Exec("UPDATE `semesters` SET `credit_limit` = ? WHERE `id` = ?", req.CreditLimit, semesterID); err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.NoContent(http.StatusOK)
 }
 
 // ---------- Announcement API ----------
@@ -1326,19 +3951,16 @@ func (h *handlers) GetAnnouncementList(c echo.Context) error {
 		return c.NoContent(http.StatusInternalServerError)
 	}
 
-	tx, err := h.DB.Beginx()
-	if err != nil {
-		c.Logger().Error(err)
-		return c.NoContent(http.StatusInternalServerError)
+	p, msg := parsePaging(c)
+	if msg != "" {
+		return c.String(http.StatusBadRequest, msg)
 	}
-	defer tx.Rollback()
 
 	var announcements []AnnouncementWithoutDetail
 	var args []interface{}
 	query := "SELECT `announcements`.`id`, `courses`.`id` AS `course_id`, `courses`.`name` AS `course_name`, `announcements`.`title`, NOT `unread_announcements`.`is_deleted` AS `unread`" +
 		" FROM `announcements`" +
 		" JOIN `courses` ON `announcements`.`course_id` = `courses`.`id`" +
-		" JOIN `registrations` ON `courses`.`id` = `registrations`.`course_id`" +
 		" JOIN `unread_announcements` ON `announcements`.`id` = `unread_announcements`.`announcement_id`" +
 		" WHERE 1=1"
 
@@ -1347,25 +3969,34 @@ func (h *handlers) GetAnnouncementList(c echo.Context) error {
 		args = append(args, courseID)
 	}
 
+	if p.cursor != nil {
+		if p.cursor.Before {
+			query += " AND `announcements`.`id` > ?"
+		} else {
+			query += " AND `announcements`.`id` < ?"
+		}
+		args = append(args, p.cursor.Key)
+	}
+
+	// 前のページは逆順に取得する
+	order := " DESC"
+	if p.backward() {
+		order = ""
+	}
+	// お知らせの宛先は unread_announcements の行で決まる
+	// 履修者へのお知らせは履修取り消し時に行を削除し、抽選結果のお知らせは履修していない落選者にも届ける
 	query += " AND `unread_announcements`.`user_id` = ?" +
-		" AND `registrations`.`user_id` = ?" +
-		" ORDER BY `announcements`.`id` DESC" +
+		" ORDER BY `announcements`.`id`" + order +
 		" LIMIT ? OFFSET ?"
-	args = append(args, userID, userID)
+	// limitより多く上限を設定し、実際にlimitより多くレコードが取得できた場合は次のページが存在する
+	args = append(args, userID, p.limit+1, p.offset)
 
-	var page int
-	if c.QueryParam("page") == "" {
-		page = 1
-	} else {
-		page, err = strconv.Atoi(c.QueryParam("page"))
-		if err != nil || page <= 0 {
-			return c.String(http.StatusBadRequest, "Invalid page.")
-		}
+	tx, err := h.DB.Beginx()
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
 	}
-	limit := 20
-	offset := limit * (page - 1)
-	// limitより多く上限を設定し、実際にlimitより多くレコードが取得できた場合は次のページが存在する
-	args = append(args, limit+1, offset)
+	defer tx.Rollback()
 
 	if err := tx.Select(&announcements, query, args...); err != nil {
 		c.Logger().Error(err)
@@ -1383,30 +4014,28 @@ func (h *handlers) GetAnnouncementList(c echo.Context) error {
 		return c.NoContent(http.StatusInternalServerError)
 	}
 
-	var links []string
-	linkURL, err := url.Parse(c.Request().URL.Path + "?" + c.Request().URL.RawQuery)
-	if err != nil {
-		c.Logger().Error(err)
-		return c.NoContent(http.StatusInternalServerError)
-	}
-
-	q := linkURL.Query()
-	if page > 1 {
-		q.Set("page", strconv.Itoa(page-1))
-		linkURL.RawQuery = q.Encode()
-		links = append(links, fmt.Sprintf("<%v>; rel=\"prev\"", linkURL))
-	}
-	if len(announcements) > limit {
-		q.Set("page", strconv.Itoa(page+1))
-		linkURL.RawQuery = q.Encode()
-		links = append(links, fmt.Sprintf("<%v>; rel=\"next\"", linkURL))
+	hasPrev, hasNext := p.hasPrevNext(len(announcements))
+	if len(announcements) > p.limit {
+		announcements = announcements[:p.limit]
 	}
-	if len(links) > 0 {
-		c.Response().Header().Set("Link", strings.Join(links, ","))
+	if p.backward() {
+		for i, j := 0, len(announcements)-1; i < j; i, j = i+1, j-1 {
+			announcements[i], announcements[j] = announcements[j], announcements[i]
+		}
 	}
 
-	if len(announcements) == limit+1 {
-		announcements = announcements[:len(announcements)-1]
+	if len(announcements) > 0 {
+		var prev, next *pageCursor
+		if hasPrev {
+			prev = &pageCursor{Before: true, Key: announcements[0].ID}
+		}
+		if hasNext {
+			next = &pageCursor{Key: announcements[len(announcements)-1].ID}
+		}
+		if err := setPagingLinks(c, prev, next); err != nil {
+			c.Logger().Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
 	}
 
 	// 対象になっているお知らせが0件の時は空配列を返却
@@ -1434,6 +4063,12 @@ type AddAnnouncementRequest struct {
 
 // AddAnnouncement POST /api/announcements 新規お知らせ追加
 func (h *handlers) AddAnnouncement(c echo.Context) error {
+	userID, _, _, err := getUserInfo(c)
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
 	var req AddAnnouncementRequest
 	if err := c.Bind(&req); err != nil {
 		return c.String(http.StatusBadRequest, "Invalid format.")
@@ -1455,6 +4090,15 @@ func (h *handlers) AddAnnouncement(c echo.Context) error {
 		return c.String(http.StatusNotFound, "No such course.")
 	}
 
+	isTeacher, err := isCourseTeacher(tx, req.CourseID, userID)
+	if err != nil {
+		c.Logger().Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	if !isTeacher {
+		return c.String(http.StatusForbidden, "You are not a teacher of this course.")
+	}
+
 	if _, err := tx.Exec("INSERT INTO `announcements` (`id`, `course_id`, `title`, `message`) VALUES (?, ?, ?, ?)",
 		req.ID, req.CourseID, req.Title, req.Message); err != nil {
 		_ = tx.Rollback()
@@ -1537,15 +4181,6 @@ func (h *handlers) GetAnnouncementDetail(c echo.Context) error {
 		return c.String(http.StatusNotFound, "No such announcement.")
 	}
 
-	var registrationCount int
-	if err := tx.Get(&registrationCount, "SELECT COUNT(*) FROM `registrations` WHERE `course_id` = ? AND `user_id` = ?", announcement.CourseID, userID); err != nil {
-		c.Logger().Error(err)
-		return c.NoContent(http.StatusInternalServerError)
-	}
-	if registrationCount == 0 {
-		return c.String(http.StatusNotFound, "No such announcement.")
-	}
-
 	if _, err := tx.Exec("UPDATE `unread_announcements` SET `is_deleted` = true WHERE `announcement_id` = ? AND `user_id` = ?", announcementID, userID); err != nil {
 		c.Logger().Error(err)
 		return c.NoContent(http.StatusInternalServerError)
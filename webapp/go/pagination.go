@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// 一覧APIのページング
+//
+// ?cursor= には前のレスポンスの Link ヘッダで返したカーソルを指定する。カーソルは並び順のキーを持つため、
+// ページを移動する間に行が追加・削除されても結果がずれない。
+// 互換性のため ?page= による OFFSET でのページングも受け付けるが、Link ヘッダは常にカーソルで返す。
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// pageCursor ページの境界となる行のキー。クライアントにとっては不透明な文字列として扱う
+type pageCursor struct {
+	// Before true の場合はキーより前のページ、false の場合はキーより後ろのページを指す
+	Before bool   `json:"b,omitempty"`
+	Key    string `json:"k"`
+	// Score 関連度順の科目検索の場合のみ、キーの行の関連度を持つ
+	Score *float64 `json:"s,omitempty"`
+}
+
+func (p *pageCursor) encode() (string, error) {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func decodePageCursor(s string) (*pageCursor, bool) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, false
+	}
+	var p pageCursor
+	if err := json.Unmarshal(b, &p); err != nil || p.Key == "" {
+		return nil, false
+	}
+	return &p, true
+}
+
+type paging struct {
+	limit  int
+	offset int
+	// cursor nil の場合は offset でページングする
+	cursor *pageCursor
+}
+
+// parsePaging limit, cursor, page クエリパラメータを読む。不正な値の場合はエラーメッセージを返す
+func parsePaging(c echo.Context) (paging, string) {
+	p := paging{limit: defaultPageLimit}
+
+	if c.QueryParam("limit") != "" {
+		limit, err := strconv.Atoi(c.QueryParam("limit"))
+		if err != nil || limit <= 0 {
+			return p, "Invalid limit."
+		}
+		if limit > maxPageLimit {
+			limit = maxPageLimit
+		}
+		p.limit = limit
+	}
+
+	if c.QueryParam("cursor") != "" {
+		cursor, ok := decodePageCursor(c.QueryParam("cursor"))
+		if !ok {
+			return p, "Invalid cursor."
+		}
+		p.cursor = cursor
+		return p, ""
+	}
+
+	if c.QueryParam("page") != "" {
+		page, err := strconv.Atoi(c.QueryParam("page"))
+		if err != nil || page <= 0 {
+			return p, "Invalid page."
+		}
+		p.offset = p.limit * (page - 1)
+	}
+
+	return p, ""
+}
+
+// backward 前のページに戻るカーソルの場合は true。この場合は逆順に取得して最後に並べ直す
+func (p paging) backward() bool {
+	return p.cursor != nil && p.cursor.Before
+}
+
+// hasPrevNext limit+1 件を上限に取得した件数から前後のページが存在するかを返す
+func (p paging) hasPrevNext(n int) (prev bool, next bool) {
+	more := n > p.limit
+	switch {
+	case p.cursor == nil:
+		return p.offset > 0, more
+	case p.cursor.Before:
+		// カーソルのキーの行が後ろのページにある
+		return more, true
+	default:
+		// カーソルのキーの行が前のページにある
+		return true, more
+	}
+}
+
+// setPagingLinks 前後のページのカーソルを RFC 8288 の Link ヘッダに設定する
+func setPagingLinks(c echo.Context, prev *pageCursor, next *pageCursor) error {
+	linkURL, err := url.Parse(c.Request().URL.Path + "?" + c.Request().URL.RawQuery)
+	if err != nil {
+		return err
+	}
+
+	var links []string
+	q := linkURL.Query()
+	q.Del("page")
+	for _, link := range []struct {
+		cursor *pageCursor
+		rel    string
+	}{{prev, "prev"}, {next, "next"}} {
+		if link.cursor == nil {
+			continue
+		}
+		cursor, err := link.cursor.encode()
+		if err != nil {
+			return err
+		}
+		q.Set("cursor", cursor)
+		linkURL.RawQuery = q.Encode()
+		links = append(links, fmt.Sprintf("<%v>; rel=\"%s\"", linkURL, link.rel))
+	}
+	if len(links) > 0 {
+		c.Response().Header().Set("Link", strings.Join(links, ","))
+	}
+
+	return nil
+}
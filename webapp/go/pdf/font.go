@@ -0,0 +1,157 @@
+package pdf
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+)
+
+// Font is one of the fonts every PDF reader provides, so font programs are never embedded.
+type Font struct {
+	baseFont string
+	// cid is true for the Japanese font, whose text is written as UTF-16BE through a predefined CMap
+	cid bool
+}
+
+var (
+	Helvetica     = &Font{baseFont: "Helvetica"}
+	HelveticaBold = &Font{baseFont: "Helvetica-Bold"}
+	// Gothic is a Japanese font from the Adobe-Japan1 collection. ASCII characters are drawn in half width.
+	Gothic = &Font{baseFont: "HeiseiKakuGo-W5", cid: true}
+)
+
+// encode returns a string operand for the text showing operators.
+func (f *Font) encode(text string) string {
+	if f.cid {
+		var hex strings.Builder
+		hex.WriteString("<")
+		for _, r := range text {
+			if r > 0xFFFF {
+				// UniJIS-UCS2 CMaps can't map characters outside the BMP
+				r = '?'
+			}
+			for _, u := range utf16.Encode([]rune{r}) {
+				hex.WriteString(fmt.Sprintf("%04X", u))
+			}
+		}
+		hex.WriteString(">")
+		return hex.String()
+	}
+
+	replacer := strings.NewReplacer(`\`, `\\`, `(`, `\(`, `)`, `\)`, "\r", `\r`)
+	return "(" + replacer.Replace(text) + ")"
+}
+
+// Width estimates the width of the text. It is exact for Gothic, and an approximation of the average
+// glyph width for Helvetica.
+func (f *Font) Width(text string, size float64) float64 {
+	var units float64
+	for _, r := range text {
+		switch {
+		case f.cid && r < 0x80:
+			units += 500
+		case f.cid:
+			units += 1000
+		default:
+			units += 556
+		}
+	}
+	return units * size / 1000
+}
+
+// -- font
+
+type type1Font struct {
+	baseFont string
+}
+
+func (f *type1Font) write(w io.Writer, _ int) error {
+	var content strings.Builder
+
+	content.WriteString("<<\n")
+	content.WriteString("\t/Type /Font\n")
+	content.WriteString("\t/Subtype /Type1\n")
+	content.WriteString(fmt.Sprintf("\t/BaseFont /%s\n", f.baseFont))
+	content.WriteString(">>\n")
+
+	if _, err := io.WriteString(w, content.String()); err != nil {
+		return err
+	}
+	return nil
+}
+
+// -- composite font
+
+type type0Font struct {
+	baseFont       string
+	descendantFont string
+}
+
+func (f *type0Font) write(w io.Writer, _ int) error {
+	var content strings.Builder
+
+	content.WriteString("<<\n")
+	content.WriteString("\t/Type /Font\n")
+	content.WriteString("\t/Subtype /Type0\n")
+	content.WriteString(fmt.Sprintf("\t/BaseFont /%s\n", f.baseFont))
+	// half width glyphs for ASCII characters
+	content.WriteString("\t/Encoding /UniJIS-UCS2-HW-H\n")
+	content.WriteString(fmt.Sprintf("\t/DescendantFonts [ %s ]\n", f.descendantFont))
+	content.WriteString(">>\n")
+
+	if _, err := io.WriteString(w, content.String()); err != nil {
+		return err
+	}
+	return nil
+}
+
+type cidFont struct {
+	baseFont       string
+	fontDescriptor string
+}
+
+func (f *cidFont) write(w io.Writer, _ int) error {
+	var content strings.Builder
+
+	content.WriteString("<<\n")
+	content.WriteString("\t/Type /Font\n")
+	content.WriteString("\t/Subtype /CIDFontType0\n")
+	content.WriteString(fmt.Sprintf("\t/BaseFont /%s\n", f.baseFont))
+	content.WriteString("\t/CIDSystemInfo << /Registry (Adobe) /Ordering (Japan1) /Supplement 2 >>\n")
+	content.WriteString(fmt.Sprintf("\t/FontDescriptor %s\n", f.fontDescriptor))
+	content.WriteString("\t/DW 1000\n")
+	// half width ASCII characters (CID 231-325)
+	content.WriteString("\t/W [ 231 325 500 ]\n")
+	content.WriteString(">>\n")
+
+	if _, err := io.WriteString(w, content.String()); err != nil {
+		return err
+	}
+	return nil
+}
+
+type fontDescriptor struct {
+	fontName string
+}
+
+func (f *fontDescriptor) write(w io.Writer, _ int) error {
+	var content strings.Builder
+
+	content.WriteString("<<\n")
+	content.WriteString("\t/Type /FontDescriptor\n")
+	content.WriteString(fmt.Sprintf("\t/FontName /%s\n", f.fontName))
+	content.WriteString("\t/Flags 4\n")
+	content.WriteString("\t/FontBBox [ -92 -250 1010 922 ]\n")
+	content.WriteString("\t/ItalicAngle 0\n")
+	content.WriteString("\t/Ascent 880\n")
+	content.WriteString("\t/Descent -120\n")
+	content.WriteString("\t/CapHeight 737\n")
+	content.WriteString("\t/StemV 93\n")
+	content.WriteString(">>\n")
+
+	if _, err := io.WriteString(w, content.String()); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,3 @@
+module github.com/isucon/isucon11-final/webapp/go/pdf
+
+go 1.17
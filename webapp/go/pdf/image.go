@@ -0,0 +1,86 @@
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg"
+	"io"
+	"strings"
+)
+
+// Image is a JPEG image which can be placed on pages.
+type Image struct {
+	colorModel color.Model
+	width      int
+	height     int
+	data       []byte
+}
+
+// NewImage parses the header of JPEG data. The data is embedded into the document as is.
+func NewImage(data []byte) (*Image, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if format != "jpeg" {
+		return nil, fmt.Errorf("unsupported image format: %s", format)
+	}
+	switch config.ColorModel {
+	case color.YCbCrModel, color.GrayModel, color.CMYKModel:
+	default:
+		return nil, errors.New("unsupported color model")
+	}
+
+	return &Image{
+		colorModel: config.ColorModel,
+		width:      config.Width,
+		height:     config.Height,
+		data:       data,
+	}, nil
+}
+
+func (i *Image) write(w io.Writer, _ int) error {
+	var content strings.Builder
+
+	content.WriteString("<<\n")
+
+	content.WriteString("\t/Type /XObject\n")
+	content.WriteString("\t/Subtype /Image\n")
+	content.WriteString(fmt.Sprintf("\t/Width %d\n", i.width))
+	content.WriteString(fmt.Sprintf("\t/Height %d\n", i.height))
+
+	var colorSpace string
+	switch i.colorModel {
+	case color.YCbCrModel:
+		colorSpace = "DeviceRGB"
+	case color.GrayModel:
+		colorSpace = "DeviceGray"
+	case color.CMYKModel:
+		colorSpace = "DeviceCMYK"
+	}
+	content.WriteString(fmt.Sprintf("\t/ColorSpace /%s\n", colorSpace))
+	if colorSpace == "DeviceCMYK" {
+		content.WriteString("\t/Decode [1 0 1 0 1 0 1 0]\n")
+	}
+
+	// jpeg specific
+	content.WriteString(fmt.Sprintf("\t/BitsPerComponent %d\n", 8))
+	content.WriteString(fmt.Sprintf("\t/Filter /%s\n", "DCTDecode"))
+
+	content.WriteString(fmt.Sprintf("\t/Length %d\n", len(i.data)))
+
+	content.WriteString(">>\n")
+
+	// image data stream
+	content.WriteString("stream\n")
+	content.Write(i.data)
+	content.WriteString("\nendstream\n")
+
+	if _, err := io.WriteString(w, content.String()); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,419 @@
+// Package pdf generates PDF1.7(ISO 32000-1) compatible PDF data without any external dependencies.
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Document is a PDF document whose pages all have the same size.
+// Coordinates are in points and the origin is the bottom left corner of the page.
+type Document struct {
+	Width  float64
+	Height float64
+	// Margin is used for the page breaks of tables
+	Margin float64
+
+	pages    []*Page
+	fonts    []*Font
+	fontIDs  map[*Font]string
+	images   []*Image
+	imageIDs map[*Image]string
+}
+
+func New(width, height float64) *Document {
+	return &Document{
+		Width:    width,
+		Height:   height,
+		Margin:   height / 20,
+		fontIDs:  make(map[*Font]string),
+		imageIDs: make(map[*Image]string),
+	}
+}
+
+// Page is a page of a Document. Drawing operations are appended to its content stream.
+type Page struct {
+	doc      *Document
+	contents strings.Builder
+}
+
+// AddPage appends a new blank page to the document.
+func (d *Document) AddPage() *Page {
+	p := &Page{doc: d}
+	d.pages = append(d.pages, p)
+	return p
+}
+
+// Pages returns the pages in the order they appear in the document.
+func (d *Document) Pages() []*Page {
+	return d.pages
+}
+
+func (d *Document) fontID(f *Font) string {
+	if id, ok := d.fontIDs[f]; ok {
+		return id
+	}
+	id := fmt.Sprintf("F%d", len(d.fonts)+1)
+	d.fonts = append(d.fonts, f)
+	d.fontIDs[f] = id
+	return id
+}
+
+func (d *Document) imageID(img *Image) string {
+	if id, ok := d.imageIDs[img]; ok {
+		return id
+	}
+	id := fmt.Sprintf("I%d", len(d.images)+1)
+	d.images = append(d.images, img)
+	d.imageIDs[img] = id
+	return id
+}
+
+// Text draws the text with its baseline starting at (x, y). Each "\n" starts a new line.
+func (p *Page) Text(font *Font, size float64, x float64, y float64, text string) {
+	fontID := p.doc.fontID(font)
+
+	p.contents.WriteString("\tBT\n")
+	p.contents.WriteString(fmt.Sprintf("\t\t%0.2f %0.2f Td\n", x, y))
+	p.contents.WriteString(fmt.Sprintf("\t\t/%s %0.2f Tf\n", fontID, size))
+	p.contents.WriteString(fmt.Sprintf("\t\t%0.2f TL\n", size*1.1))
+	for _, line := range strings.Split(text, "\n") {
+		p.contents.WriteString(fmt.Sprintf("\t\t%s Tj T*\n", font.encode(line)))
+	}
+	p.contents.WriteString("\tET\n")
+}
+
+// Image draws the image with its bottom left corner at (x, y), scaled to mag x mag points.
+func (p *Page) Image(img *Image, x float64, y float64, mag float64) {
+	imageID := p.doc.imageID(img)
+
+	p.contents.WriteString("\tq\n")
+	p.contents.WriteString(fmt.Sprintf("\t%0.2f 0 0 %0.2f %0.2f %0.2f cm\n", mag, mag, x, y))
+	p.contents.WriteString(fmt.Sprintf("\t/%s Do\n", imageID))
+	p.contents.WriteString("\tQ\n")
+}
+
+// Line draws a straight line from (x1, y1) to (x2, y2).
+func (p *Page) Line(x1 float64, y1 float64, x2 float64, y2 float64, width float64) {
+	p.contents.WriteString("\tq\n")
+	p.contents.WriteString(fmt.Sprintf("\t%0.2f w\n", width))
+	p.contents.WriteString(fmt.Sprintf("\t%0.2f %0.2f m %0.2f %0.2f l S\n", x1, y1, x2, y2))
+	p.contents.WriteString("\tQ\n")
+}
+
+// Bytes returns the whole document.
+func (d *Document) Bytes() []byte {
+	var buf bytes.Buffer
+	if err := d.Write(&buf); err != nil {
+		// bytes.Buffer never fails
+		panic(err)
+	}
+	return buf.Bytes()
+}
+
+// Write writes the whole document to w.
+func (d *Document) Write(w io.Writer) error {
+	cw := &countingWriter{w: w}
+
+	if err := header(cw); err != nil {
+		return err
+	}
+
+	var objs []obj
+	add := func(o obj) string {
+		objs = append(objs, o)
+		return fmt.Sprintf("%d 0 R", len(objs))
+	}
+
+	root := add(&catalog{})
+	pagesRef := add(&pages{
+		pageCount:  len(d.pages),
+		pageWidth:  d.Width,
+		pageHeight: d.Height,
+	})
+	objs[0].(*catalog).pages = pagesRef
+
+	res := &resources{
+		fonts:    make(map[string]string, len(d.fonts)),
+		xObjects: make(map[string]string, len(d.images)),
+	}
+	for _, f := range d.fonts {
+		if f.cid {
+			descriptor := add(&fontDescriptor{fontName: f.baseFont})
+			descendant := add(&cidFont{baseFont: f.baseFont, fontDescriptor: descriptor})
+			res.fonts[d.fontIDs[f]] = add(&type0Font{baseFont: f.baseFont, descendantFont: descendant})
+		} else {
+			res.fonts[d.fontIDs[f]] = add(&type1Font{baseFont: f.baseFont})
+		}
+	}
+	for _, img := range d.images {
+		res.xObjects[d.imageIDs[img]] = add(img)
+	}
+	resourcesRef := add(res)
+
+	kids := make([]string, 0, len(d.pages))
+	for _, p := range d.pages {
+		pg := &page{
+			parent:    pagesRef,
+			resources: resourcesRef,
+		}
+		kids = append(kids, add(pg))
+		pg.contents = add(&stream{data: p.contents.String()})
+	}
+	objs[1].(*pages).kids = strings.Join(kids, " ")
+
+	linelens, err := body(cw, objs)
+	if err != nil {
+		return err
+	}
+	objNum := len(objs)
+
+	xrefOffset := cw.offset
+	if err := xref(cw, objNum, linelens); err != nil {
+		return err
+	}
+
+	if err := trailer(cw, root, objNum, xrefOffset); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+type countingWriter struct {
+	w      io.Writer
+	offset int
+}
+
+func (cw *countingWriter) Write(b []byte) (int, error) {
+	n, err := cw.w.Write(b)
+	cw.offset += n
+	return n, err
+}
+
+type obj interface {
+	write(w io.Writer, objID int) error
+}
+
+// - components
+
+// -- header
+func header(w *countingWriter) error {
+	if _, err := fmt.Fprint(w, "%PDF-1.7\n\n"); err != nil {
+		return err
+	}
+	return nil
+}
+
+// -- body
+func body(w *countingWriter, objs []obj) ([]int, error) {
+	linelens := make([]int, len(objs))
+
+	for i := range objs {
+		objID := i + 1 // 1-indexed
+		linelens[i] = w.offset
+		if _, err := fmt.Fprintf(w, "%d 0 obj\n", objID); err != nil {
+			return nil, err
+		}
+		if err := objs[i].write(w, objID); err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(w, "endobj\n\n"); err != nil {
+			return nil, err
+		}
+	}
+
+	return linelens, nil
+}
+
+// -- xref
+func xref(w *countingWriter, objNum int, linelens []int) error {
+	var content strings.Builder
+
+	content.WriteString("xref\n")
+	content.WriteString(fmt.Sprintf("0 %d\n", objNum+1))
+	content.WriteString("0000000000 65535 f \n")
+
+	for i := range linelens {
+		linelen := linelens[i]
+		content.WriteString(fmt.Sprintf("%s 00000 n \n", formatXrefLinelen(linelen)))
+	}
+
+	if _, err := io.WriteString(w, content.String()); err != nil {
+		return err
+	}
+	return nil
+}
+
+func formatXrefLinelen(n int) string {
+	str := strconv.Itoa(n)
+	for len(str) < 10 {
+		str = "0" + str
+	}
+	return str
+}
+
+// -- trailer
+func trailer(w *countingWriter, rootRef string, objNum int, xrefOffset int) error {
+	var content strings.Builder
+
+	content.WriteString("trailer\n")
+	content.WriteString("<<\n")
+	content.WriteString(fmt.Sprintf("\t/Size %d\n", objNum+1))
+	content.WriteString(fmt.Sprintf("\t/Root %s\n", rootRef))
+	content.WriteString(">>\n")
+
+	content.WriteString("startxref\n")
+	content.WriteString(fmt.Sprintf("%d", xrefOffset))
+	content.WriteString("\n%%EOF\n")
+
+	if _, err := io.WriteString(w, content.String()); err != nil {
+		return err
+	}
+	return nil
+}
+
+// - obj implementations
+
+// -- catalog
+
+type catalog struct {
+	pages string
+}
+
+func (c *catalog) write(w io.Writer, _ int) error {
+	var content strings.Builder
+
+	content.WriteString("<<\n")
+	content.WriteString("\t/Type /Catalog\n")
+	content.WriteString(fmt.Sprintf("\t/Pages %s\n", c.pages))
+	content.WriteString(">>\n")
+
+	if _, err := io.WriteString(w, content.String()); err != nil {
+		return err
+	}
+	return nil
+}
+
+// -- pages
+
+type pages struct {
+	pageCount  int
+	kids       string
+	pageWidth  float64
+	pageHeight float64
+}
+
+func (p *pages) write(w io.Writer, _ int) error {
+	var content strings.Builder
+
+	content.WriteString("<<\n")
+
+	content.WriteString("\t/Type /Pages\n")
+	content.WriteString(fmt.Sprintf("\t/MediaBox [ 0 0 %0.2f %0.2f ]\n", p.pageWidth, p.pageHeight))
+	content.WriteString(fmt.Sprintf("\t/Count %d\n", p.pageCount))
+	content.WriteString(fmt.Sprintf("\t/Kids [ %s ]\n", p.kids))
+
+	content.WriteString(">>\n")
+
+	if _, err := io.WriteString(w, content.String()); err != nil {
+		return err
+	}
+	return nil
+}
+
+// -- page
+
+type page struct {
+	parent    string
+	resources string
+	contents  string
+}
+
+func (p *page) write(w io.Writer, _ int) error {
+	var content strings.Builder
+	content.WriteString("<<\n")
+
+	content.WriteString("\t/Type /Page\n")
+	content.WriteString(fmt.Sprintf("\t/Parent %s\n", p.parent))
+	content.WriteString(fmt.Sprintf("\t/Resources %s\n", p.resources))
+	if p.contents != "" {
+		content.WriteString(fmt.Sprintf("\t/Contents %s\n", p.contents))
+	}
+
+	content.WriteString(">>\n")
+
+	if _, err := io.WriteString(w, content.String()); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// -- resources
+
+type resources struct {
+	fonts    map[string]string
+	xObjects map[string]string
+}
+
+func (r *resources) write(w io.Writer, _ int) error {
+	var content strings.Builder
+
+	content.WriteString("<<\n")
+
+	content.WriteString("\t/Font <<\n")
+	for _, name := range sortedKeys(r.fonts) {
+		content.WriteString(fmt.Sprintf("\t\t/%s %s\n", name, r.fonts[name]))
+	}
+	content.WriteString("\t>>\n")
+
+	content.WriteString("\t/XObject <<\n")
+	for _, name := range sortedKeys(r.xObjects) {
+		content.WriteString(fmt.Sprintf("\t\t/%s %s\n", name, r.xObjects[name]))
+	}
+	content.WriteString("\t>>\n")
+
+	content.WriteString(">>\n")
+
+	if _, err := io.WriteString(w, content.String()); err != nil {
+		return err
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// -- contents
+
+type stream struct {
+	data string
+}
+
+func (s *stream) write(w io.Writer, _ int) error {
+	var content strings.Builder
+
+	content.WriteString("<<\n")
+	content.WriteString(fmt.Sprintf("\t/Length %d\n", len(s.data)))
+	content.WriteString(">>\n")
+
+	content.WriteString("stream\n")
+	content.WriteString(s.data)
+	content.WriteString("endstream\n")
+
+	if _, err := io.WriteString(w, content.String()); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func testImage(t *testing.T) *Image {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 8, 8)), nil); err != nil {
+		t.Fatal(err)
+	}
+	img, err := NewImage(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+// verifyXref checks that every xref entry and startxref point to the right offsets.
+func verifyXref(t *testing.T, data []byte) {
+	t.Helper()
+
+	if !bytes.HasPrefix(data, []byte("%PDF-1.7\n")) {
+		t.Fatalf("missing header")
+	}
+
+	m := regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`).FindSubmatch(data)
+	if m == nil {
+		t.Fatalf("missing startxref")
+	}
+	xrefOffset, _ := strconv.Atoi(string(m[1]))
+	if !bytes.HasPrefix(data[xrefOffset:], []byte("xref\n")) {
+		t.Fatalf("startxref %d does not point to xref", xrefOffset)
+	}
+
+	lines := strings.Split(string(data[xrefOffset:]), "\n")
+	var objNum int
+	if _, err := fmt.Sscanf(lines[1], "0 %d", &objNum); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < objNum; i++ {
+		offset, _ := strconv.Atoi(lines[2+i][:10])
+		if want := fmt.Sprintf("%d 0 obj\n", i); !bytes.HasPrefix(data[offset:], []byte(want)) {
+			t.Errorf("xref entry of object %d points to %q", i, data[offset:offset+len(want)])
+		}
+	}
+}
+
+func TestDocument(t *testing.T) {
+	doc := New(1600, 1000)
+	p := doc.AddPage()
+	p.Text(Helvetica, 64, 128, 900, "this is a sample pdf.\nnext line.")
+	p = doc.AddPage()
+	p.Image(testImage(t), 100, 100, 700)
+	p.Text(Gothic, 32, 100, 900, "日本語")
+
+	data := doc.Bytes()
+	verifyXref(t, data)
+
+	if !bytes.Contains(data, []byte("/Count 2\n")) {
+		t.Errorf("page count is not 2")
+	}
+	for _, want := range []string{"/BaseFont /Helvetica\n", "/BaseFont /HeiseiKakuGo-W5\n", "/Subtype /Image\n", "<65E5672C8A9E> Tj"} {
+		if !bytes.Contains(data, []byte(want)) {
+			t.Errorf("missing %q", want)
+		}
+	}
+}
+
+func TestTablePageBreak(t *testing.T) {
+	doc := New(595, 842)
+	table := &Table{
+		Columns: []Column{
+			{Header: "Code", Width: 100},
+			{Header: "Name", Width: 60},
+		},
+		Font:     Gothic,
+		FontSize: 10,
+	}
+	for i := 0; i < 100; i++ {
+		table.Rows = append(table.Rows, []string{fmt.Sprintf("X%04d", i), "とても長い科目名の科目"})
+	}
+
+	p, y := table.Draw(doc.AddPage(), 50, 800)
+	if len(doc.Pages()) != 3 {
+		t.Fatalf("page count: want 3, got %d", len(doc.Pages()))
+	}
+	if p != doc.Pages()[2] {
+		t.Errorf("the table does not end on the last page")
+	}
+	if y < doc.Margin {
+		t.Errorf("the table overflows the bottom margin: %0.2f", y)
+	}
+
+	data := doc.Bytes()
+	verifyXref(t, data)
+
+	// the header is repeated on every page
+	if n := bytes.Count(data, []byte(Gothic.encode("Code")+" Tj")); n != 3 {
+		t.Errorf("header count: want 3, got %d", n)
+	}
+	// cells wider than the column are truncated
+	if bytes.Contains(data, []byte(Gothic.encode("とても長い科目名の科目"))) {
+		t.Errorf("the long cell is not truncated")
+	}
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		font *Font
+		text string
+		want string
+	}{
+		{Helvetica, `a(b)\c`, `(a\(b\)\\c)`},
+		{Gothic, "A成績", "<004162107E3E>"},
+		{Gothic, "😀", "<003F>"},
+	}
+	for _, tt := range tests {
+		if got := tt.font.encode(tt.text); got != tt.want {
+			t.Errorf("encode(%q): want %s, got %s", tt.text, tt.want, got)
+		}
+	}
+}
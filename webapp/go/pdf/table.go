@@ -0,0 +1,90 @@
+package pdf
+
+const cellPadding = 4
+
+type Column struct {
+	Header string
+	Width  float64
+}
+
+// Table is a simple grid of text cells. Cells wider than their column are truncated.
+type Table struct {
+	Columns []Column
+	Rows    [][]string
+	Font    *Font
+	// HeaderFont defaults to Font
+	HeaderFont *Font
+	FontSize   float64
+	// RowHeight defaults to 1.8 times FontSize
+	RowHeight float64
+}
+
+// Draw draws the table on p with its top left corner at (x, y).
+// Rows which don't fit above the bottom margin continue on new pages, starting below the top margin with
+// the header repeated. It returns the page on which the table ends and the y coordinate of its bottom.
+func (t *Table) Draw(p *Page, x float64, y float64) (*Page, float64) {
+	doc := p.doc
+	rowHeight := t.RowHeight
+	if rowHeight == 0 {
+		rowHeight = t.FontSize * 1.8
+	}
+	headerFont := t.HeaderFont
+	if headerFont == nil {
+		headerFont = t.Font
+	}
+	width := 0.0
+	for _, column := range t.Columns {
+		width += column.Width
+	}
+
+	drawRow := func(font *Font, cells []string) {
+		baseline := y - (rowHeight+t.FontSize*0.7)/2
+		cellX := x
+		for i, column := range t.Columns {
+			if i < len(cells) {
+				p.Text(font, t.FontSize, cellX+cellPadding, baseline, truncate(font, t.FontSize, cells[i], column.Width-cellPadding*2))
+			}
+			cellX += column.Width
+		}
+		y -= rowHeight
+	}
+	drawHeader := func() {
+		p.Line(x, y, x+width, y, 1)
+		headers := make([]string, 0, len(t.Columns))
+		for _, column := range t.Columns {
+			headers = append(headers, column.Header)
+		}
+		drawRow(headerFont, headers)
+		p.Line(x, y, x+width, y, 0.5)
+	}
+
+	drawHeader()
+	for _, row := range t.Rows {
+		if y-rowHeight < doc.Margin {
+			p.Line(x, y, x+width, y, 1)
+			p = doc.AddPage()
+			y = doc.Height - doc.Margin
+			drawHeader()
+		}
+		drawRow(t.Font, row)
+	}
+	p.Line(x, y, x+width, y, 1)
+
+	return p, y
+}
+
+// truncate cuts off the end of the text so that it fits in the width.
+func truncate(font *Font, size float64, text string, width float64) string {
+	if font.Width(text, size) <= width {
+		return text
+	}
+	const ellipsis = "..."
+	runes := []rune(text)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		if s := string(runes) + ellipsis; font.Width(s, size) <= width {
+			return s
+		}
+	}
+	return ""
+}
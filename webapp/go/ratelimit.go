@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const defaultRateLimitSweepInterval = time.Minute
+
+// RateLimiter 失敗した試行の回数に応じてキーをロックアウトする制限器
+// 複数台で状態を共有する場合は外部ストアを使う実装に差し替える
+type RateLimiter interface {
+	// Check キーがロックアウト中であれば解除までの時間を返す。ロックアウト中でなければ0を返す
+	Check(key string) (time.Duration, error)
+	// Fail 失敗した試行を記録する
+	Fail(key string) error
+	// Reset キーの失敗の記録を消去する
+	Reset(key string) error
+}
+
+type TokenBucketConfig struct {
+	// Capacity ロックアウトされるまでに許容する連続した失敗の回数
+	Capacity float64
+	// RefillInterval 失敗1回分が回復するまでの時間
+	RefillInterval time.Duration
+	// BaseLockout 初回のロックアウト時間。以降ロックアウトされる度に倍になる
+	BaseLockout time.Duration
+	// MaxLockout ロックアウト時間の上限
+	MaxLockout time.Duration
+}
+
+type tokenBucket struct {
+	tokens      float64
+	updatedAt   time.Time
+	lockouts    int
+	lockedUntil time.Time
+}
+
+// tokenBucketLimiter 失敗の度にトークンを消費し、トークンが尽きるとロックアウトするプロセス内の制限器
+type tokenBucketLimiter struct {
+	config  TokenBucketConfig
+	buckets map[string]*tokenBucket
+	mu      sync.Mutex
+}
+
+func NewTokenBucketLimiter(config TokenBucketConfig) *tokenBucketLimiter {
+	return &tokenBucketLimiter{
+		config:  config,
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// refill 経過時間分のトークンを回復する
+// 満タンかつロックアウト中でないバケットは保持する必要がないので破棄してnilを返す
+func (l *tokenBucketLimiter) refill(key string, now time.Time) *tokenBucket {
+	b, ok := l.buckets[key]
+	if !ok {
+		return nil
+	}
+	elapsed := now.Sub(b.updatedAt)
+	b.tokens = math.Min(l.config.Capacity, b.tokens+float64(elapsed)/float64(l.config.RefillInterval))
+	b.updatedAt = now
+	if b.tokens >= l.config.Capacity && !now.Before(b.lockedUntil) {
+		delete(l.buckets, key)
+		return nil
+	}
+	return b
+}
+
+func (l *tokenBucketLimiter) Check(key string) (time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	b := l.refill(key, now)
+	if b == nil || !now.Before(b.lockedUntil) {
+		return 0, nil
+	}
+	return b.lockedUntil.Sub(now), nil
+}
+
+func (l *tokenBucketLimiter) Fail(key string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	b := l.refill(key, now)
+	if b == nil {
+		b = &tokenBucket{
+			tokens:    l.config.Capacity,
+			updatedAt: now,
+		}
+		l.buckets[key] = b
+	}
+
+	b.tokens--
+	if b.tokens < 1 {
+		lockout := l.config.BaseLockout << b.lockouts
+		if lockout <= 0 || lockout > l.config.MaxLockout {
+			lockout = l.config.MaxLockout
+		}
+		b.lockouts++
+		b.lockedUntil = now.Add(lockout)
+	}
+
+	return nil
+}
+
+// sweep 満タンかつロックアウト中でないバケットをすべて破棄する
+// 一度しか失敗していないキーのバケットが残り続けないよう定期的に呼ぶ
+func (l *tokenBucketLimiter) sweep(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for key := range l.buckets {
+		l.refill(key, now)
+	}
+}
+
+// Run ctx が終了するまで interval ごとに不要なバケットを破棄する
+func (l *tokenBucketLimiter) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			l.sweep(now)
+		}
+	}
+}
+
+func (l *tokenBucketLimiter) Reset(key string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.buckets, key)
+	return nil
+}
+
+// retryAfterSeconds Retry-After ヘッダに設定する秒数
+func retryAfterSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// NewIPExtractor クライアントのIPアドレスを取り出す IPExtractor を返す
+// X-Forwarded-For はループバックと trustedProxies(カンマ区切りのCIDR)からの接続の場合のみ信頼する
+// それ以外の接続では X-Forwarded-For を無視し、接続元のIPアドレスを使う
+func NewIPExtractor(trustedProxies string) (echo.IPExtractor, error) {
+	options := []echo.TrustOption{
+		echo.TrustLoopback(true),
+		echo.TrustLinkLocal(false),
+		echo.TrustPrivateNet(false),
+	}
+	for _, cidr := range strings.Split(trustedProxies, ",") {
+		if cidr = strings.TrimSpace(cidr); cidr == "" {
+			continue
+		}
+		_, ipRange, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TRUSTED_PROXIES: %w", err)
+		}
+		options = append(options, echo.TrustIPRange(ipRange))
+	}
+	return echo.ExtractIPFromXFFHeader(options...), nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"github.com/jmoiron/sqlx"
+)
+
+// 履修要件
+//
+// prerequisite: 履修登録の時点で、指定の科目を修了して単位を修得している必要がある
+// corequisite: 指定の科目を修了して単位を修得しているか、同時に履修している(同じリクエストで履修登録する場合を含む)必要がある
+
+type RequisiteType string
+
+const (
+	Prerequisite RequisiteType = "prerequisite"
+	Corequisite  RequisiteType = "corequisite"
+)
+
+type CourseRequisite struct {
+	CourseID         string        `db:"course_id"`
+	RequiredCourseID string        `db:"required_course_id"`
+	Type             RequisiteType `db:"type"`
+}
+
+// findRequisiteCycle 科目 courseID の履修要件を requisites に置き換えたときに、履修要件が循環する場合はその循環の要件となる科目のIDを返す
+// 先修要件・同時履修要件の種類を問わず、すべての循環を拒否する
+// 履修要件の定義を直列化するため、すべての履修要件を FOR UPDATE で読む
+func findRequisiteCycle(tx *sqlx.Tx, courseID string, requisites []CourseRequisite) (string, error) {
+	var all []CourseRequisite
+	if err := tx.Select(&all, "SELECT * FROM `course_requisites` FOR UPDATE"); err != nil {
+		return "", err
+	}
+
+	graph := make(map[string][]string)
+	for _, r := range all {
+		if r.CourseID != courseID {
+			graph[r.CourseID] = append(graph[r.CourseID], r.RequiredCourseID)
+		}
+	}
+
+	// 要件の科目から courseID に戻る経路を探索する
+	for _, start := range requisites {
+		visited := make(map[string]bool)
+		stack := []string{start.RequiredCourseID}
+		for len(stack) > 0 {
+			id := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if id == courseID {
+				return start.RequiredCourseID, nil
+			}
+			if visited[id] {
+				continue
+			}
+			visited[id] = true
+			stack = append(stack, graph[id]...)
+		}
+	}
+
+	return "", nil
+}
+
+// checkRequisites 新たに履修登録する科目のうち、先修要件または同時履修要件を満たさない科目のIDを返す
+// registered には履修中の科目と新たに履修登録する科目を渡す
+func checkRequisites(tx *sqlx.Tx, userID string, newlyAdded []Course, registered []Course) (prerequisiteNotMet []string, corequisiteNotMet []string, err error) {
+	if len(newlyAdded) == 0 {
+		return nil, nil, nil
+	}
+
+	courseIDs := make([]string, 0, len(newlyAdded))
+	for _, course := range newlyAdded {
+		courseIDs = append(courseIDs, course.ID)
+	}
+	query, args, err := sqlx.In("SELECT * FROM `course_requisites` WHERE `course_id` IN (?)", courseIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	var requisites []CourseRequisite
+	if err := tx.Select(&requisites, query, args...); err != nil {
+		return nil, nil, err
+	}
+	if len(requisites) == 0 {
+		return nil, nil, nil
+	}
+
+	// 要件の科目のうち、修了して単位を修得した科目
+	requiredCourseIDs := make([]string, 0, len(requisites))
+	for _, r := range requisites {
+		requiredCourseIDs = append(requiredCourseIDs, r.RequiredCourseID)
+	}
+	var totalScores []struct {
+		CourseID   string `db:"course_id"`
+		TotalScore int    `db:"total_score"`
+	}
+	query, args, err = sqlx.In("SELECT `course_total_scores`.`course_id`, `course_total_scores`.`total_score`"+
+		" FROM `course_total_scores`"+
+		" JOIN `courses` ON `course_total_scores`.`course_id` = `courses`.`id`"+
+		" WHERE `course_total_scores`.`user_id` = ? AND `courses`.`status` = ? AND `course_total_scores`.`course_id` IN (?)",
+		userID, StatusClosed, requiredCourseIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Select(&totalScores, query, args...); err != nil {
+		return nil, nil, err
+	}
+	closedCourseIDs := make([]string, 0, len(totalScores))
+	for _, s := range totalScores {
+		closedCourseIDs = append(closedCourseIDs, s.CourseID)
+	}
+	policies, err := getGradingPolicies(tx, closedCourseIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	passed := make(map[string]bool, len(totalScores))
+	for _, s := range totalScores {
+		passed[s.CourseID] = policies[s.CourseID].grade(s.TotalScore, 1).Credits > 0
+	}
+
+	isRegistered := make(map[string]bool, len(registered))
+	for _, course := range registered {
+		isRegistered[course.ID] = true
+	}
+
+	prerequisiteFailed := make(map[string]bool)
+	corequisiteFailed := make(map[string]bool)
+	for _, r := range requisites {
+		if passed[r.RequiredCourseID] {
+			continue
+		}
+		switch r.Type {
+		case Prerequisite:
+			prerequisiteFailed[r.CourseID] = true
+		case Corequisite:
+			if !isRegistered[r.RequiredCourseID] {
+				corequisiteFailed[r.CourseID] = true
+			}
+		}
+	}
+
+	for _, course := range newlyAdded {
+		if prerequisiteFailed[course.ID] {
+			prerequisiteNotMet = append(prerequisiteNotMet, course.ID)
+		}
+		if corequisiteFailed[course.ID] {
+			corequisiteNotMet = append(corequisiteNotMet, course.ID)
+		}
+	}
+
+	return prerequisiteNotMet, corequisiteNotMet, nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 科目の全文検索
+//
+// courses(name, keywords) の ngram 全文検索インデックスを使い、以下の構文の検索語を MySQL の BOOLEAN MODE の式に変換する
+//   - 空白区切りの語はすべてを含む科目にマッチする (AND)
+//   - "..." で囲んだ語は空白を含めて一つの語として扱う (フレーズ検索)
+//   - 語の間の OR はどちらかを含む科目にマッチする
+//   - NOT または先頭の - をつけた語は含まない科目にマッチする
+
+// minFulltextTermLength ngram_token_size (既定値2) より短い語はインデックスから検索できないため無視する
+const minFulltextTermLength = 2
+
+// relevanceScorePrecision 関連度を丸める小数点以下の桁数
+const relevanceScorePrecision = 6
+
+type fulltextQuery struct {
+	// musts それぞれのグループの語のいずれかを含む
+	musts [][]string
+	// nots いずれの語も含まない
+	nots []string
+}
+
+func parseFulltextQuery(s string) fulltextQuery {
+	var q fulltextQuery
+	negate := false
+	or := false
+	lastNegated := false
+
+	addTerm := func(term string, quoted bool) {
+		if term == "" {
+			return
+		}
+		if !quoted {
+			switch term {
+			case "OR":
+				or = true
+				return
+			case "NOT":
+				negate = true
+				return
+			}
+			if strings.HasPrefix(term, "-") {
+				negate = true
+				term = term[1:]
+			}
+		}
+		// 無効な検索条件はエラーを返さず無視して良い
+		if utf8.RuneCountInString(term) < minFulltextTermLength {
+			negate, or = false, false
+			return
+		}
+
+		switch {
+		case negate:
+			q.nots = append(q.nots, term)
+			lastNegated = true
+		case or && len(q.musts) > 0 && !lastNegated:
+			q.musts[len(q.musts)-1] = append(q.musts[len(q.musts)-1], term)
+		default:
+			q.musts = append(q.musts, []string{term})
+			lastNegated = false
+		}
+		negate, or = false, false
+	}
+
+	var term strings.Builder
+	inQuote := false
+	for _, r := range s {
+		switch {
+		case r == '"' && inQuote:
+			addTerm(term.String(), true)
+			term.Reset()
+			inQuote = false
+		case r == '"' && (term.Len() == 0 || term.String() == "-"):
+			if term.Len() > 0 {
+				negate = true
+			}
+			term.Reset()
+			inQuote = true
+		case r == '"':
+			// 語の途中の " は区切りとして扱わず捨てる
+		case unicode.IsSpace(r) && !inQuote:
+			addTerm(term.String(), false)
+			term.Reset()
+		default:
+			term.WriteRune(r)
+		}
+	}
+	addTerm(term.String(), inQuote)
+
+	return q
+}
+
+// booleanPhrase 語を BOOLEAN MODE のフレーズにする。ngram パーサではフレーズは部分文字列としてマッチする
+func booleanPhrase(term string) string {
+	return `"` + term + `"`
+}
+
+// buildFulltextSearch 検索語から科目を絞り込む条件と、関連度の高い順に並べるための式を返す
+// 条件がない場合は空文字を返す。関連度の式は含むべき語がない場合は空文字を返す
+func buildFulltextSearch(keywords string) (condition string, args []interface{}, score string, scoreArgs []interface{}) {
+	q := parseFulltextQuery(keywords)
+
+	if len(q.musts) == 0 {
+		if len(q.nots) == 0 {
+			return "", nil, "", nil
+		}
+		// 除外する語のみの BOOLEAN MODE の式は何にもマッチしないため、いずれかを含むものを除外する
+		phrases := make([]string, 0, len(q.nots))
+		for _, term := range q.nots {
+			phrases = append(phrases, booleanPhrase(term))
+		}
+		return " AND NOT MATCH(`courses`.`name`, `courses`.`keywords`) AGAINST(? IN BOOLEAN MODE)", []interface{}{strings.Join(phrases, " ")}, "", nil
+	}
+
+	exprs := make([]string, 0, len(q.musts)+len(q.nots))
+	for _, group := range q.musts {
+		if len(group) == 1 {
+			exprs = append(exprs, "+"+booleanPhrase(group[0]))
+			continue
+		}
+		phrases := make([]string, 0, len(group))
+		for _, term := range group {
+			phrases = append(phrases, booleanPhrase(term))
+		}
+		exprs = append(exprs, "+("+strings.Join(phrases, " ")+")")
+	}
+	for _, term := range q.nots {
+		exprs = append(exprs, "-"+booleanPhrase(term))
+	}
+	expr := strings.Join(exprs, " ")
+
+	match := "MATCH(`courses`.`name`, `courses`.`keywords`) AGAINST(? IN BOOLEAN MODE)"
+	// 関連度はページングのカーソルに含めて等号で比較するため、丸めて浮動小数点数の誤差で行が飛んだり重複したりしないようにする
+	score = fmt.Sprintf("ROUND(%s, %d)", match, relevanceScorePrecision)
+	return " AND " + match, []interface{}{expr}, score, []interface{}{expr}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 学期
+//
+// すべての科目はいずれかの学期に開講される。学期を指定せずに登録された科目(semester_id が NULL の科目)は現在の学期に開講されるものとして扱う。
+// 学期には学生一人あたりの履修単位数の上限(CAP)を設定でき、
+// 上限はその学期に開講される科目のうち履修登録した科目(修了した科目を含む)の単位数の合計に適用される。
+
+type Semester struct {
+	ID       string `json:"id" db:"id"`
+	Code     string `json:"code" db:"code"`
+	Name     string `json:"name" db:"name"`
+	StartsOn string `json:"starts_on" db:"starts_on"` // YYYY-MM-DD
+	// CreditLimit 履修単位数の上限。null の場合は上限なし
+	CreditLimit *int `json:"credit_limit" db:"credit_limit"`
+}
+
+const semesterColumns = "`id`, `code`, `name`, DATE_FORMAT(`starts_on`, '%Y-%m-%d') AS `starts_on`, `credit_limit`"
+
+// currentSemesterIDQuery 開始日が最も新しい学期を現在の学期とする
+const currentSemesterIDQuery = "SELECT `id` FROM `semesters` ORDER BY `starts_on` DESC, `id` DESC LIMIT 1"
+
+// courseSemesterIDColumn 科目が開講される学期のID。semester_id が NULL の場合は現在の学期
+const courseSemesterIDColumn = "IFNULL(`courses`.`semester_id`, (" + currentSemesterIDQuery + "))"
+
+func getCurrentSemesterID(q sqlx.Queryer) (string, error) {
+	var semesterID string
+	if err := sqlx.Get(q, &semesterID, currentSemesterIDQuery); err != nil {
+		return "", err
+	}
+	return semesterID, nil
+}
+
+// resolveSemesterID 科目の semester_id が NULL の場合は現在の学期のIDを返す
+func resolveSemesterID(q sqlx.Queryer, semesterID sql.NullString) (string, error) {
+	if semesterID.Valid {
+		return semesterID.String, nil
+	}
+	return getCurrentSemesterID(q)
+}
+
+// exceedsCreditLimit 学生が学期に credits 単位を追加で履修すると上限を超える場合は true を返す
+// 同じ学生の履修登録を直列化するため、上限のある学期では学生の行を FOR UPDATE でロックする
+func exceedsCreditLimit(tx *sqlx.Tx, userID string, semesterID string, credits int) (bool, error) {
+	var creditLimit sql.NullInt64
+	if err := tx.Get(&creditLimit, "SELECT `credit_limit` FROM `semesters` WHERE `id` = ?", semesterID); err != nil {
+		return false, err
+	}
+	if !creditLimit.Valid {
+		return false, nil
+	}
+
+	var locked string
+	if err := tx.Get(&locked, "SELECT `id` FROM `users` WHERE `id` = ? FOR UPDATE", userID); err != nil {
+		return false, err
+	}
+
+	var registeredCredits int
+	query := "SELECT IFNULL(SUM(`courses`.`credit`), 0)" +
+		" FROM `registrations`" +
+		" JOIN `courses` ON `registrations`.`course_id` = `courses`.`id`" +
+		" WHERE `registrations`.`user_id` = ? AND " + courseSemesterIDColumn + " = ?"
+	if err := tx.Get(&registeredCredits, query, userID, semesterID); err != nil {
+		return false, err
+	}
+
+	return int64(registeredCredits+credits) > creditLimit.Int64, nil
+}
+
+// checkCreditLimits 新たに履修登録する科目のうち、学期の履修単位数の上限を超える科目のIDを返す
+// 上限を超える学期の科目はすべて返す。newlyAdded に同じ科目は含まれないものとする
+func checkCreditLimits(tx *sqlx.Tx, userID string, newlyAdded []Course) ([]string, error) {
+	var semesterIDs []string
+	courseSemesterIDs := make([]string, len(newlyAdded))
+	creditsBySemester := make(map[string]int)
+	for i, course := range newlyAdded {
+		semesterID, err := resolveSemesterID(tx, course.SemesterID)
+		if err != nil {
+			return nil, err
+		}
+		courseSemesterIDs[i] = semesterID
+		if _, ok := creditsBySemester[semesterID]; !ok {
+			semesterIDs = append(semesterIDs, semesterID)
+		}
+		creditsBySemester[semesterID] += int(course.Credit)
+	}
+
+	exceeded := make(map[string]bool, len(semesterIDs))
+	for _, semesterID := range semesterIDs {
+		ok, err := exceedsCreditLimit(tx, userID, semesterID, creditsBySemester[semesterID])
+		if err != nil {
+			return nil, err
+		}
+		exceeded[semesterID] = ok
+	}
+
+	var res []string
+	for i, course := range newlyAdded {
+		if exceeded[courseSemesterIDs[i]] {
+			res = append(res, course.ID)
+		}
+	}
+	return res, nil
+}
+
+// semesterGPAs 学期に開講された科目のうちGPAの対象となる科目を一つでも修了した学生の、その学期の科目から計算したGPA一覧
+func semesterGPAs(q sqlx.Queryer, semesterID string) ([]float64, error) {
+	var totals []struct {
+		UserID     string `db:"user_id"`
+		CourseID   string `db:"course_id"`
+		Credit     int    `db:"credit"`
+		TotalScore int    `db:"total_score"`
+	}
+	query := "SELECT `course_total_scores`.`user_id`, `courses`.`id` AS `course_id`, `courses`.`credit`, `course_total_scores`.`total_score`" +
+		" FROM `course_total_scores`" +
+		" JOIN `courses` ON `course_total_scores`.`course_id` = `courses`.`id`" +
+		" JOIN `users` ON `course_total_scores`.`user_id` = `users`.`id`" +
+		" WHERE " + courseSemesterIDColumn + " = ? AND `courses`.`status` = ? AND `users`.`type` = ?" +
+		" ORDER BY `course_total_scores`.`user_id`"
+	if err := sqlx.Select(q, &totals, query, semesterID, StatusClosed, Student); err != nil {
+		return nil, err
+	}
+
+	var courseIDs []string
+	seen := make(map[string]bool)
+	for _, total := range totals {
+		if !seen[total.CourseID] {
+			seen[total.CourseID] = true
+			courseIDs = append(courseIDs, total.CourseID)
+		}
+	}
+	policies, err := getGradingPolicies(q, courseIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var gpas []float64
+	for i := 0; i < len(totals); {
+		var weightedGradePoints, gpaCredits int
+		j := i
+		for ; j < len(totals) && totals[j].UserID == totals[i].UserID; j++ {
+			grade := policies[totals[j].CourseID].grade(totals[j].TotalScore, totals[j].Credit)
+			weightedGradePoints += grade.WeightedGradePoints
+			gpaCredits += grade.GPACredits
+		}
+		if gpaCredits > 0 {
+			gpas = append(gpas, float64(weightedGradePoints)/100/float64(gpaCredits))
+		}
+		i = j
+	}
+	return gpas, nil
+}
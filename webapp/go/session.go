@@ -0,0 +1,352 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+)
+
+const maxUserAgentLength = 255
+
+var (
+	errSessionWithoutUser = errors.New("session has no userID")
+	errNoSessionSecret    = errors.New("SESSION_SECRETS is not set")
+)
+
+// SessionRecord サーバ側で保持するセッション
+type SessionRecord struct {
+	ID        string    `db:"id"`
+	UserID    string    `db:"user_id"`
+	Data      []byte    `db:"data"`
+	UserAgent string    `db:"user_agent"`
+	CreatedAt time.Time `db:"created_at"`
+	ExpiresAt time.Time `db:"expires_at"`
+}
+
+// SessionBackend セッションの保存先
+type SessionBackend interface {
+	// Get 有効なセッションを返す。存在しないか期限切れの場合は nil を返す
+	Get(id string) (*SessionRecord, error)
+	Save(record *SessionRecord) error
+	Delete(id string) error
+	DeleteByUserID(userID string) error
+	// ListByUserID ユーザの有効なセッションを作成順に返す
+	ListByUserID(userID string) ([]SessionRecord, error)
+	// DeleteExpired 期限切れのセッションを削除する
+	DeleteExpired() error
+}
+
+const defaultSessionSweepInterval = 10 * time.Minute
+
+// RunSessionSweeper ctx が終了するまで interval ごとに期限切れのセッションを削除する
+func RunSessionSweeper(ctx context.Context, backend SessionBackend, interval time.Duration, logger echo.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := backend.DeleteExpired(); err != nil {
+				logger.Error(err)
+			}
+		}
+	}
+}
+
+// NewSessionBackend SESSION_STORE に対応するセッションの保存先を返す
+func NewSessionBackend(kind string, db *sqlx.DB) (SessionBackend, error) {
+	switch kind {
+	case "mysql":
+		return &mysqlSessionBackend{DB: db}, nil
+	case "memory":
+		return newMemorySessionBackend(), nil
+	default:
+		return nil, fmt.Errorf("unknown session store: %s", kind)
+	}
+}
+
+// loadSessionSecrets SESSION_SECRETS からcookieの署名鍵を読み込む
+// カンマ区切りで先頭が現在の鍵、以降はローテーション前の鍵として検証にのみ使う
+// 複数のサーバで同じ鍵を使う必要があるため、鍵が設定されていない場合はエラーとする
+func loadSessionSecrets() ([][]byte, error) {
+	var secrets [][]byte
+	for _, secret := range strings.Split(GetEnv("SESSION_SECRETS", ""), ",") {
+		if secret = strings.TrimSpace(secret); secret != "" {
+			secrets = append(secrets, []byte(secret))
+		}
+	}
+	if len(secrets) == 0 {
+		return nil, errNoSessionSecret
+	}
+	return secrets, nil
+}
+
+// SessionStore cookieにはセッションIDのみを保持し、値はSessionBackendに保存するsessions.Store
+type SessionStore struct {
+	Backend SessionBackend
+	Codecs  []securecookie.Codec
+	Options *sessions.Options
+}
+
+func NewSessionStore(backend SessionBackend, secrets [][]byte) *SessionStore {
+	keyPairs := make([][]byte, 0, len(secrets)*2)
+	for _, secret := range secrets {
+		keyPairs = append(keyPairs, secret, nil)
+	}
+	return &SessionStore{
+		Backend: backend,
+		Codecs:  securecookie.CodecsFromPairs(keyPairs...),
+		Options: &sessions.Options{
+			Path:   "/",
+			MaxAge: 3600,
+		},
+	}
+}
+
+func (s *SessionStore) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(s, name)
+}
+
+func (s *SessionStore) New(r *http.Request, name string) (*sessions.Session, error) {
+	session := sessions.NewSession(s, name)
+	options := *s.Options
+	session.Options = &options
+	session.IsNew = true
+
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+	// 不正なcookieやローテーションで破棄された鍵で署名されたcookieは未ログインとして扱う
+	var id string
+	if err := securecookie.DecodeMulti(name, cookie.Value, &id, s.Codecs...); err != nil {
+		return session, nil
+	}
+
+	record, err := s.Backend.Get(id)
+	if err != nil {
+		return session, err
+	}
+	if record == nil {
+		return session, nil
+	}
+	if err := gob.NewDecoder(bytes.NewReader(record.Data)).Decode(&session.Values); err != nil {
+		return session, err
+	}
+	session.ID = record.ID
+	session.IsNew = false
+
+	return session, nil
+}
+
+func (s *SessionStore) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	if session.Options.MaxAge < 0 {
+		if session.ID != "" {
+			if err := s.Backend.Delete(session.ID); err != nil {
+				return err
+			}
+		}
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+
+	userID, ok := session.Values["userID"].(string)
+	if !ok {
+		return errSessionWithoutUser
+	}
+
+	var data bytes.Buffer
+	if err := gob.NewEncoder(&data).Encode(session.Values); err != nil {
+		return err
+	}
+
+	// MaxAge が0のcookieはブラウザを閉じるまで有効だが、サーバ側ではストアの既定の期限で失効させる
+	maxAge := session.Options.MaxAge
+	if maxAge == 0 {
+		maxAge = s.Options.MaxAge
+	}
+
+	now := time.Now()
+	record := &SessionRecord{
+		ID:        session.ID,
+		UserID:    userID,
+		Data:      data.Bytes(),
+		UserAgent: r.UserAgent(),
+		CreatedAt: now,
+		ExpiresAt: now.Add(time.Duration(maxAge) * time.Second),
+	}
+	if record.ID == "" {
+		id, err := newRandomToken()
+		if err != nil {
+			return err
+		}
+		record.ID = id
+	}
+	if len(record.UserAgent) > maxUserAgentLength {
+		record.UserAgent = record.UserAgent[:maxUserAgentLength]
+	}
+	if err := s.Backend.Save(record); err != nil {
+		return err
+	}
+	session.ID = record.ID
+
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.Codecs...)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, sessions.NewCookie(session.Name(), encoded, session.Options))
+
+	return nil
+}
+
+// ---------- MySQL ----------
+
+type mysqlSessionBackend struct {
+	DB *sqlx.DB
+}
+
+func (b *mysqlSessionBackend) Get(id string) (*SessionRecord, error) {
+	var record SessionRecord
+	if err := b.DB.Get(&record, "SELECT * FROM `sessions` WHERE `id` = ? AND `expires_at` > NOW(6)", id); err != nil && err != sql.ErrNoRows {
+		return nil, err
+	} else if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &record, nil
+}
+
+func (b *mysqlSessionBackend) Save(record *SessionRecord) error {
+	// 作成日時はセッションの初回保存時のものを維持する
+	_, err := b.DB.Exec("INSERT INTO `sessions` (`id`, `user_id`, `data`, `user_agent`, `created_at`, `expires_at`) VALUES (?, ?, ?, ?, ?, ?)"+
+		" ON DUPLICATE KEY UPDATE `user_id` = VALUES(`user_id`), `data` = VALUES(`data`), `user_agent` = VALUES(`user_agent`), `expires_at` = VALUES(`expires_at`)",
+		record.ID, record.UserID, record.Data, record.UserAgent, record.CreatedAt, record.ExpiresAt)
+	return err
+}
+
+func (b *mysqlSessionBackend) Delete(id string) error {
+	_, err := b.DB.Exec("DELETE FROM `sessions` WHERE `id` = ?", id)
+	return err
+}
+
+func (b *mysqlSessionBackend) DeleteByUserID(userID string) error {
+	_, err := b.DB.Exec("DELETE FROM `sessions` WHERE `user_id` = ?", userID)
+	return err
+}
+
+func (b *mysqlSessionBackend) ListByUserID(userID string) ([]SessionRecord, error) {
+	records := make([]SessionRecord, 0)
+	if err := b.DB.Select(&records, "SELECT * FROM `sessions` WHERE `user_id` = ? AND `expires_at` > NOW(6) ORDER BY `created_at`", userID); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+func (b *mysqlSessionBackend) DeleteExpired() error {
+	_, err := b.DB.Exec("DELETE FROM `sessions` WHERE `expires_at` <= NOW(6)")
+	return err
+}
+
+// ---------- in-memory ----------
+
+type memorySessionBackend struct {
+	records map[string]SessionRecord
+	mu      sync.RWMutex
+}
+
+func newMemorySessionBackend() *memorySessionBackend {
+	return &memorySessionBackend{
+		records: make(map[string]SessionRecord),
+	}
+}
+
+func (b *memorySessionBackend) Get(id string) (*SessionRecord, error) {
+	b.mu.RLock()
+	record, ok := b.records[id]
+	b.mu.RUnlock()
+	if !ok {
+		return nil, nil
+	}
+	if !record.ExpiresAt.After(time.Now()) {
+		b.mu.Lock()
+		delete(b.records, id)
+		b.mu.Unlock()
+		return nil, nil
+	}
+	return &record, nil
+}
+
+func (b *memorySessionBackend) Save(record *SessionRecord) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	saved := *record
+	if prev, ok := b.records[record.ID]; ok {
+		saved.CreatedAt = prev.CreatedAt
+	}
+	b.records[record.ID] = saved
+	return nil
+}
+
+func (b *memorySessionBackend) Delete(id string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.records, id)
+	return nil
+}
+
+func (b *memorySessionBackend) DeleteByUserID(userID string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for id, record := range b.records {
+		if record.UserID == userID {
+			delete(b.records, id)
+		}
+	}
+	return nil
+}
+
+func (b *memorySessionBackend) ListByUserID(userID string) ([]SessionRecord, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	now := time.Now()
+	records := make([]SessionRecord, 0)
+	for _, record := range b.records {
+		if record.UserID == userID && record.ExpiresAt.After(now) {
+			records = append(records, record)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].CreatedAt.Before(records[j].CreatedAt)
+	})
+	return records, nil
+}
+
+func (b *memorySessionBackend) DeleteExpired() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	for id, record := range b.records {
+		if !record.ExpiresAt.After(now) {
+			delete(b.records, id)
+		}
+	}
+	return nil
+}
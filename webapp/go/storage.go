@@ -0,0 +1,476 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// 課題ファイルの保存先
+//
+// ASSIGNMENT_STORAGE で保存先を切り替える。
+// 課題ファイルは提出ごとに <classID>-<userID>-<ULID>.pdf というキーで保存する(初期データは <classID>-<userID>.pdf)。
+//   - local: AssignmentsDirectory にキーをファイル名として保存する(既定)
+//   - cas: AssignmentsDirectory に内容の SHA-256 をキーとして保存し、同じ内容のファイルを重複して保存しない。
+//     ディレクトリを共有する複数のプロセスからの書き込みはファイルロックで直列化する
+//   - s3: S3 互換のオブジェクトストレージに保存する。複数のサーバから同じ課題ファイルを読み書きできる
+
+var errObjectNotFound = errors.New("object not found")
+
+// StorageObject 保存されたファイルの情報
+type StorageObject struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// Storage 課題ファイルの保存先
+type Storage interface {
+	// Put キーにファイルを保存する。すでに存在する場合は置き換える
+	Put(ctx context.Context, key string, r io.Reader) error
+	// Get ファイルを返す。存在しない場合は errObjectNotFound を返す
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete ファイルを削除する。存在しない場合も成功とする
+	Delete(ctx context.Context, key string) error
+	// List キーが prefix で始まるファイルをキーの順に返す
+	List(ctx context.Context, prefix string) ([]StorageObject, error)
+	// Stat ファイルの情報を返す。存在しない場合は errObjectNotFound を返す
+	Stat(ctx context.Context, key string) (StorageObject, error)
+}
+
+// NewStorage ASSIGNMENT_STORAGE に対応する課題ファイルの保存先を返す
+func NewStorage(kind string) (Storage, error) {
+	switch kind {
+	case "local":
+		return newLocalStorage(AssignmentsDirectory)
+	case "cas":
+		return newContentAddressedStorage(AssignmentsDirectory)
+	case "s3":
+		return newS3Storage(loadS3Config())
+	default:
+		return nil, fmt.Errorf("unknown assignment storage: %s", kind)
+	}
+}
+
+// storageResetter 保存されたファイルを一度にすべて削除できる保存先
+type storageResetter interface {
+	Reset(ctx context.Context) error
+}
+
+// resetStorage 保存されたファイルをすべて削除し、dir のファイルを初期データとして保存する
+func resetStorage(ctx context.Context, storage Storage, dir string) error {
+	if resetter, ok := storage.(storageResetter); ok {
+		if err := resetter.Reset(ctx); err != nil {
+			return err
+		}
+	} else {
+		objects, err := storage.List(ctx, "")
+		if err != nil {
+			return err
+		}
+		for _, object := range objects {
+			if err := storage.Delete(ctx, object.Key); err != nil {
+				return err
+			}
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if err := putFile(ctx, storage, entry.Name(), filepath.Join(dir, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func putFile(ctx context.Context, storage Storage, key string, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return storage.Put(ctx, key, file)
+}
+
+// validateStorageKey ディレクトリの外を指すキーを拒否する
+func validateStorageKey(key string) error {
+	if key == "" || strings.ContainsAny(key, "/\\") || strings.HasPrefix(key, ".") {
+		return fmt.Errorf("invalid storage key: %q", key)
+	}
+	return nil
+}
+
+// writeFileAtomic 一時ファイルに書き込んでから rename し、書き込み途中のファイルが読まれないようにする
+func writeFileAtomic(path string, r io.Reader) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func statFile(key string, path string) (StorageObject, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return StorageObject{}, errObjectNotFound
+	} else if err != nil {
+		return StorageObject{}, err
+	}
+	return StorageObject{Key: key, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+// listKeys ディレクトリ内のファイル名のうち prefix で始まるものを返す。一時ファイルは除く
+func listKeys(dir string, prefix string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		keys = append(keys, name)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// localStorage ディレクトリにキーをファイル名として保存する
+type localStorage struct {
+	Dir string
+}
+
+func newLocalStorage(dir string) (*localStorage, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &localStorage{Dir: dir}, nil
+}
+
+func (s *localStorage) Put(_ context.Context, key string, r io.Reader) error {
+	if err := validateStorageKey(key); err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(s.Dir, key), r)
+}
+
+func (s *localStorage) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	if err := validateStorageKey(key); err != nil {
+		return nil, err
+	}
+	file, err := os.Open(filepath.Join(s.Dir, key))
+	if os.IsNotExist(err) {
+		return nil, errObjectNotFound
+	}
+	return file, err
+}
+
+func (s *localStorage) Delete(_ context.Context, key string) error {
+	if err := validateStorageKey(key); err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(s.Dir, key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *localStorage) List(_ context.Context, prefix string) ([]StorageObject, error) {
+	keys, err := listKeys(s.Dir, prefix)
+	if err != nil {
+		return nil, err
+	}
+	objects := make([]StorageObject, 0, len(keys))
+	for _, key := range keys {
+		object, err := statFile(key, filepath.Join(s.Dir, key))
+		if err == errObjectNotFound {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		objects = append(objects, object)
+	}
+	return objects, nil
+}
+
+func (s *localStorage) Stat(_ context.Context, key string) (StorageObject, error) {
+	if err := validateStorageKey(key); err != nil {
+		return StorageObject{}, err
+	}
+	return statFile(key, filepath.Join(s.Dir, key))
+}
+
+// contentAddressedStorage ファイルの内容を objects/<SHA-256> に、キーから内容への参照を refs/<key> に、
+// 内容ごとの参照数を counts/<SHA-256> に保存する
+// 同じ内容のファイルは一つだけ保存し、参照数が0になった時点で削除する
+type contentAddressedStorage struct {
+	Dir string
+	// mu と lockFile で内容の保存・削除と参照の書き換えを直列化する
+	// lockFile は同じディレクトリを共有する他のプロセスとの排他に使う
+	mu       sync.Mutex
+	lockFile *os.File
+}
+
+func newContentAddressedStorage(dir string) (*contentAddressedStorage, error) {
+	for _, sub := range []string{"objects", "refs", "counts"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			return nil, err
+		}
+	}
+	lockFile, err := os.OpenFile(filepath.Join(dir, ".lock"), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &contentAddressedStorage{Dir: dir, lockFile: lockFile}, nil
+}
+
+// lock 他のゴルーチンと他のプロセスを排他し、解除する関数を返す
+func (s *contentAddressedStorage) lock() (func(), error) {
+	s.mu.Lock()
+	fd := int(s.lockFile.Fd())
+	if err := syscall.Flock(fd, syscall.LOCK_EX); err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	return func() {
+		syscall.Flock(fd, syscall.LOCK_UN)
+		s.mu.Unlock()
+	}, nil
+}
+
+func (s *contentAddressedStorage) objectPath(hash string) string {
+	return filepath.Join(s.Dir, "objects", hash)
+}
+
+func (s *contentAddressedStorage) refPath(key string) string {
+	return filepath.Join(s.Dir, "refs", key)
+}
+
+func (s *contentAddressedStorage) countPath(hash string) string {
+	return filepath.Join(s.Dir, "counts", hash)
+}
+
+// readRef キーが参照する内容のハッシュを返す
+func (s *contentAddressedStorage) readRef(key string) (string, error) {
+	b, err := os.ReadFile(s.refPath(key))
+	if os.IsNotExist(err) {
+		return "", errObjectNotFound
+	} else if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// addRefCount 内容の参照数に delta を加え、0になった内容を削除する。ロックを保持して呼ぶ
+func (s *contentAddressedStorage) addRefCount(hash string, delta int) error {
+	count := 0
+	b, err := os.ReadFile(s.countPath(hash))
+	if err == nil {
+		if count, err = strconv.Atoi(string(b)); err != nil {
+			return fmt.Errorf("invalid reference count of %s: %w", hash, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	count += delta
+	if count > 0 {
+		return writeFileAtomic(s.countPath(hash), strings.NewReader(strconv.Itoa(count)))
+	}
+	for _, path := range []string{s.objectPath(hash), s.countPath(hash)} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *contentAddressedStorage) Put(_ context.Context, key string, r io.Reader) error {
+	if err := validateStorageKey(key); err != nil {
+		return err
+	}
+
+	// ハッシュを求めながら一時ファイルに書き込む
+	tmp, err := os.CreateTemp(filepath.Join(s.Dir, "objects"), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, h), r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	hash := hex.EncodeToString(h.Sum(nil))
+
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	prevHash, err := s.readRef(key)
+	if err != nil && err != errObjectNotFound {
+		return err
+	}
+	if prevHash != hash {
+		if _, err := os.Stat(s.objectPath(hash)); os.IsNotExist(err) {
+			if err := os.Rename(tmp.Name(), s.objectPath(hash)); err != nil {
+				return err
+			}
+		} else if err != nil {
+			return err
+		}
+		if err := s.addRefCount(hash, 1); err != nil {
+			return err
+		}
+	}
+	// 同じ内容でも参照を書き換えて更新日時を進める
+	if err := writeFileAtomic(s.refPath(key), strings.NewReader(hash)); err != nil {
+		return err
+	}
+	if prevHash != "" && prevHash != hash {
+		return s.addRefCount(prevHash, -1)
+	}
+	return nil
+}
+
+func (s *contentAddressedStorage) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	if err := validateStorageKey(key); err != nil {
+		return nil, err
+	}
+	unlock, err := s.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	hash, err := s.readRef(key)
+	if err != nil {
+		return nil, err
+	}
+	// 開いたファイルは削除されても読み続けられる
+	file, err := os.Open(s.objectPath(hash))
+	if os.IsNotExist(err) {
+		return nil, errObjectNotFound
+	}
+	return file, err
+}
+
+func (s *contentAddressedStorage) Delete(_ context.Context, key string) error {
+	if err := validateStorageKey(key); err != nil {
+		return err
+	}
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	hash, err := s.readRef(key)
+	if err == errObjectNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if err := os.Remove(s.refPath(key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return s.addRefCount(hash, -1)
+}
+
+// Reset 内容・参照・参照数をまとめて削除する
+func (s *contentAddressedStorage) Reset(_ context.Context) error {
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	for _, sub := range []string{"objects", "refs", "counts"} {
+		dir := filepath.Join(s.Dir, sub)
+		if err := os.RemoveAll(dir); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *contentAddressedStorage) List(ctx context.Context, prefix string) ([]StorageObject, error) {
+	keys, err := listKeys(filepath.Join(s.Dir, "refs"), prefix)
+	if err != nil {
+		return nil, err
+	}
+	objects := make([]StorageObject, 0, len(keys))
+	for _, key := range keys {
+		object, err := s.Stat(ctx, key)
+		if err == errObjectNotFound {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		objects = append(objects, object)
+	}
+	return objects, nil
+}
+
+// Stat 更新日時は参照を書き換えた日時とする
+func (s *contentAddressedStorage) Stat(_ context.Context, key string) (StorageObject, error) {
+	if err := validateStorageKey(key); err != nil {
+		return StorageObject{}, err
+	}
+	unlock, err := s.lock()
+	if err != nil {
+		return StorageObject{}, err
+	}
+	defer unlock()
+
+	hash, err := s.readRef(key)
+	if err != nil {
+		return StorageObject{}, err
+	}
+	ref, err := statFile(key, s.refPath(key))
+	if err != nil {
+		return StorageObject{}, err
+	}
+	object, err := statFile(key, s.objectPath(hash))
+	if err != nil {
+		return StorageObject{}, err
+	}
+	object.ModTime = ref.ModTime
+	return object, nil
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3 互換のオブジェクトストレージ
+//
+// 署名(AWS Signature Version 4)とリクエストは標準ライブラリのみで行う。
+// MinIO などの互換実装でも使えるよう、バケットはパス形式(<endpoint>/<bucket>/<key>)で指定する。
+
+const (
+	s3Service       = "s3"
+	s3SigningAlgo   = "AWS4-HMAC-SHA256"
+	s3AmzDateFormat = "20060102T150405Z"
+	// s3ErrorBodyLimit エラーに含めるレスポンスボディの上限
+	s3ErrorBodyLimit = 1024
+	// s3UnsignedPayload ボディを読み込まずに送れるよう、ボディのハッシュを署名に含めない
+	s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+type S3Config struct {
+	// Endpoint スキームを含むURL。例: http://127.0.0.1:9000
+	Endpoint        string
+	Bucket          string
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// Prefix すべてのキーの前につける文字列
+	Prefix string
+}
+
+// loadS3Config 環境変数から S3 互換ストレージの設定を読み込む
+//   - S3_ENDPOINT, S3_BUCKET, S3_REGION(既定値は us-east-1), S3_ACCESS_KEY_ID, S3_SECRET_ACCESS_KEY, S3_PREFIX
+func loadS3Config() S3Config {
+	return S3Config{
+		Endpoint:        GetEnv("S3_ENDPOINT", "http://127.0.0.1:9000"),
+		Bucket:          GetEnv("S3_BUCKET", "isucholar"),
+		Region:          GetEnv("S3_REGION", "us-east-1"),
+		AccessKeyID:     GetEnv("S3_ACCESS_KEY_ID", ""),
+		SecretAccessKey: GetEnv("S3_SECRET_ACCESS_KEY", ""),
+		Prefix:          GetEnv("S3_PREFIX", "assignments/"),
+	}
+}
+
+type s3Storage struct {
+	Config   S3Config
+	Client   *http.Client
+	endpoint *url.URL
+}
+
+func newS3Storage(config S3Config) (*s3Storage, error) {
+	endpoint, err := url.Parse(strings.TrimSuffix(config.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid S3_ENDPOINT: %s", config.Endpoint)
+	}
+	if config.Bucket == "" {
+		return nil, fmt.Errorf("S3_BUCKET is required")
+	}
+	return &s3Storage{
+		Config:   config,
+		Client:   &http.Client{Timeout: 30 * time.Second},
+		endpoint: endpoint,
+	}, nil
+}
+
+// do 署名したリクエストを送る。ステータスコードが okStatus 以外の場合はボディを閉じてエラーを返す
+// body が nil でない場合は size バイトのボディを UNSIGNED-PAYLOAD として送る
+func (s *s3Storage) do(ctx context.Context, method string, key string, query url.Values, body io.Reader, size int64, okStatus int) (*http.Response, error) {
+	u := *s.endpoint
+	u.Path = s.endpoint.Path + "/" + s.Config.Bucket + "/" + key
+	u.RawPath = s.endpoint.Path + "/" + s3URIEncode(s.Config.Bucket, false) + "/" + s3URIEncode(key, true)
+	u.RawQuery = s3CanonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	payloadHash := sha256Hex(nil)
+	if body != nil {
+		payloadHash = s3UnsignedPayload
+		if size > 0 {
+			req.Body = io.NopCloser(body)
+			req.ContentLength = size
+		}
+	}
+	s3Sign(req, payloadHash, s.Config, time.Now())
+
+	res, err := s.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode == http.StatusNotFound {
+		res.Body.Close()
+		return nil, errObjectNotFound
+	}
+	if res.StatusCode != okStatus {
+		defer res.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, s3ErrorBodyLimit))
+		return nil, fmt.Errorf("s3: %s %s: %s: %s", method, key, res.Status, msg)
+	}
+	return res, nil
+}
+
+func (s *s3Storage) Put(ctx context.Context, key string, r io.Reader) error {
+	body, size, err := s3Body(r)
+	if err != nil {
+		return err
+	}
+	res, err := s.do(ctx, http.MethodPut, s.Config.Prefix+key, nil, body, size, http.StatusOK)
+	if err != nil {
+		return err
+	}
+	return res.Body.Close()
+}
+
+func (s *s3Storage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	res, err := s.do(ctx, http.MethodGet, s.Config.Prefix+key, nil, nil, 0, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
+
+func (s *s3Storage) Delete(ctx context.Context, key string) error {
+	res, err := s.do(ctx, http.MethodDelete, s.Config.Prefix+key, nil, nil, 0, http.StatusNoContent)
+	if err == errObjectNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return res.Body.Close()
+}
+
+type s3ListBucketResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (s *s3Storage) List(ctx context.Context, prefix string) ([]StorageObject, error) {
+	var objects []StorageObject
+	query := url.Values{
+		"list-type": {"2"},
+		"prefix":    {s.Config.Prefix + prefix},
+	}
+	for {
+		res, err := s.do(ctx, http.MethodGet, "", query, nil, 0, http.StatusOK)
+		if err == errObjectNotFound {
+			return nil, fmt.Errorf("s3: no such bucket: %s", s.Config.Bucket)
+		} else if err != nil {
+			return nil, err
+		}
+		var result s3ListBucketResult
+		err = xml.NewDecoder(res.Body).Decode(&result)
+		res.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, content := range result.Contents {
+			objects = append(objects, StorageObject{
+				Key:     strings.TrimPrefix(content.Key, s.Config.Prefix),
+				Size:    content.Size,
+				ModTime: content.LastModified,
+			})
+		}
+		if !result.IsTruncated {
+			break
+		}
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].Key < objects[j].Key
+	})
+	return objects, nil
+}
+
+func (s *s3Storage) Stat(ctx context.Context, key string) (StorageObject, error) {
+	res, err := s.do(ctx, http.MethodHead, s.Config.Prefix+key, nil, nil, 0, http.StatusOK)
+	if err != nil {
+		return StorageObject{}, err
+	}
+	res.Body.Close()
+
+	object := StorageObject{Key: key}
+	if size, err := strconv.ParseInt(res.Header.Get("Content-Length"), 10, 64); err == nil {
+		object.Size = size
+	}
+	if t, err := http.ParseTime(res.Header.Get("Last-Modified")); err == nil {
+		object.ModTime = t
+	}
+	return object, nil
+}
+
+// s3Body PUT するボディとその長さ。S3 は Content-Length のない PUT を受け付けないため、
+// 長さの分からない Reader は一度すべて読み込む
+func s3Body(r io.Reader) (io.Reader, int64, error) {
+	if seeker, ok := r.(io.Seeker); ok {
+		current, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, 0, err
+		}
+		end, err := seeker.Seek(0, io.SeekEnd)
+		if err != nil {
+			return nil, 0, err
+		}
+		if _, err := seeker.Seek(current, io.SeekStart); err != nil {
+			return nil, 0, err
+		}
+		return r, end - current, nil
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, 0, err
+	}
+	return bytes.NewReader(b), int64(len(b)), nil
+}
+
+// s3Sign リクエストに AWS Signature Version 4 の Authorization ヘッダをつける
+// payloadHash はボディの SHA-256 か UNSIGNED-PAYLOAD。リクエストに設定済みのヘッダはすべて署名の対象にする
+func s3Sign(req *http.Request, payloadHash string, config S3Config, now time.Time) {
+	amzDate := now.UTC().Format(s3AmzDateFormat)
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalURI := req.URL.EscapedPath()
+	if canonicalURI == "" {
+		canonicalURI = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI,
+		s3CanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{date, config.Region, s3Service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{s3SigningAlgo, amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+config.SecretAccessKey), date)
+	key = hmacSHA256(key, config.Region)
+	key = hmacSHA256(key, s3Service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3SigningAlgo, config.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// s3CanonicalQuery キーの順に並べてエンコードしたクエリ文字列
+func s3CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var params []string
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			params = append(params, s3URIEncode(key, false)+"="+s3URIEncode(value, false))
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+// s3URIEncode RFC 3986 の非予約文字以外をエンコードする。keepSlash の場合は / をそのまま残す
+func s3URIEncode(s string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' || (keepSlash && c == '/') {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// 時間割
+//
+// 履修中の科目を曜日(月〜金) x 時限(1〜6)の表にする。
+// iCalendar 形式では学期の開始日と時限ごとの時刻から、各科目を学期の週数だけ毎週繰り返す予定として書き出す。
+
+const (
+	// timetablePeriods 1日の時限の数
+	timetablePeriods     = 6
+	defaultPeriodTimes   = "09:00-10:30,10:40-12:10,13:00-14:30,14:40-16:10,16:20-17:50,18:00-19:30"
+	defaultSemesterWeeks = 15
+)
+
+// timetableLocation 時間割の時刻はすべて日本時間
+var timetableLocation = time.FixedZone("Asia/Tokyo", 9*60*60)
+
+type PeriodTime struct {
+	Period int    `json:"period"`
+	Start  string `json:"start"`
+	End    string `json:"end"`
+}
+
+type TimetableConfig struct {
+	// SemesterStart 学期の最初の週の月曜日
+	SemesterStart time.Time
+	Weeks         int
+	// Periods 1時限目から順に、各時限の開始時刻と終了時刻
+	Periods []PeriodTime
+}
+
+// LoadTimetableConfig 環境変数から時間割の設定を読み込む
+//   - SEMESTER_START: 学期の開始日(YYYY-MM-DD)。月曜日でない場合は次の月曜日から始める。既定値は今年度の4月1日
+//   - SEMESTER_WEEKS: 学期の週数
+//   - PERIOD_TIMES: カンマ区切りの各時限の時刻(HH:MM-HH:MM)
+func LoadTimetableConfig() (TimetableConfig, error) {
+	var config TimetableConfig
+
+	if s := GetEnv("SEMESTER_START", ""); s != "" {
+		start, err := time.ParseInLocation("2006-01-02", s, timetableLocation)
+		if err != nil {
+			return config, fmt.Errorf("invalid SEMESTER_START: %w", err)
+		}
+		config.SemesterStart = start
+	} else {
+		now := time.Now().In(timetableLocation)
+		year := now.Year()
+		if now.Month() < time.April {
+			year--
+		}
+		config.SemesterStart = time.Date(year, time.April, 1, 0, 0, 0, 0, timetableLocation)
+	}
+	// time.Sunday = 0, time.Monday = 1
+	config.SemesterStart = config.SemesterStart.AddDate(0, 0, (8-int(config.SemesterStart.Weekday()))%7)
+
+	weeks, err := strconv.Atoi(GetEnv("SEMESTER_WEEKS", strconv.Itoa(defaultSemesterWeeks)))
+	if err != nil || weeks <= 0 {
+		return config, fmt.Errorf("invalid SEMESTER_WEEKS")
+	}
+	config.Weeks = weeks
+
+	for i, s := range strings.Split(GetEnv("PERIOD_TIMES", defaultPeriodTimes), ",") {
+		arr := strings.Split(strings.TrimSpace(s), "-")
+		if len(arr) != 2 {
+			return config, fmt.Errorf("invalid PERIOD_TIMES: %s", s)
+		}
+		start, err1 := time.Parse("15:04", arr[0])
+		end, err2 := time.Parse("15:04", arr[1])
+		if err1 != nil || err2 != nil || !start.Before(end) {
+			return config, fmt.Errorf("invalid PERIOD_TIMES: %s", s)
+		}
+		config.Periods = append(config.Periods, PeriodTime{Period: i + 1, Start: arr[0], End: arr[1]})
+	}
+	if len(config.Periods) < timetablePeriods {
+		return config, fmt.Errorf("PERIOD_TIMES must have %d periods", timetablePeriods)
+	}
+	config.Periods = config.Periods[:timetablePeriods]
+
+	return config, nil
+}
+
+type TimetableAssignment struct {
+	ClassID string `json:"class_id"`
+	Part    uint8  `json:"part"`
+	Title   string `json:"title"`
+}
+
+type TimetableCell struct {
+	CourseID   string    `json:"course_id" db:"course_id"`
+	Code       string    `json:"code" db:"code"`
+	Name       string    `json:"name" db:"name"`
+	Teacher    string    `json:"teacher" db:"teacher"`
+	Period     uint8     `json:"period" db:"period"`
+	DayOfWeek  DayOfWeek `json:"day_of_week" db:"day_of_week"`
+	ClassCount int       `json:"class_count" db:"-"`
+	// NextAssignment 未提出で提出を締め切っていない課題のうち最も前の講義のもの。ない場合は null
+	NextAssignment *TimetableAssignment `json:"next_assignment" db:"-"`
+}
+
+type GetTimetableResponse struct {
+	// Timetable [曜日(月〜金)][時限-1] の表。科目のないコマは null
+	Timetable [][]*TimetableCell `json:"timetable"`
+	Periods   []PeriodTime       `json:"periods"`
+}
+
+func newTimetable() [][]*TimetableCell {
+	timetable := make([][]*TimetableCell, len(daysOfWeek))
+	for i := range timetable {
+		timetable[i] = make([]*TimetableCell, timetablePeriods)
+	}
+	return timetable
+}
+
+// putTimetableCell 科目を曜日と時限のコマに置く。時間割の範囲外の科目は無視する
+func putTimetableCell(timetable [][]*TimetableCell, cell *TimetableCell) {
+	for i, dayOfWeek := range daysOfWeek {
+		if dayOfWeek == cell.DayOfWeek && cell.Period >= 1 && int(cell.Period) <= timetablePeriods {
+			timetable[i][cell.Period-1] = cell
+			return
+		}
+	}
+}
+
+// writeTimetableICS 時間割の各コマを学期の週数だけ繰り返す予定として iCalendar(RFC 5545) 形式で書き出す
+func writeTimetableICS(w io.Writer, config TimetableConfig, timetable [][]*TimetableCell, now time.Time) error {
+	bw := bufio.NewWriter(w)
+	writeLine := func(line string) {
+		bw.WriteString(foldICSLine(line))
+		bw.WriteString("\r\n")
+	}
+	utc := func(t time.Time) string {
+		return t.UTC().Format("20060102T150405Z")
+	}
+
+	writeLine("BEGIN:VCALENDAR")
+	writeLine("VERSION:2.0")
+	writeLine("PRODID:-//ISUCON//ISUCHOLAR//JA")
+	writeLine("CALSCALE:GREGORIAN")
+	writeLine("X-WR-CALNAME:" + escapeICSText("ISUCHOLAR 時間割"))
+	for day, cells := range timetable {
+		for period, cell := range cells {
+			if cell == nil {
+				continue
+			}
+			date := config.SemesterStart.AddDate(0, 0, day)
+			start, _ := time.ParseInLocation("2006-01-02 15:04", date.Format("2006-01-02")+" "+config.Periods[period].Start, timetableLocation)
+			end, _ := time.ParseInLocation("2006-01-02 15:04", date.Format("2006-01-02")+" "+config.Periods[period].End, timetableLocation)
+
+			writeLine("BEGIN:VEVENT")
+			writeLine("UID:" + cell.CourseID + "@isucholar")
+			writeLine("DTSTAMP:" + utc(now))
+			writeLine("DTSTART:" + utc(start))
+			writeLine("DTEND:" + utc(end))
+			writeLine(fmt.Sprintf("RRULE:FREQ=WEEKLY;COUNT=%d", config.Weeks))
+			writeLine("SUMMARY:" + escapeICSText(cell.Name))
+			writeLine("DESCRIPTION:" + escapeICSText(fmt.Sprintf("%s\n担当教員: %s", cell.Code, cell.Teacher)))
+			writeLine("END:VEVENT")
+		}
+	}
+	writeLine("END:VCALENDAR")
+
+	return bw.Flush()
+}
+
+var icsTextEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func escapeICSText(s string) string {
+	return icsTextEscaper.Replace(s)
+}
+
+// foldICSLine 75オクテットを超える行を折り返す。マルチバイト文字の途中では折り返さない
+func foldICSLine(line string) string {
+	const maxOctets = 75
+	var b strings.Builder
+	width := 0
+	for _, r := range line {
+		n := utf8.RuneLen(r)
+		if width+n > maxOctets {
+			b.WriteString("\r\n ")
+			// 継続行の先頭の空白も1オクテットに数える
+			width = 1
+		}
+		b.WriteRune(r)
+		width += n
+	}
+	return b.String()
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/isucon/isucon11-final/webapp/go/pdf"
+)
+
+// 成績証明書
+
+// A4縦
+const (
+	transcriptPageWidth  = 595.28
+	transcriptPageHeight = 841.89
+	transcriptMarginX    = 50
+)
+
+type TranscriptCourse struct {
+	ID         string      `db:"id"`
+	Code       string      `db:"code"`
+	Name       string      `db:"name"`
+	Credit     uint8       `db:"credit"`
+	TotalScore int         `db:"total_score"`
+	Grade      courseGrade `db:"-"`
+}
+
+type Transcript struct {
+	UserCode string
+	UserName string
+	IssuedAt time.Time
+	Courses  []TranscriptCourse
+	Credits  int // 修得単位数
+	GPA      float64
+}
+
+// gradePoint 科目のGP。GPAの対象外の科目では空文字
+func (c TranscriptCourse) gradePoint() string {
+	if c.Grade.GPACredits == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%.2f", float64(c.Grade.WeightedGradePoints)/100/float64(c.Grade.GPACredits))
+}
+
+// writeTranscriptCSV 科目ごとの行と、単位数の合計とGPAを載せた TOTAL 行を書き出す
+func writeTranscriptCSV(w io.Writer, t *Transcript) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"code", "name", "credit", "total_score", "grade", "earned_credits", "grade_point"}); err != nil {
+		return err
+	}
+
+	credits := 0
+	for _, course := range t.Courses {
+		credits += int(course.Credit)
+		if err := cw.Write([]string{
+			course.Code,
+			course.Name,
+			strconv.Itoa(int(course.Credit)),
+			strconv.Itoa(course.TotalScore),
+			course.Grade.Grade,
+			strconv.Itoa(course.Grade.Credits),
+			course.gradePoint(),
+		}); err != nil {
+			return err
+		}
+	}
+	if err := cw.Write([]string{"TOTAL", "", strconv.Itoa(credits), "", "", strconv.Itoa(t.Credits), fmt.Sprintf("%.2f", t.GPA)}); err != nil {
+		return err
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeTranscriptPDF 成績証明書のPDFを書き出す。科目の数が多い場合は表を複数ページに分ける
+func writeTranscriptPDF(w io.Writer, t *Transcript) error {
+	doc := pdf.New(transcriptPageWidth, transcriptPageHeight)
+	doc.Margin = 60
+
+	p := doc.AddPage()
+	y := transcriptPageHeight - doc.Margin
+	p.Text(pdf.Gothic, 20, transcriptMarginX, y, "成績証明書")
+	y -= 16
+	p.Text(pdf.Helvetica, 10, transcriptMarginX, y, "Academic Transcript")
+	y -= 28
+	p.Text(pdf.Gothic, 10, transcriptMarginX, y, fmt.Sprintf("学籍番号: %s\n氏名: %s\n発行日: %s", t.UserCode, t.UserName, t.IssuedAt.Format("2006-01-02")))
+	y -= 50
+
+	table := &pdf.Table{
+		Columns: []pdf.Column{
+			{Header: "科目コード", Width: 70},
+			{Header: "科目名", Width: 225},
+			{Header: "単位数", Width: 50},
+			{Header: "総合点", Width: 50},
+			{Header: "評語", Width: 50},
+			{Header: "修得単位", Width: 50},
+		},
+		Font:     pdf.Gothic,
+		FontSize: 9,
+	}
+	for _, course := range t.Courses {
+		grade := course.Grade.Grade
+		if grade == "" {
+			grade = "-"
+		}
+		table.Rows = append(table.Rows, []string{
+			course.Code,
+			course.Name,
+			strconv.Itoa(int(course.Credit)),
+			strconv.Itoa(course.TotalScore),
+			grade,
+			strconv.Itoa(course.Grade.Credits),
+		})
+	}
+	p, y = table.Draw(p, transcriptMarginX, y)
+
+	if y-40 < doc.Margin {
+		p = doc.AddPage()
+		y = transcriptPageHeight - doc.Margin
+	}
+	y -= 24
+	p.Text(pdf.Gothic, 10, transcriptMarginX, y, fmt.Sprintf("修得単位数: %d\nGPA: %.2f", t.Credits, t.GPA))
+
+	pages := doc.Pages()
+	for i, page := range pages {
+		page.Text(pdf.Helvetica, 8, transcriptPageWidth/2-10, doc.Margin/2, fmt.Sprintf("%d / %d", i+1, len(pages)))
+	}
+
+	return doc.Write(w)
+}
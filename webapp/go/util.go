@@ -1,9 +1,12 @@
 package main
 
 import (
+	crand "crypto/rand"
+	"encoding/base64"
 	"math"
 	"math/rand"
 	"os"
+	"sort"
 	"sync"
 	"time"
 
@@ -38,6 +41,15 @@ func newULID() string {
 	return ulid.MustNew(ulid.Now(), entropy).String()
 }
 
+// newRandomToken 推測できないランダムなトークンを生成する
+func newRandomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := crand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
 // ----- int -----
 
 func averageInt(arr []int, or float64) float64 {
@@ -98,6 +110,31 @@ func tScoreInt(v int, arr []int) float64 {
 	}
 }
 
+// percentileInt p(0~100)パーセンタイルを隣接する値の線形補間で求める
+func percentileInt(arr []int, p float64, or float64) float64 {
+	if len(arr) == 0 {
+		return or
+	}
+	sorted := make([]int, len(arr))
+	copy(sorted, arr)
+	sort.Ints(sorted)
+
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	if lower < 0 {
+		return float64(sorted[0])
+	}
+	if upper >= len(sorted) {
+		return float64(sorted[len(sorted)-1])
+	}
+	return float64(sorted[lower]) + (rank-float64(lower))*float64(sorted[upper]-sorted[lower])
+}
+
+func medianInt(arr []int, or float64) float64 {
+	return percentileInt(arr, 50, or)
+}
+
 // ----- float64 -----
 
 func isAllEqualFloat64(arr []float64) bool {
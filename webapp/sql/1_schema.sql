@@ -1,10 +1,23 @@
 -- CREATEと逆順
+DROP TABLE IF EXISTS `lottery_preferences`;
+DROP TABLE IF EXISTS `course_lotteries`;
+DROP TABLE IF EXISTS `course_requisites`;
+DROP TABLE IF EXISTS `class_weights`;
+DROP TABLE IF EXISTS `grading_policies`;
+DROP TABLE IF EXISTS `user_gpas`;
+DROP TABLE IF EXISTS `course_total_scores`;
+DROP TABLE IF EXISTS `password_reset_tokens`;
+DROP TABLE IF EXISTS `sessions`;
+DROP TABLE IF EXISTS `course_teachers`;
+DROP TABLE IF EXISTS `course_status_history`;
+DROP TABLE IF EXISTS `waitlists`;
 DROP TABLE IF EXISTS `unread_announcements`;
 DROP TABLE IF EXISTS `announcements`;
 DROP TABLE IF EXISTS `submissions`;
 DROP TABLE IF EXISTS `classes`;
 DROP TABLE IF EXISTS `registrations`;
 DROP TABLE IF EXISTS `courses`;
+DROP TABLE IF EXISTS `semesters`;
 DROP TABLE IF EXISTS `users`;
 
 -- master data
@@ -17,6 +30,15 @@ CREATE TABLE `users`
     `type`            ENUM ('student', 'teacher') NOT NULL
 );
 
+CREATE TABLE `semesters`
+(
+    `id`           CHAR(26) PRIMARY KEY,
+    `code`         VARCHAR(255) UNIQUE NOT NULL,
+    `name`         VARCHAR(255)        NOT NULL,
+    `starts_on`    DATE                NOT NULL,
+    `credit_limit` INT UNSIGNED        NULL
+);
+
 CREATE TABLE `courses`
 (
     `id`          CHAR(26) PRIMARY KEY,
@@ -30,11 +52,19 @@ CREATE TABLE `courses`
     `teacher_id`  CHAR(26)                                                      NOT NULL,
     `keywords`    TEXT                                                          NOT NULL,
     `status`      ENUM ('registration', 'in-progress', 'closed')                NOT NULL DEFAULT 'registration',
-    CONSTRAINT FK_courses_teacher_id FOREIGN KEY (`teacher_id`) REFERENCES `users` (`id`)
+    `capacity`    SMALLINT UNSIGNED                                             NOT NULL DEFAULT 50,
+    `semester_id` CHAR(26)                                                      NULL, -- NULL の場合は現在の学期
+    CONSTRAINT FK_courses_teacher_id FOREIGN KEY (`teacher_id`) REFERENCES `users` (`id`),
+    CONSTRAINT FK_courses_semester_id FOREIGN KEY (`semester_id`) REFERENCES `semesters` (`id`)
 );
 
 CREATE INDEX `courses_01` on courses(`teacher_id`);
 
+-- 科目検索用の全文検索インデックス
+-- ngram パーサではストップワードを含むトークンが全て除外されるため、ストップワードを無効にしてから作成する
+SET SESSION innodb_ft_enable_stopword = OFF;
+CREATE FULLTEXT INDEX `courses_02` on courses(`name`, `keywords`) WITH PARSER ngram;
+
 CREATE TABLE `registrations`
 (
     `course_id` CHAR(26),
@@ -55,6 +85,9 @@ CREATE TABLE `classes`
     `title`             VARCHAR(255)     NOT NULL,
     `description`       TEXT             NOT NULL,
     `submission_closed` TINYINT(1)       NOT NULL DEFAULT false,
+    `deadline`          DATETIME(6)      NULL,
+    `late_until`        DATETIME(6)      NULL,
+    `late_penalty`      TINYINT UNSIGNED NOT NULL DEFAULT 0,
     UNIQUE KEY `idx_classes_course_id_part` (`course_id`, `part`),
     CONSTRAINT FK_classes_course_id FOREIGN KEY (`course_id`) REFERENCES `courses` (`id`)
 );
@@ -63,10 +96,13 @@ CREATE INDEX `classes_01` on classes(`course_id`);
 
 CREATE TABLE `submissions`
 (
-    `user_id`   CHAR(26)     NOT NULL,
-    `class_id`  CHAR(26)     NOT NULL,
-    `file_name` VARCHAR(255) NOT NULL,
-    `score`     TINYINT UNSIGNED,
+    `user_id`      CHAR(26)     NOT NULL,
+    `class_id`     CHAR(26)     NOT NULL,
+    `file_name`    VARCHAR(255) NOT NULL,
+    `storage_key`  VARCHAR(255) NULL, -- 課題ファイルのキー。NULL の場合は <class_id>-<user_id>.pdf
+    `score`        TINYINT UNSIGNED,
+    `late`         TINYINT(1)   NOT NULL DEFAULT false,
+    `submitted_at` DATETIME(6)  NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
     PRIMARY KEY (`user_id`, `class_id`),
     CONSTRAINT FK_submissions_user_id FOREIGN KEY (`user_id`) REFERENCES `users` (`id`),
     CONSTRAINT FK_submissions_class_id FOREIGN KEY (`class_id`) REFERENCES `classes` (`id`)
@@ -98,3 +134,150 @@ CREATE TABLE `unread_announcements`
 
 CREATE INDEX `unread_announcements_01` on unread_announcements(`announcement_id`);
 CREATE INDEX `unread_announcements_02` on unread_announcements(`course_id`);
+
+CREATE TABLE `waitlists`
+(
+    `id`         BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    `course_id`  CHAR(26)    NOT NULL,
+    `user_id`    CHAR(26)    NOT NULL,
+    `created_at` DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    UNIQUE KEY `idx_waitlists_course_id_user_id` (`course_id`, `user_id`),
+    CONSTRAINT FK_waitlists_course_id FOREIGN KEY (`course_id`) REFERENCES `courses` (`id`),
+    CONSTRAINT FK_waitlists_user_id FOREIGN KEY (`user_id`) REFERENCES `users` (`id`)
+);
+
+CREATE INDEX `waitlists_01` on waitlists(`user_id`);
+
+CREATE TABLE `course_status_history`
+(
+    `id`          BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    `course_id`   CHAR(26)                                       NOT NULL,
+    `from_status` ENUM ('registration', 'in-progress', 'closed') NOT NULL,
+    `to_status`   ENUM ('registration', 'in-progress', 'closed') NOT NULL,
+    `actor_id`    CHAR(26)                                       NOT NULL,
+    `created_at`  DATETIME(6)                                    NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    CONSTRAINT FK_course_status_history_course_id FOREIGN KEY (`course_id`) REFERENCES `courses` (`id`),
+    CONSTRAINT FK_course_status_history_actor_id FOREIGN KEY (`actor_id`) REFERENCES `users` (`id`)
+);
+
+CREATE INDEX `course_status_history_01` on course_status_history(`course_id`);
+
+CREATE TABLE `course_teachers`
+(
+    `course_id`  CHAR(26)    NOT NULL,
+    `teacher_id` CHAR(26)    NOT NULL,
+    `created_at` DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    PRIMARY KEY (`course_id`, `teacher_id`),
+    CONSTRAINT FK_course_teachers_course_id FOREIGN KEY (`course_id`) REFERENCES `courses` (`id`),
+    CONSTRAINT FK_course_teachers_teacher_id FOREIGN KEY (`teacher_id`) REFERENCES `users` (`id`)
+);
+
+CREATE INDEX `course_teachers_01` on course_teachers(`teacher_id`);
+
+CREATE TABLE `sessions`
+(
+    `id`         VARCHAR(64) PRIMARY KEY,
+    `user_id`    CHAR(26)     NOT NULL,
+    `data`       BLOB         NOT NULL,
+    `user_agent` VARCHAR(255) NOT NULL,
+    `created_at` DATETIME(6)  NOT NULL,
+    `expires_at` DATETIME(6)  NOT NULL,
+    CONSTRAINT FK_sessions_user_id FOREIGN KEY (`user_id`) REFERENCES `users` (`id`)
+);
+
+CREATE INDEX `sessions_01` on sessions(`user_id`);
+CREATE INDEX `sessions_02` on sessions(`expires_at`);
+
+CREATE TABLE `password_reset_tokens`
+(
+    `token_hash` CHAR(64) PRIMARY KEY,
+    `user_id`    CHAR(26)    NOT NULL,
+    `issuer_id`  CHAR(26)    NOT NULL,
+    `expires_at` DATETIME(6) NOT NULL,
+    `used_at`    DATETIME(6) NULL,
+    `created_at` DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    CONSTRAINT FK_password_reset_tokens_user_id FOREIGN KEY (`user_id`) REFERENCES `users` (`id`),
+    CONSTRAINT FK_password_reset_tokens_issuer_id FOREIGN KEY (`issuer_id`) REFERENCES `users` (`id`)
+);
+
+CREATE INDEX `password_reset_tokens_01` on password_reset_tokens(`user_id`);
+
+-- 成績の集計値
+CREATE TABLE `course_total_scores`
+(
+    `course_id`   CHAR(26)     NOT NULL,
+    `user_id`     CHAR(26)     NOT NULL,
+    `total_score` INT UNSIGNED NOT NULL DEFAULT 0,
+    PRIMARY KEY (`course_id`, `user_id`),
+    CONSTRAINT FK_course_total_scores_course_id FOREIGN KEY (`course_id`) REFERENCES `courses` (`id`),
+    CONSTRAINT FK_course_total_scores_user_id FOREIGN KEY (`user_id`) REFERENCES `users` (`id`)
+);
+
+CREATE INDEX `course_total_scores_01` on course_total_scores(`user_id`);
+
+CREATE TABLE `user_gpas`
+(
+    `user_id`              CHAR(26)        PRIMARY KEY,
+    `credits`               INT UNSIGNED    NOT NULL,
+    `gpa_credits`           INT UNSIGNED    NOT NULL,
+    `weighted_grade_points` BIGINT UNSIGNED NOT NULL,
+    CONSTRAINT FK_user_gpas_user_id FOREIGN KEY (`user_id`) REFERENCES `users` (`id`)
+);
+
+-- 成績評価の方針
+CREATE TABLE `grading_policies`
+(
+    `course_id`  CHAR(26) PRIMARY KEY,
+    `type`       ENUM ('score', 'letter', 'pass_fail') NOT NULL,
+    `pass_score` INT UNSIGNED NOT NULL DEFAULT 0,
+    `cutoff_s`   INT UNSIGNED NOT NULL DEFAULT 0,
+    `cutoff_a`   INT UNSIGNED NOT NULL DEFAULT 0,
+    `cutoff_b`   INT UNSIGNED NOT NULL DEFAULT 0,
+    `cutoff_c`   INT UNSIGNED NOT NULL DEFAULT 0,
+    `updated_at` DATETIME(6)  NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),
+    CONSTRAINT FK_grading_policies_course_id FOREIGN KEY (`course_id`) REFERENCES `courses` (`id`)
+);
+
+CREATE TABLE `class_weights`
+(
+    `class_id` CHAR(26) PRIMARY KEY,
+    `weight`   INT UNSIGNED NOT NULL,
+    CONSTRAINT FK_class_weights_class_id FOREIGN KEY (`class_id`) REFERENCES `classes` (`id`)
+);
+
+-- 履修要件
+CREATE TABLE `course_requisites`
+(
+    `course_id`          CHAR(26)                             NOT NULL,
+    `required_course_id` CHAR(26)                             NOT NULL,
+    `type`               ENUM ('prerequisite', 'corequisite') NOT NULL,
+    PRIMARY KEY (`course_id`, `required_course_id`),
+    CONSTRAINT FK_course_requisites_course_id FOREIGN KEY (`course_id`) REFERENCES `courses` (`id`),
+    CONSTRAINT FK_course_requisites_required_course_id FOREIGN KEY (`required_course_id`) REFERENCES `courses` (`id`)
+);
+
+CREATE INDEX `course_requisites_01` on course_requisites(`required_course_id`);
+
+CREATE TABLE `course_lotteries`
+(
+    `course_id`    CHAR(26) PRIMARY KEY,
+    `seed`         BIGINT                                NOT NULL,
+    `status`       ENUM ('open', 'pending', 'allocated') NOT NULL DEFAULT 'open',
+    `allocated_at` DATETIME(6)                           NULL,
+    CONSTRAINT FK_course_lotteries_course_id FOREIGN KEY (`course_id`) REFERENCES `courses` (`id`)
+);
+
+CREATE INDEX `course_lotteries_01` on course_lotteries(`status`);
+
+CREATE TABLE `lottery_preferences`
+(
+    `user_id`   CHAR(26)                        NOT NULL,
+    `course_id` CHAR(26)                        NOT NULL,
+    `priority`  TINYINT UNSIGNED                NOT NULL,
+    `result`    ENUM ('pending', 'won', 'lost') NOT NULL DEFAULT 'pending',
+    PRIMARY KEY (`user_id`, `course_id`),
+    CONSTRAINT FK_lottery_preferences_user_id FOREIGN KEY (`user_id`) REFERENCES `users` (`id`),
+    CONSTRAINT FK_lottery_preferences_course_id FOREIGN KEY (`course_id`) REFERENCES `course_lotteries` (`course_id`)
+);
+
+CREATE INDEX `lottery_preferences_01` on lottery_preferences(`course_id`, `result`);
@@ -5050,34 +5050,36 @@ INSERT INTO `users` (`id`, `code`, `name`, `hashed_password`, `type`) VALUES
 ('01FF6J8Y2R0N78V004RF3J997X','S04997','斎藤 誠','$2a$04$8jQMrwGG4YenDaj66vdIRO55GqD8jIXZj/cS5ZMqjCCC9hty.5l2i','student'),
 ('01FF6J8Y2R3HXQRX34H6XFG6Y7','S04998','佐藤 麻美','$2a$04$ocHE13VxVW8LV5ZHOcdi8eCbTmCnb6mRNhQCDgYX5Di1Eq2XNP3pS','student'),
 ('01FF6J8Y2RFY8BMPCJ6BB8CGS3','S04999','新井 大地','$2a$04$nJpLehaw6o8qR5Uq7csz6.Jp.SyMmHN/t.mLSaghq0zQ7nLy5S9im','student');
-INSERT INTO `courses` (`id`, `code`, `type`, `name`, `description`, `credit`, `period`, `day_of_week`, `teacher_id`, `keywords`, `status`) VALUES
-('01FF6N3NA2J712CAH9X8SRRK8R', 'A0001', 'liberal-arts', '社会モデリング導入', '本講義では課題提出をもって出席の代わりとする。成績は出席と課題の提出状況により判断する。', 2, 1, 'monday', '01FF6J8XFSWM3X28NM0WXZQ8QK', '社会 モデリング', 'closed'),
-('01FF6N3NA2J712CAH9XAC2BG5E', 'A0002', 'major-subjects', '言語システム演習', '本講義では出席をランダムな講義回で取る。成績は出席と課題の提出状況により判断する。', 2, 2, 'monday', '01FF6J8XFSCAT6ADYNN5G33QQC', '言語 システム', 'closed'),
-('01FF6N3NA2J712CAH9XB3WZX2D', 'A0003', 'major-subjects', '先進マネジメント化学導入', '本講義では出席をランダムな講義回で取る。成績は課題の提出状況により判断する。', 3, 3, 'monday', '01FF6J8XFSRFYE6GGAB4Y3F94P', 'マネジメント 化学', 'closed'),
-('01FF6N3NA2J712CAH9XF1KHVAC', 'A0004', 'liberal-arts', '社会システムA', '本講義では課題提出をもって出席の代わりとする。成績は課題の提出状況により判断する。', 1, 4, 'monday', '01FF6J8XFSHMQTXPHJ9BHD64FC', '社会 システム', 'closed'),
-('01FF6N3NA2J712CAH9XH9ZH2V4', 'A0005', 'major-subjects', '言語デザイン基礎', '本講義では出席をランダムな講義回で取る。成績は課題の提出状況により判断する。', 1, 5, 'monday', '01FF6J8XFS3906KB5YNKHSNQWG', '言語 デザイン', 'closed'),
-('01FF6N3NA2J712CAH9XKR2GVV6', 'A0006', 'major-subjects', '先進生命リテラシー応用', '本講義では出席をランダムな講義回で取る。成績は出席と課題の提出状況により判断する。', 2, 6, 'monday', '01FF6J8XFSVX41R59K7JT2TT12', '生命 リテラシー', 'closed'),
-('01FF6N3NA2J712CAH9XM75WAN4', 'A0007', 'major-subjects', '知能化プログラミング力学第二', '本講義では出席を毎回取る。成績は出席と課題の提出状況により判断する。', 3, 1, 'tuesday', '01FF6J8XFSMB96ZKQZK8G516B3', 'プログラミング 力学', 'closed'),
-('01FF6N3NA2J712CAH9XN1WQXW1', 'A0008', 'liberal-arts', '言語デザインA', '本講義では出席をランダムな講義回で取る。成績は課題の提出状況により判断する。', 3, 2, 'tuesday', '01FF6J8XFSX748CNN1MTCEY4VT', '言語 デザイン', 'closed'),
-('01FF6N3NA2J712CAH9XNDYQ517', 'A0009', 'major-subjects', 'コミュニケーションメカトロニクス概論', '本講義では出席をランダムな講義回で取る。成績は課題の提出状況により判断する。', 2, 3, 'tuesday', '01FF6J8XFS4N2RA3TS2B2SK600', 'コミュニケーション メカトロニクス', 'closed'),
-('01FF6N3NA2J712CAH9XPV0BJNC', 'A0010', 'major-subjects', '知能化コンピューティングネットワークA', '本講義では出席を毎回取る。成績は出席と課題の提出状況により判断する。', 3, 4, 'tuesday', '01FF6J8XFTM3BB01XKXYNGBKKM', 'コンピューティング ネットワーク', 'closed'),
-('01FF6N3NA2J712CAH9XSYG4HZP', 'A0011', 'major-subjects', '機能的コンピュータシステム特論', '本講義では出席を毎回取る。成績は課題の提出状況により判断する。', 1, 5, 'tuesday', '01FF6J8XFSWM3X28NM0WXZQ8QK', 'コンピュータ システム', 'closed'),
-('01FF6N3NA2J712CAH9XWCGW21Z', 'A0012', 'major-subjects', '先進アルゴリズムシステム導入', '本講義では課題提出をもって出席の代わりとする。成績は出席と課題の提出状況により判断する。', 2, 6, 'tuesday', '01FF6J8XFSCAT6ADYNN5G33QQC', 'アルゴリズム システム', 'closed'),
-('01FF6N3NA2J712CAH9XXAP5FQ5', 'A0013', 'major-subjects', '知能化プログラミングリテラシー基礎', '本講義では出席を毎回取る。成績は課題の提出状況により判断する。', 3, 1, 'wednesday', '01FF6J8XFSRFYE6GGAB4Y3F94P', 'プログラミング リテラシー', 'closed'),
-('01FF6N3NA2J712CAH9Y0WMDG2S', 'A0014', 'major-subjects', '知能化統計工学A', '本講義では課題提出をもって出席の代わりとする。成績は課題の提出状況により判断する。', 1, 2, 'wednesday', '01FF6J8XFSHMQTXPHJ9BHD64FC', '統計 工学', 'closed'),
-('01FF6N3NA2J712CAH9Y3B2A7J8', 'A0015', 'major-subjects', 'アルゴリズム工学C', '本講義では出席をランダムな講義回で取る。成績は出席と課題の提出状況により判断する。', 3, 3, 'wednesday', '01FF6J8XFS3906KB5YNKHSNQWG', 'アルゴリズム 工学', 'closed'),
-('01FF6N3NA2J712CAH9Y53860NG', 'A0016', 'liberal-arts', '椅子史導入', '本講義では出席をランダムな講義回で取る。成績は出席と課題の提出状況により判断する。', 2, 4, 'wednesday', '01FF6J8XFSVX41R59K7JT2TT12', '椅子 史', 'closed'),
-('01FF6N3NA2J712CAH9Y73GNWPY', 'A0017', 'major-subjects', '生命システム演習', '本講義では出席を毎回取る。成績は課題の提出状況により判断する。', 1, 5, 'wednesday', '01FF6J8XFSMB96ZKQZK8G516B3', '生命 システム', 'closed'),
-('01FF6N3NA2J712CAH9YA5870HX', 'A0018', 'major-subjects', '先進コンピュータ力学Ⅱ', '本講義では出席を毎回取る。成績は出席と課題の提出状況により判断する。', 3, 6, 'wednesday', '01FF6J8XFSX748CNN1MTCEY4VT', 'コンピュータ 力学', 'closed'),
-('01FF6N3NA2J712CAH9YCMDM47S', 'A0019', 'major-subjects', 'バイオ工学A', '本講義では課題提出をもって出席の代わりとする。成績は課題の提出状況により判断する。', 1, 1, 'thursday', '01FF6J8XFS4N2RA3TS2B2SK600', 'バイオ 工学', 'closed'),
-('01FF6N3NA2J712CAH9YDHXN9G8', 'A0020', 'major-subjects', '機能的統計サイエンス第二', '本講義では課題提出をもって出席の代わりとする。成績は課題の提出状況により判断する。', 1, 2, 'thursday', '01FF6J8XFTM3BB01XKXYNGBKKM', '統計 サイエンス', 'closed'),
-('01FF6N3NA2J712CAH9YF4N0NPG', 'A0021', 'liberal-arts', '法学システムB', '本講義では課題提出をもって出席の代わりとする。成績は課題の提出状況により判断する。', 3, 3, 'thursday', '01FF6J8XFSWM3X28NM0WXZQ8QK', '法学 システム', 'closed'),
-('01FF6N3NA2J712CAH9YGM6335H', 'A0022', 'liberal-arts', '言語システムB', '本講義では出席を毎回取る。成績は出席と課題の提出状況により判断する。', 2, 4, 'thursday', '01FF6J8XFSCAT6ADYNN5G33QQC', '言語 システム', 'closed'),
-('01FF6N3NA2J712CAH9YM9YM1YN', 'A0023', 'major-subjects', 'アルゴリズム化学特論', '本講義では出席を毎回取る。成績は課題の提出状況により判断する。', 2, 5, 'thursday', '01FF6J8XFSRFYE6GGAB4Y3F94P', 'アルゴリズム 化学', 'closed'),
-('01FF6N3NA2J712CAH9YPYG4KWS', 'A0024', 'major-subjects', 'アルゴリズムネットワークB', '本講義では課題提出をもって出席の代わりとする。成績は出席と課題の提出状況により判断する。', 2, 6, 'thursday', '01FF6J8XFSHMQTXPHJ9BHD64FC', 'アルゴリズム ネットワーク', 'closed'),
-('01FF6N3NA2J712CAH9YQ613ZSY', 'A0025', 'major-subjects', 'バイオネットワーク特論', '本講義では出席をランダムな講義回で取る。成績は出席と課題の提出状況により判断する。', 1, 1, 'friday', '01FF6J8XFS3906KB5YNKHSNQWG', 'バイオ ネットワーク', 'closed'),
-('01FF6N3NA2J712CAH9YRR6VRFA', 'A0026', 'major-subjects', '椅子化学演習', '本講義では課題提出をもって出席の代わりとする。成績は出席と課題の提出状況により判断する。', 2, 2, 'friday', '01FF6J8XFSVX41R59K7JT2TT12', '椅子 化学', 'closed'),
-('01FF6N3NA2J712CAH9YTC20ACW', 'A0027', 'major-subjects', '先進プログラミングネットワークB', '本講義では出席をランダムな講義回で取る。成績は課題の提出状況により判断する。', 2, 3, 'friday', '01FF6J8XFSMB96ZKQZK8G516B3', 'プログラミング ネットワーク', 'closed'),
-('01FF6N3NA2J712CAH9YWXTWF89', 'A0028', 'major-subjects', '量子言語メカトロニクス特論', '本講義では出席を毎回取る。成績は出席と課題の提出状況により判断する。', 1, 4, 'friday', '01FF6J8XFSX748CNN1MTCEY4VT', '言語 メカトロニクス', 'closed'),
-('01FF6N3NA2J712CAH9YYQAE96H', 'A0029', 'liberal-arts', '社会サイエンス第二', '本講義では出席を毎回取る。成績は課題の提出状況により判断する。', 2, 5, 'friday', '01FF6J8XFS4N2RA3TS2B2SK600', '社会 サイエンス', 'closed'),
-('01FF6N3NA2J712CAH9Z1KYVWKM', 'A0030', 'major-subjects', '機能的プログラミング力学基礎', '本講義では課題提出をもって出席の代わりとする。成績は課題の提出状況により判断する。', 3, 6, 'friday', '01FF6J8XFTM3BB01XKXYNGBKKM', 'プログラミング 力学', 'closed');
+INSERT INTO `semesters` (`id`, `code`, `name`, `starts_on`) VALUES
+('01FF6J8XFQM9S346Q3D25VT4F5', '2021-1', '2021年度 前期', '2021-04-05');
+INSERT INTO `courses` (`id`, `code`, `type`, `name`, `description`, `credit`, `period`, `day_of_week`, `teacher_id`, `keywords`, `status`, `semester_id`) VALUES
+('01FF6N3NA2J712CAH9X8SRRK8R', 'A0001', 'liberal-arts', '社会モデリング導入', '本講義では課題提出をもって出席の代わりとする。成績は出席と課題の提出状況により判断する。', 2, 1, 'monday', '01FF6J8XFSWM3X28NM0WXZQ8QK', '社会 モデリング', 'closed', '01FF6J8XFQM9S346Q3D25VT4F5'),
+('01FF6N3NA2J712CAH9XAC2BG5E', 'A0002', 'major-subjects', '言語システム演習', '本講義では出席をランダムな講義回で取る。成績は出席と課題の提出状況により判断する。', 2, 2, 'monday', '01FF6J8XFSCAT6ADYNN5G33QQC', '言語 システム', 'closed', '01FF6J8XFQM9S346Q3D25VT4F5'),
+('01FF6N3NA2J712CAH9XB3WZX2D', 'A0003', 'major-subjects', '先進マネジメント化学導入', '本講義では出席をランダムな講義回で取る。成績は課題の提出状況により判断する。', 3, 3, 'monday', '01FF6J8XFSRFYE6GGAB4Y3F94P', 'マネジメント 化学', 'closed', '01FF6J8XFQM9S346Q3D25VT4F5'),
+('01FF6N3NA2J712CAH9XF1KHVAC', 'A0004', 'liberal-arts', '社会システムA', '本講義では課題提出をもって出席の代わりとする。成績は課題の提出状況により判断する。', 1, 4, 'monday', '01FF6J8XFSHMQTXPHJ9BHD64FC', '社会 システム', 'closed', '01FF6J8XFQM9S346Q3D25VT4F5'),
+('01FF6N3NA2J712CAH9XH9ZH2V4', 'A0005', 'major-subjects', '言語デザイン基礎', '本講義では出席をランダムな講義回で取る。成績は課題の提出状況により判断する。', 1, 5, 'monday', '01FF6J8XFS3906KB5YNKHSNQWG', '言語 デザイン', 'closed', '01FF6J8XFQM9S346Q3D25VT4F5'),
+('01FF6N3NA2J712CAH9XKR2GVV6', 'A0006', 'major-subjects', '先進生命リテラシー応用', '本講義では出席をランダムな講義回で取る。成績は出席と課題の提出状況により判断する。', 2, 6, 'monday', '01FF6J8XFSVX41R59K7JT2TT12', '生命 リテラシー', 'closed', '01FF6J8XFQM9S346Q3D25VT4F5'),
+('01FF6N3NA2J712CAH9XM75WAN4', 'A0007', 'major-subjects', '知能化プログラミング力学第二', '本講義では出席を毎回取る。成績は出席と課題の提出状況により判断する。', 3, 1, 'tuesday', '01FF6J8XFSMB96ZKQZK8G516B3', 'プログラミング 力学', 'closed', '01FF6J8XFQM9S346Q3D25VT4F5'),
+('01FF6N3NA2J712CAH9XN1WQXW1', 'A0008', 'liberal-arts', '言語デザインA', '本講義では出席をランダムな講義回で取る。成績は課題の提出状況により判断する。', 3, 2, 'tuesday', '01FF6J8XFSX748CNN1MTCEY4VT', '言語 デザイン', 'closed', '01FF6J8XFQM9S346Q3D25VT4F5'),
+('01FF6N3NA2J712CAH9XNDYQ517', 'A0009', 'major-subjects', 'コミュニケーションメカトロニクス概論', '本講義では出席をランダムな講義回で取る。成績は課題の提出状況により判断する。', 2, 3, 'tuesday', '01FF6J8XFS4N2RA3TS2B2SK600', 'コミュニケーション メカトロニクス', 'closed', '01FF6J8XFQM9S346Q3D25VT4F5'),
+('01FF6N3NA2J712CAH9XPV0BJNC', 'A0010', 'major-subjects', '知能化コンピューティングネットワークA', '本講義では出席を毎回取る。成績は出席と課題の提出状況により判断する。', 3, 4, 'tuesday', '01FF6J8XFTM3BB01XKXYNGBKKM', 'コンピューティング ネットワーク', 'closed', '01FF6J8XFQM9S346Q3D25VT4F5'),
+('01FF6N3NA2J712CAH9XSYG4HZP', 'A0011', 'major-subjects', '機能的コンピュータシステム特論', '本講義では出席を毎回取る。成績は課題の提出状況により判断する。', 1, 5, 'tuesday', '01FF6J8XFSWM3X28NM0WXZQ8QK', 'コンピュータ システム', 'closed', '01FF6J8XFQM9S346Q3D25VT4F5'),
+('01FF6N3NA2J712CAH9XWCGW21Z', 'A0012', 'major-subjects', '先進アルゴリズムシステム導入', '本講義では課題提出をもって出席の代わりとする。成績は出席と課題の提出状況により判断する。', 2, 6, 'tuesday', '01FF6J8XFSCAT6ADYNN5G33QQC', 'アルゴリズム システム', 'closed', '01FF6J8XFQM9S346Q3D25VT4F5'),
+('01FF6N3NA2J712CAH9XXAP5FQ5', 'A0013', 'major-subjects', '知能化プログラミングリテラシー基礎', '本講義では出席を毎回取る。成績は課題の提出状況により判断する。', 3, 1, 'wednesday', '01FF6J8XFSRFYE6GGAB4Y3F94P', 'プログラミング リテラシー', 'closed', '01FF6J8XFQM9S346Q3D25VT4F5'),
+('01FF6N3NA2J712CAH9Y0WMDG2S', 'A0014', 'major-subjects', '知能化統計工学A', '本講義では課題提出をもって出席の代わりとする。成績は課題の提出状況により判断する。', 1, 2, 'wednesday', '01FF6J8XFSHMQTXPHJ9BHD64FC', '統計 工学', 'closed', '01FF6J8XFQM9S346Q3D25VT4F5'),
+('01FF6N3NA2J712CAH9Y3B2A7J8', 'A0015', 'major-subjects', 'アルゴリズム工学C', '本講義では出席をランダムな講義回で取る。成績は出席と課題の提出状況により判断する。', 3, 3, 'wednesday', '01FF6J8XFS3906KB5YNKHSNQWG', 'アルゴリズム 工学', 'closed', '01FF6J8XFQM9S346Q3D25VT4F5'),
+('01FF6N3NA2J712CAH9Y53860NG', 'A0016', 'liberal-arts', '椅子史導入', '本講義では出席をランダムな講義回で取る。成績は出席と課題の提出状況により判断する。', 2, 4, 'wednesday', '01FF6J8XFSVX41R59K7JT2TT12', '椅子 史', 'closed', '01FF6J8XFQM9S346Q3D25VT4F5'),
+('01FF6N3NA2J712CAH9Y73GNWPY', 'A0017', 'major-subjects', '生命システム演習', '本講義では出席を毎回取る。成績は課題の提出状況により判断する。', 1, 5, 'wednesday', '01FF6J8XFSMB96ZKQZK8G516B3', '生命 システム', 'closed', '01FF6J8XFQM9S346Q3D25VT4F5'),
+('01FF6N3NA2J712CAH9YA5870HX', 'A0018', 'major-subjects', '先進コンピュータ力学Ⅱ', '本講義では出席を毎回取る。成績は出席と課題の提出状況により判断する。', 3, 6, 'wednesday', '01FF6J8XFSX748CNN1MTCEY4VT', 'コンピュータ 力学', 'closed', '01FF6J8XFQM9S346Q3D25VT4F5'),
+('01FF6N3NA2J712CAH9YCMDM47S', 'A0019', 'major-subjects', 'バイオ工学A', '本講義では課題提出をもって出席の代わりとする。成績は課題の提出状況により判断する。', 1, 1, 'thursday', '01FF6J8XFS4N2RA3TS2B2SK600', 'バイオ 工学', 'closed', '01FF6J8XFQM9S346Q3D25VT4F5'),
+('01FF6N3NA2J712CAH9YDHXN9G8', 'A0020', 'major-subjects', '機能的統計サイエンス第二', '本講義では課題提出をもって出席の代わりとする。成績は課題の提出状況により判断する。', 1, 2, 'thursday', '01FF6J8XFTM3BB01XKXYNGBKKM', '統計 サイエンス', 'closed', '01FF6J8XFQM9S346Q3D25VT4F5'),
+('01FF6N3NA2J712CAH9YF4N0NPG', 'A0021', 'liberal-arts', '法学システムB', '本講義では課題提出をもって出席の代わりとする。成績は課題の提出状況により判断する。', 3, 3, 'thursday', '01FF6J8XFSWM3X28NM0WXZQ8QK', '法学 システム', 'closed', '01FF6J8XFQM9S346Q3D25VT4F5'),
+('01FF6N3NA2J712CAH9YGM6335H', 'A0022', 'liberal-arts', '言語システムB', '本講義では出席を毎回取る。成績は出席と課題の提出状況により判断する。', 2, 4, 'thursday', '01FF6J8XFSCAT6ADYNN5G33QQC', '言語 システム', 'closed', '01FF6J8XFQM9S346Q3D25VT4F5'),
+('01FF6N3NA2J712CAH9YM9YM1YN', 'A0023', 'major-subjects', 'アルゴリズム化学特論', '本講義では出席を毎回取る。成績は課題の提出状況により判断する。', 2, 5, 'thursday', '01FF6J8XFSRFYE6GGAB4Y3F94P', 'アルゴリズム 化学', 'closed', '01FF6J8XFQM9S346Q3D25VT4F5'),
+('01FF6N3NA2J712CAH9YPYG4KWS', 'A0024', 'major-subjects', 'アルゴリズムネットワークB', '本講義では課題提出をもって出席の代わりとする。成績は出席と課題の提出状況により判断する。', 2, 6, 'thursday', '01FF6J8XFSHMQTXPHJ9BHD64FC', 'アルゴリズム ネットワーク', 'closed', '01FF6J8XFQM9S346Q3D25VT4F5'),
+('01FF6N3NA2J712CAH9YQ613ZSY', 'A0025', 'major-subjects', 'バイオネットワーク特論', '本講義では出席をランダムな講義回で取る。成績は出席と課題の提出状況により判断する。', 1, 1, 'friday', '01FF6J8XFS3906KB5YNKHSNQWG', 'バイオ ネットワーク', 'closed', '01FF6J8XFQM9S346Q3D25VT4F5'),
+('01FF6N3NA2J712CAH9YRR6VRFA', 'A0026', 'major-subjects', '椅子化学演習', '本講義では課題提出をもって出席の代わりとする。成績は出席と課題の提出状況により判断する。', 2, 2, 'friday', '01FF6J8XFSVX41R59K7JT2TT12', '椅子 化学', 'closed', '01FF6J8XFQM9S346Q3D25VT4F5'),
+('01FF6N3NA2J712CAH9YTC20ACW', 'A0027', 'major-subjects', '先進プログラミングネットワークB', '本講義では出席をランダムな講義回で取る。成績は課題の提出状況により判断する。', 2, 3, 'friday', '01FF6J8XFSMB96ZKQZK8G516B3', 'プログラミング ネットワーク', 'closed', '01FF6J8XFQM9S346Q3D25VT4F5'),
+('01FF6N3NA2J712CAH9YWXTWF89', 'A0028', 'major-subjects', '量子言語メカトロニクス特論', '本講義では出席を毎回取る。成績は出席と課題の提出状況により判断する。', 1, 4, 'friday', '01FF6J8XFSX748CNN1MTCEY4VT', '言語 メカトロニクス', 'closed', '01FF6J8XFQM9S346Q3D25VT4F5'),
+('01FF6N3NA2J712CAH9YYQAE96H', 'A0029', 'liberal-arts', '社会サイエンス第二', '本講義では出席を毎回取る。成績は課題の提出状況により判断する。', 2, 5, 'friday', '01FF6J8XFS4N2RA3TS2B2SK600', '社会 サイエンス', 'closed', '01FF6J8XFQM9S346Q3D25VT4F5'),
+('01FF6N3NA2J712CAH9Z1KYVWKM', 'A0030', 'major-subjects', '機能的プログラミング力学基礎', '本講義では課題提出をもって出席の代わりとする。成績は課題の提出状況により判断する。', 3, 6, 'friday', '01FF6J8XFTM3BB01XKXYNGBKKM', 'プログラミング 力学', 'closed', '01FF6J8XFQM9S346Q3D25VT4F5');
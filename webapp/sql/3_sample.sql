@@ -4,10 +4,10 @@ INSERT INTO `users` (`id`, `code`, `name`, `hashed_password`, `type`) VALUES
 ('01FF4RXEKS0DG2EG20CQVX6FV0','S99998','isucon2','$2a$04$abH7BE13odlVdw.rLLDvT.mWcTsvR.FXIm0.Pu0p2iiE4WvV6N51O','student'),
 ('01FF4RXEKS0DG2EG20CTTAPEVH','S99997','isucon3','$2a$04$6q3Lb.KYJLkkaWx34DMVy.1t2icsMbzW1eQvwFzXesHW3encgz/ru','student');
 
-INSERT INTO `courses` VALUES
-('01FF4RXEKS0DG2EG20CWPQ60M3','X0001','major-subjects','ISUCON演習第一','この科目ではISUCONの過去問を通してサーバのチューニングアップを学びます。課題は講義中に出題するクイズへの回答を提出してください。本講義の成績は課題の提出状況により判断します。',1,1,'monday','01FF4RXEKS0DG2EG20CKDWS7CC','ISUCON SpeedUP','in-progress'),
-('01FF4RXEKS0DG2EG20CYAYCCGM','X0002','major-subjects','ISUCON演習第二','この科目ではISUCONの過去問を通してサーバのチューニングアップを学びます。課題は講義中に出題するクイズへの回答を提出してください。本講義の成績は課題の提出状況により判断します。',1,1,'tuesday','01FF4RXEKS0DG2EG20CKDWS7CC','ISUCON SpeedUP','in-progress'),
-('01FF4RXEKS0DG2EG20D23EQZRY','X0003','major-subjects','ISUCON演習第三','この科目ではISUCONの過去問を通してサーバのチューニングアップを学びます。課題は講義中に出題するクイズへの回答を提出してください。本講義の成績は課題の提出状況により判断します。',1,1,'wednesday','01FF4RXEKS0DG2EG20CKDWS7CC','ISUCON SpeedUP','registration');
+INSERT INTO `courses` (`id`, `code`, `type`, `name`, `description`, `credit`, `period`, `day_of_week`, `teacher_id`, `keywords`, `status`, `semester_id`) VALUES
+('01FF4RXEKS0DG2EG20CWPQ60M3','X0001','major-subjects','ISUCON演習第一','この科目ではISUCONの過去問を通してサーバのチューニングアップを学びます。課題は講義中に出題するクイズへの回答を提出してください。本講義の成績は課題の提出状況により判断します。',1,1,'monday','01FF4RXEKS0DG2EG20CKDWS7CC','ISUCON SpeedUP','in-progress','01FF6J8XFQM9S346Q3D25VT4F5'),
+('01FF4RXEKS0DG2EG20CYAYCCGM','X0002','major-subjects','ISUCON演習第二','この科目ではISUCONの過去問を通してサーバのチューニングアップを学びます。課題は講義中に出題するクイズへの回答を提出してください。本講義の成績は課題の提出状況により判断します。',1,1,'tuesday','01FF4RXEKS0DG2EG20CKDWS7CC','ISUCON SpeedUP','in-progress','01FF6J8XFQM9S346Q3D25VT4F5'),
+('01FF4RXEKS0DG2EG20D23EQZRY','X0003','major-subjects','ISUCON演習第三','この科目ではISUCONの過去問を通してサーバのチューニングアップを学びます。課題は講義中に出題するクイズへの回答を提出してください。本講義の成績は課題の提出状況により判断します。',1,1,'wednesday','01FF4RXEKS0DG2EG20CKDWS7CC','ISUCON SpeedUP','registration','01FF6J8XFQM9S346Q3D25VT4F5');
 
 INSERT INTO `registrations` VALUES
 ('01FF4RXEKS0DG2EG20CWPQ60M3','01FF4RXEKS0DG2EG20CN2GJB8K'),
@@ -15,7 +15,7 @@ INSERT INTO `registrations` VALUES
 ('01FF4RXEKS0DG2EG20CWPQ60M3','01FF4RXEKS0DG2EG20CTTAPEVH'),
 ('01FF4RXEKS0DG2EG20CYAYCCGM','01FF4RXEKS0DG2EG20CN2GJB8K');
 
-INSERT INTO `classes` VALUES
+INSERT INTO `classes` (`id`, `course_id`, `part`, `title`, `description`, `submission_closed`) VALUES
 ('01FF4RXEKS0DG2EG20CWPQ60M3','01FF4RXEKS0DG2EG20CWPQ60M3',1,'ISUCON3 予選','本日はISUCON3 予選の過去問を実施します。課題は講義中に出題するクイズへの回答を提出してください。',0),
 ('01FF4RXEKS0DG2EG20CYAYCCGM','01FF4RXEKS0DG2EG20CWPQ60M3',2,'ISUCON4 予選','本日はISUCON4 予選の過去問を実施します。課題は講義中に出題するクイズへの回答を提出してください。',0),
 ('01FF4RXEKS0DG2EG20D23EQZRY','01FF4RXEKS0DG2EG20CWPQ60M3',3,'ISUCON5 予選','本日はISUCON5 予選の過去問を実施します。課題は講義中に出題するクイズへの回答を提出してください。',0),
@@ -46,7 +46,7 @@ INSERT INTO `unread_announcements` VALUES
 ('01FF4RXEKS0DG2EG20DBT4PFHF','01FF4RXEKS0DG2EG20CTTAPEVH',true),
 ('01FF4RXEKS0DG2EG20DDPCS14P','01FF4RXEKS0DG2EG20CTTAPEVH',true);
 
-INSERT INTO `submissions` VALUES
+INSERT INTO `submissions` (`user_id`, `class_id`, `file_name`, `score`) VALUES
 ('01FF4RXEKS0DG2EG20CN2GJB8K','01FF4RXEKS0DG2EG20CWPQ60M3','S99999_1st.pdf',72),
 ('01FF4RXEKS0DG2EG20CN2GJB8K','01FF4RXEKS0DG2EG20CYAYCCGM','S99999_2nd.pdf',65),
 ('01FF4RXEKS0DG2EG20CN2GJB8K','01FF4RXEKS0DG2EG20D23EQZRY','S99999_3rd.pdf',88),
@@ -62,3 +62,17 @@ INSERT INTO `submissions` VALUES
 ('01FF4RXEKS0DG2EG20CTTAPEVH','01FF4RXEKS0DG2EG20D23EQZRY','S99997_3rd.pdf',73),
 ('01FF4RXEKS0DG2EG20CTTAPEVH','01FF4RXEKS0DG2EG20D4APKY18','S99997_4th.pdf',79),
 ('01FF4RXEKS0DG2EG20CTTAPEVH','01FF4RXEKS0DG2EG20D61YCEM1','S99997_5th.pdf',100);
+
+-- 成績の集計値
+INSERT INTO `course_total_scores` (`course_id`, `user_id`, `total_score`)
+SELECT `registrations`.`course_id`, `registrations`.`user_id`, IFNULL(SUM(`submissions`.`score`), 0)
+FROM `registrations`
+LEFT JOIN `classes` ON `registrations`.`course_id` = `classes`.`course_id`
+LEFT JOIN `submissions` ON `registrations`.`user_id` = `submissions`.`user_id` AND `submissions`.`class_id` = `classes`.`id`
+GROUP BY `registrations`.`course_id`, `registrations`.`user_id`;
+
+INSERT INTO `user_gpas` (`user_id`, `credits`, `gpa_credits`, `weighted_grade_points`)
+SELECT `course_total_scores`.`user_id`, SUM(`courses`.`credit`), SUM(`courses`.`credit`), SUM(`course_total_scores`.`total_score` * `courses`.`credit`)
+FROM `course_total_scores`
+JOIN `courses` ON `course_total_scores`.`course_id` = `courses`.`id` AND `courses`.`status` = 'closed'
+GROUP BY `course_total_scores`.`user_id`;